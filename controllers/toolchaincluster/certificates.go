@@ -0,0 +1,125 @@
+package toolchaincluster
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultCertificateExpiryWarningWindow is the default time before the expiry of a certificate when the CertificatesExpiringSoon condition is set to True
+const DefaultCertificateExpiryWarningWindow = 30 * 24 * time.Hour
+
+const (
+	// ConditionCertificatesValid is set when the config of the ToolchainCluster contains a client certificate or CA certificates.
+	// It's False when a certificate expired or can't be parsed. The message contains the expiry dates of the certificates.
+	ConditionCertificatesValid toolchainv1alpha1.ConditionType = "CertificatesValid"
+	// ConditionCertificatesExpiringSoon is set when the config of the ToolchainCluster contains a client certificate or CA certificates.
+	// It's True when a certificate expires within the CertificateExpiryWarningWindow of the Reconciler (or already expired),
+	// so that the certificate can be renewed before the cluster goes offline.
+	ConditionCertificatesExpiringSoon toolchainv1alpha1.ConditionType = "CertificatesExpiringSoon"
+
+	// ToolchainClusterCertificatesValidReason none of the certificates expired
+	ToolchainClusterCertificatesValidReason = "CertificatesValid"
+	// ToolchainClusterCertificateExpiredReason the client certificate or a CA certificate expired
+	ToolchainClusterCertificateExpiredReason = "CertificateExpired"
+	// ToolchainClusterInvalidCertificateReason the client certificate or a CA certificate can't be parsed
+	ToolchainClusterInvalidCertificateReason = "InvalidCertificate"
+	// ToolchainClusterCertificateExpiringSoonReason the client certificate or a CA certificate expires within the warning window
+	ToolchainClusterCertificateExpiringSoonReason = "CertificateExpiringSoon"
+	// ToolchainClusterCertificatesNotExpiringSoonReason none of the certificates expires within the warning window
+	ToolchainClusterCertificatesNotExpiringSoonReason = "CertificatesNotExpiringSoon"
+)
+
+const (
+	clientCertificate = "client"
+	caCertificate     = "ca"
+)
+
+// certificateExpiry is the expiry of the client certificate or of the CA certificates of the cluster
+type certificateExpiry struct {
+	certificate string
+	notAfter    time.Time
+}
+
+func (e certificateExpiry) String() string {
+	if e.certificate == clientCertificate {
+		return "client certificate"
+	}
+	return "CA certificate"
+}
+
+// certificateExpiries returns the expiries of the certificates of the given config, as computed when the config was loaded
+func certificateExpiries(config *cluster.Config) ([]certificateExpiry, error) {
+	if config.CertificatesError != nil {
+		return nil, config.CertificatesError
+	}
+	var expiries []certificateExpiry
+	if config.ClientCertificateNotAfter != nil {
+		expiries = append(expiries, certificateExpiry{certificate: clientCertificate, notAfter: *config.ClientCertificateNotAfter})
+	}
+	if config.CANotAfter != nil {
+		expiries = append(expiries, certificateExpiry{certificate: caCertificate, notAfter: *config.CANotAfter})
+	}
+	return expiries, nil
+}
+
+// certificatesConditions returns the CertificatesValid and CertificatesExpiringSoon conditions with the expiry dates of the certificates
+// of the given config, or no condition if the config doesn't contain any certificate. It also returns the expiries of the certificates
+// and whether any of them expires within the given window.
+func certificatesConditions(config *cluster.Config, now time.Time, window time.Duration) ([]toolchainv1alpha1.Condition, []certificateExpiry, bool) {
+	expiries, err := certificateExpiries(config)
+	if err != nil {
+		return []toolchainv1alpha1.Condition{{
+			Type:    ConditionCertificatesValid,
+			Status:  corev1.ConditionFalse,
+			Reason:  ToolchainClusterInvalidCertificateReason,
+			Message: err.Error(),
+		}}, nil, false
+	}
+	if len(expiries) == 0 {
+		return nil, nil, false
+	}
+
+	valid := toolchainv1alpha1.Condition{
+		Type:   ConditionCertificatesValid,
+		Status: corev1.ConditionTrue,
+		Reason: ToolchainClusterCertificatesValidReason,
+	}
+	expiringSoon := toolchainv1alpha1.Condition{
+		Type:   ConditionCertificatesExpiringSoon,
+		Status: corev1.ConditionFalse,
+		Reason: ToolchainClusterCertificatesNotExpiringSoonReason,
+	}
+	var validMsgs, expiringMsgs []string
+	for _, expiry := range expiries {
+		notAfter := expiry.notAfter.UTC().Format(time.RFC3339)
+		if expiry.notAfter.Before(now) {
+			valid.Status = corev1.ConditionFalse
+			valid.Reason = ToolchainClusterCertificateExpiredReason
+			validMsgs = append(validMsgs, fmt.Sprintf("%s expired at %s", expiry, notAfter))
+		} else {
+			validMsgs = append(validMsgs, fmt.Sprintf("%s expires at %s", expiry, notAfter))
+		}
+		if expiry.notAfter.Sub(now) <= window {
+			expiringSoon.Status = corev1.ConditionTrue
+			expiringSoon.Reason = ToolchainClusterCertificateExpiringSoonReason
+			expiringMsgs = append(expiringMsgs, fmt.Sprintf("%s expires in %s", expiry, expiry.notAfter.Sub(now).Round(time.Minute)))
+		}
+	}
+	valid.Message = strings.Join(validMsgs, ", ")
+	if len(expiringMsgs) > 0 {
+		expiringSoon.Message = fmt.Sprintf("%s (warning window: %s)", strings.Join(expiringMsgs, ", "), window)
+	}
+	return []toolchainv1alpha1.Condition{valid, expiringSoon}, expiries, expiringSoon.Status == corev1.ConditionTrue
+}
+
+func (r *Reconciler) certificateExpiryWarningWindow() time.Duration {
+	if r.CertificateExpiryWarningWindow > 0 {
+		return r.CertificateExpiryWarningWindow
+	}
+	return DefaultCertificateExpiryWarningWindow
+}
@@ -0,0 +1,180 @@
+package toolchaincluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+)
+
+func TestCertificatesConditions(t *testing.T) {
+	// given
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clientNotAfter := now.Add(60 * 24 * time.Hour)
+	expiringCANotAfter := now.Add(10 * 24 * time.Hour)
+	expiredCANotAfter := now.Add(-time.Hour)
+
+	t.Run("valid certificates", func(t *testing.T) {
+		// when
+		conditions, expiries, expiringSoon := certificatesConditions(&cluster.Config{ClientCertificateNotAfter: &clientNotAfter}, now, DefaultCertificateExpiryWarningWindow)
+
+		// then
+		assert.Equal(t, []toolchainv1alpha1.Condition{
+			{
+				Type:    ConditionCertificatesValid,
+				Status:  corev1.ConditionTrue,
+				Reason:  ToolchainClusterCertificatesValidReason,
+				Message: "client certificate expires at 2026-03-02T00:00:00Z",
+			},
+			{
+				Type:   ConditionCertificatesExpiringSoon,
+				Status: corev1.ConditionFalse,
+				Reason: ToolchainClusterCertificatesNotExpiringSoonReason,
+			},
+		}, conditions)
+		assert.Equal(t, []certificateExpiry{{certificate: clientCertificate, notAfter: now.Add(60 * 24 * time.Hour)}}, expiries)
+		assert.False(t, expiringSoon)
+	})
+
+	t.Run("certificate expiring within the window", func(t *testing.T) {
+		// when
+		conditions, _, expiringSoon := certificatesConditions(&cluster.Config{ClientCertificateNotAfter: &clientNotAfter, CANotAfter: &expiringCANotAfter}, now, DefaultCertificateExpiryWarningWindow)
+
+		// then
+		assert.Equal(t, []toolchainv1alpha1.Condition{
+			{
+				Type:    ConditionCertificatesValid,
+				Status:  corev1.ConditionTrue,
+				Reason:  ToolchainClusterCertificatesValidReason,
+				Message: "client certificate expires at 2026-03-02T00:00:00Z, CA certificate expires at 2026-01-11T00:00:00Z",
+			},
+			{
+				Type:    ConditionCertificatesExpiringSoon,
+				Status:  corev1.ConditionTrue,
+				Reason:  ToolchainClusterCertificateExpiringSoonReason,
+				Message: "CA certificate expires in 240h0m0s (warning window: 720h0m0s)",
+			},
+		}, conditions)
+		assert.True(t, expiringSoon)
+	})
+
+	t.Run("certificate expiring within the custom window", func(t *testing.T) {
+		// when
+		conditions, _, expiringSoon := certificatesConditions(&cluster.Config{ClientCertificateNotAfter: &clientNotAfter, CANotAfter: &expiringCANotAfter}, now, 90*24*time.Hour)
+
+		// then
+		require.Len(t, conditions, 2)
+		assert.Equal(t, "client certificate expires in 1440h0m0s, CA certificate expires in 240h0m0s (warning window: 2160h0m0s)", conditions[1].Message)
+		assert.True(t, expiringSoon)
+	})
+
+	t.Run("expired certificate", func(t *testing.T) {
+		// when
+		conditions, _, expiringSoon := certificatesConditions(&cluster.Config{ClientCertificateNotAfter: &clientNotAfter, CANotAfter: &expiredCANotAfter}, now, DefaultCertificateExpiryWarningWindow)
+
+		// then
+		assert.Equal(t, []toolchainv1alpha1.Condition{
+			{
+				Type:    ConditionCertificatesValid,
+				Status:  corev1.ConditionFalse,
+				Reason:  ToolchainClusterCertificateExpiredReason,
+				Message: "client certificate expires at 2026-03-02T00:00:00Z, CA certificate expired at 2025-12-31T23:00:00Z",
+			},
+			{
+				Type:    ConditionCertificatesExpiringSoon,
+				Status:  corev1.ConditionTrue,
+				Reason:  ToolchainClusterCertificateExpiringSoonReason,
+				Message: "CA certificate expires in -1h0m0s (warning window: 720h0m0s)",
+			},
+		}, conditions)
+		assert.True(t, expiringSoon)
+	})
+
+	t.Run("invalid certificate", func(t *testing.T) {
+		// when
+		conditions, expiries, _ := certificatesConditions(&cluster.Config{
+			CertificatesError: fmt.Errorf("invalid CA certificate: x509: malformed certificate"),
+		}, now, DefaultCertificateExpiryWarningWindow)
+
+		// then
+		require.Len(t, conditions, 1)
+		assert.Equal(t, ConditionCertificatesValid, conditions[0].Type)
+		assert.Equal(t, corev1.ConditionFalse, conditions[0].Status)
+		assert.Equal(t, ToolchainClusterInvalidCertificateReason, conditions[0].Reason)
+		assert.Equal(t, "invalid CA certificate: x509: malformed certificate", conditions[0].Message)
+		assert.Empty(t, expiries)
+	})
+
+	t.Run("no certificate", func(t *testing.T) {
+		// when
+		conditions, expiries, _ := certificatesConditions(&cluster.Config{}, now, DefaultCertificateExpiryWarningWindow)
+
+		// then
+		assert.Empty(t, conditions)
+		assert.Empty(t, expiries)
+	})
+}
+
+func TestCertificateExpiryMonitoring(t *testing.T) {
+	// given
+	stable, sec := newToolchainCluster(t, "certs", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, stable, sec)
+	reset := setupCachedClusters(t, cl, stable)
+	defer reset()
+	deleteHealthCheckMetrics("certs")
+	defer deleteHealthCheckMetrics("certs")
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	controller.now = func() time.Time { return now }
+	controller.CertificateExpiryWarningWindow = 7 * 24 * time.Hour
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+		return &healthStatus{healthy: true}, nil
+	}
+	clientNotAfter := now.Add(3 * 24 * time.Hour)
+	cachedCluster, found := cluster.GetCachedToolchainCluster("certs")
+	require.True(t, found)
+	cachedCluster.ClientCertificateNotAfter = &clientNotAfter
+
+	// when
+	_, err := controller.Reconcile(context.TODO(), req)
+
+	// then
+	require.NoError(t, err)
+	assertClusterStatus(t, cl, "certs", clusterReadyCondition(),
+		toolchainv1alpha1.Condition{
+			Type:    ConditionCertificatesValid,
+			Status:  corev1.ConditionTrue,
+			Reason:  ToolchainClusterCertificatesValidReason,
+			Message: "client certificate expires at 2026-01-04T00:00:00Z",
+		},
+		toolchainv1alpha1.Condition{
+			Type:    ConditionCertificatesExpiringSoon,
+			Status:  corev1.ConditionTrue,
+			Reason:  ToolchainClusterCertificateExpiringSoonReason,
+			Message: "client certificate expires in 72h0m0s (warning window: 168h0m0s)",
+		})
+	metrics.AssertMetricsGaugeEquals(t, int(now.Add(3*24*time.Hour).Unix()), CertificateExpiryTimestampGauge.WithLabelValues("certs", clientCertificate))
+	metrics.AssertMetricsGaugeEquals(t, 1, CertificateExpiringSoonGauge.WithLabelValues("certs"))
+
+	t.Run("metrics are removed when the certificate is not used anymore", func(t *testing.T) {
+		// given
+		cachedCluster.ClientCertificateNotAfter = nil
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, CertificateExpiryTimestampGauge.DeleteLabelValues("certs", clientCertificate))
+		assert.False(t, CertificateExpiringSoonGauge.DeleteLabelValues("certs"))
+	})
+}
@@ -0,0 +1,143 @@
+package toolchaincluster
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ConditionDegraded is set to True when the latest health checks of the ToolchainCluster disagree with its Ready condition,
+	// but the number of consecutive failed (or successful) checks didn't reach the threshold to change the Ready condition yet
+	ConditionDegraded toolchainv1alpha1.ConditionType = "Degraded"
+
+	// ToolchainClusterHealthCheckFailedReason the cluster is Ready, but the latest health checks failed
+	ToolchainClusterHealthCheckFailedReason = "HealthCheckFailed"
+	// ToolchainClusterRecoveringReason the cluster is not Ready, but the latest health checks succeeded
+	ToolchainClusterRecoveringReason = "Recovering"
+	// ToolchainClusterHealthCheckStableReason the latest health checks agree with the Ready condition
+	ToolchainClusterHealthCheckStableReason = "HealthCheckStable"
+)
+
+// healthHistory keeps the number of the consecutive failed and successful health checks of the ToolchainClusters
+type healthHistory struct {
+	sync.Mutex
+	clusters map[string]*clusterHealthHistory
+}
+
+type clusterHealthHistory struct {
+	consecutiveFailures  int
+	consecutiveSuccesses int
+}
+
+func newHealthHistory() *healthHistory {
+	return &healthHistory{
+		clusters: map[string]*clusterHealthHistory{},
+	}
+}
+
+// record records the result of the health check of the given cluster and returns the updated history
+func (h *healthHistory) record(name string, healthy bool) clusterHealthHistory {
+	h.Lock()
+	defer h.Unlock()
+	history, found := h.clusters[name]
+	if !found {
+		history = &clusterHealthHistory{}
+		h.clusters[name] = history
+	}
+	if healthy {
+		history.consecutiveSuccesses++
+		history.consecutiveFailures = 0
+	} else {
+		history.consecutiveFailures++
+		history.consecutiveSuccesses = 0
+	}
+	return *history
+}
+
+func (h *healthHistory) forget(name string) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.clusters, name)
+}
+
+// dampHealthCondition returns the conditions that should be set in the status of the ToolchainCluster.
+// The Ready condition changes only when the number of the consecutive failed (or successful) checks reaches the FailureThreshold
+// (or the SuccessThreshold). Until then, the Ready condition is kept and the Degraded condition is set instead, so that a single
+// network blip doesn't make the cluster unavailable. It also returns the time after which the cluster should be checked again.
+func (r *Reconciler) dampHealthCondition(toolchainCluster *toolchainv1alpha1.ToolchainCluster, healthCondition toolchainv1alpha1.Condition, history clusterHealthHistory) ([]toolchainv1alpha1.Condition, time.Duration) {
+	healthy := healthCondition.Status == corev1.ConditionTrue
+	requeueAfter := r.requeueAfter(healthCondition, history.consecutiveFailures)
+
+	_, degradedFound := condition.FindConditionByType(toolchainCluster.Status.Conditions, ConditionDegraded)
+	stable := func(conditions ...toolchainv1alpha1.Condition) []toolchainv1alpha1.Condition {
+		if degradedFound {
+			conditions = append(conditions, clusterNotDegradedCondition())
+		}
+		return conditions
+	}
+
+	ready, found := condition.FindConditionByType(toolchainCluster.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	if !found || ready.Status == healthCondition.Status {
+		return stable(healthCondition), requeueAfter
+	}
+	if healthy && history.consecutiveSuccesses < threshold(r.SuccessThreshold) {
+		return []toolchainv1alpha1.Condition{clusterDegradedCondition(ToolchainClusterRecoveringReason,
+			fmt.Sprintf("%s (%d/%d consecutive successful health checks)", healthCondition.Message, history.consecutiveSuccesses, threshold(r.SuccessThreshold)))}, requeueAfter
+	}
+	if !healthy && history.consecutiveFailures < threshold(r.FailureThreshold) {
+		return []toolchainv1alpha1.Condition{clusterDegradedCondition(ToolchainClusterHealthCheckFailedReason,
+			fmt.Sprintf("%s (%d/%d consecutive failed health checks)", healthCondition.Message, history.consecutiveFailures, threshold(r.FailureThreshold)))}, requeueAfter
+	}
+	return stable(healthCondition), requeueAfter
+}
+
+// requeueAfter returns the RequeAfter, or the exponential backoff (capped to the MaxRequeueAfter) if the cluster is not reachable
+func (r *Reconciler) requeueAfter(healthCondition toolchainv1alpha1.Condition, consecutiveFailures int) time.Duration {
+	if r.MaxRequeueAfter <= r.RequeAfter || healthCondition.Reason != toolchainv1alpha1.ToolchainClusterClusterNotReachableReason {
+		return r.RequeAfter
+	}
+	requeueAfter := r.RequeAfter
+	for i := 1; i < consecutiveFailures && requeueAfter < r.MaxRequeueAfter; i++ {
+		requeueAfter *= 2
+	}
+	if requeueAfter > r.MaxRequeueAfter {
+		return r.MaxRequeueAfter
+	}
+	return requeueAfter
+}
+
+func (r *Reconciler) getHealthHistory() *healthHistory {
+	r.historyOnce.Do(func() {
+		r.history = newHealthHistory()
+	})
+	return r.history
+}
+
+func threshold(value int) int {
+	if value < 1 {
+		return 1
+	}
+	return value
+}
+
+func clusterDegradedCondition(reason, msg string) toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:    ConditionDegraded,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: msg,
+	}
+}
+
+func clusterNotDegradedCondition() toolchainv1alpha1.Condition {
+	return toolchainv1alpha1.Condition{
+		Type:   ConditionDegraded,
+		Status: corev1.ConditionFalse,
+		Reason: ToolchainClusterHealthCheckStableReason,
+	}
+}
@@ -0,0 +1,253 @@
+package toolchaincluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestFlapDamping(t *testing.T) {
+	healthy := func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+		return &healthStatus{healthy: true}, nil
+	}
+	unhealthy := func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+		return &healthStatus{healthy: false, failedChecks: []string{"etcd"}}, nil
+	}
+	unreachable := func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	t.Run("ready cluster becomes not ready after the failure threshold", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.FailureThreshold = 3
+		controller.checkHealth = healthy
+		_, err := controller.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition())
+		controller.checkHealth = unhealthy
+
+		for i := 1; i < 3; i++ {
+			// when
+			_, err := controller.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assertClusterStatus(t, cl, "stable", clusterReadyCondition(), toolchainv1alpha1.Condition{
+				Type:    ConditionDegraded,
+				Status:  corev1.ConditionTrue,
+				Reason:  ToolchainClusterHealthCheckFailedReason,
+				Message: fmt.Sprintf("/readyz responded without ok, failed checks: etcd (%d/3 consecutive failed health checks)", i),
+			})
+		}
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterNotReadyCondition([]string{"etcd"}), clusterNotDegradedCondition())
+	})
+
+	t.Run("single failure doesn't change the ready condition", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.FailureThreshold = 2
+		controller.checkHealth = healthy
+		_, err := controller.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		controller.checkHealth = unreachable
+		_, err = controller.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		controller.checkHealth = healthy
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition(), clusterNotDegradedCondition(), toolchainv1alpha1.Condition{
+			Type:   ConditionConnectionError,
+			Status: corev1.ConditionFalse,
+			Reason: ToolchainClusterResolvedReason,
+		})
+	})
+
+	t.Run("not ready cluster becomes ready after the success threshold", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.SuccessThreshold = 2
+		controller.checkHealth = unhealthy
+		_, err := controller.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterNotReadyCondition([]string{"etcd"}))
+		controller.checkHealth = healthy
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterNotReadyCondition([]string{"etcd"}), toolchainv1alpha1.Condition{
+			Type:    ConditionDegraded,
+			Status:  corev1.ConditionTrue,
+			Reason:  ToolchainClusterRecoveringReason,
+			Message: "/readyz responded with ok (1/2 consecutive successful health checks)",
+		})
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition(), clusterNotDegradedCondition())
+	})
+
+	t.Run("history is forgotten when the cluster is deleted", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.checkHealth = unhealthy
+		_, err := controller.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		require.NoError(t, cl.Delete(context.TODO(), stable))
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.NotContains(t, controller.history.clusters, "stable")
+	})
+
+	t.Run("history is shared by the concurrent reconciles", func(t *testing.T) {
+		// given
+		controller := &Reconciler{}
+		histories := make([]*healthHistory, 10)
+
+		// when
+		var wg sync.WaitGroup
+		for i := range histories {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				histories[i] = controller.getHealthHistory()
+			}(i)
+		}
+		wg.Wait()
+
+		// then
+		for _, history := range histories {
+			assert.Same(t, histories[0], history)
+		}
+	})
+}
+
+func TestHealthCheckBackoff(t *testing.T) {
+	unreachable := func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	t.Run("requeue time is doubled up to the max for unreachable cluster", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.MaxRequeueAfter = time.Minute
+		controller.checkHealth = unreachable
+
+		for _, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute} {
+			// when
+			result, err := controller.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, reconcile.Result{RequeueAfter: expected}, result)
+		}
+		assertClusterStatus(t, cl, "stable", clusterOfflineCondition("connection refused"), toolchainv1alpha1.Condition{
+			Type:    ConditionConnectionError,
+			Status:  corev1.ConditionTrue,
+			Reason:  cluster.FailureReasonUnknown,
+			Message: "connection refused",
+		})
+
+		// when
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			return &healthStatus{healthy: true}, nil
+		}
+		result, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, result)
+	})
+
+	t.Run("no backoff when max requeue time is not set", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.checkHealth = unreachable
+
+		for i := 0; i < 3; i++ {
+			// when
+			result, err := controller.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, result)
+		}
+	})
+
+	t.Run("no backoff when cluster is reachable but not ready", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.MaxRequeueAfter = time.Minute
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			return &healthStatus{healthy: false}, nil
+		}
+
+		for i := 0; i < 3; i++ {
+			// when
+			result, err := controller.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, result)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package toolchaincluster
+
+import (
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ConditionCredentialsError is set to True when the config of the ToolchainCluster can't be loaded from its secret.
+	// The reason is cluster.FailureReasonCredentialsMissing or cluster.FailureReasonInvalidConfig.
+	ConditionCredentialsError toolchainv1alpha1.ConditionType = "CredentialsError"
+	// ConditionConnectionError is set to True when the health check can't connect to the cluster. The reason is one of
+	// cluster.FailureReasonTLSError, cluster.FailureReasonDNSError, cluster.FailureReasonUnauthorized, cluster.FailureReasonForbidden,
+	// cluster.FailureReasonTimeout or cluster.FailureReasonUnknown.
+	ConditionConnectionError toolchainv1alpha1.ConditionType = "ConnectionError"
+
+	// ToolchainClusterResolvedReason the failure reported by the CredentialsError or ConnectionError condition is gone
+	ToolchainClusterResolvedReason = "Resolved"
+)
+
+// failureCondition returns the condition of the given type set to True with the classified reason of the given error.
+// If there is no error, then the condition is set to False, but only if it's already present in the status, so that
+// the conditions are not added to the ToolchainClusters which never had a problem.
+func failureCondition(toolchainCluster *toolchainv1alpha1.ToolchainCluster, conditionType toolchainv1alpha1.ConditionType, err error) []toolchainv1alpha1.Condition {
+	if err != nil {
+		return []toolchainv1alpha1.Condition{{
+			Type:    conditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  cluster.FailureReason(err),
+			Message: err.Error(),
+		}}
+	}
+	if _, found := condition.FindConditionByType(toolchainCluster.Status.Conditions, conditionType); found {
+		return []toolchainv1alpha1.Condition{{
+			Type:   conditionType,
+			Status: corev1.ConditionFalse,
+			Reason: ToolchainClusterResolvedReason,
+		}}
+	}
+	return nil
+}
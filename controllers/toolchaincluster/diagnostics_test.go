@@ -0,0 +1,100 @@
+package toolchaincluster
+
+import (
+	"context"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeclientset "k8s.io/client-go/kubernetes"
+)
+
+func TestConnectionDiagnostics(t *testing.T) {
+	// given
+	stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, stable, sec)
+	reset := setupCachedClusters(t, cl, stable)
+	defer reset()
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+
+	t.Run("connection error is classified", func(t *testing.T) {
+		// given
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			return nil, apierrors.NewUnauthorized("invalid token")
+		}
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterOfflineCondition("invalid token"), toolchainv1alpha1.Condition{
+			Type:    ConditionConnectionError,
+			Status:  corev1.ConditionTrue,
+			Reason:  cluster.FailureReasonUnauthorized,
+			Message: "invalid token",
+		})
+	})
+
+	t.Run("connection error is resolved", func(t *testing.T) {
+		// given
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			return &healthStatus{healthy: true}, nil
+		}
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition(), toolchainv1alpha1.Condition{
+			Type:   ConditionConnectionError,
+			Status: corev1.ConditionFalse,
+			Reason: ToolchainClusterResolvedReason,
+		})
+	})
+}
+
+func TestCredentialsDiagnostics(t *testing.T) {
+	// given
+	stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, stable)
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+		return &healthStatus{healthy: true}, nil
+	}
+
+	// when
+	_, err := controller.Reconcile(context.TODO(), req)
+
+	// then
+	require.EqualError(t, err, "cluster stable not found in cache")
+	assertClusterStatus(t, cl, "stable", clusterOfflineCondition("cluster stable not found in cache"), toolchainv1alpha1.Condition{
+		Type:    ConditionCredentialsError,
+		Status:  corev1.ConditionTrue,
+		Reason:  cluster.FailureReasonCredentialsMissing,
+		Message: `unable to get secret test-namespace/secret for cluster stable: secrets "secret" not found`,
+	})
+
+	t.Run("credentials error is resolved when the cluster is added in the cache", func(t *testing.T) {
+		// given
+		require.NoError(t, cl.Create(context.TODO(), sec))
+		reset := setupCachedClusters(t, cl, stable)
+		defer reset()
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assertClusterStatus(t, cl, "stable", clusterReadyCondition(), toolchainv1alpha1.Condition{
+			Type:   ConditionCredentialsError,
+			Status: corev1.ConditionFalse,
+			Reason: ToolchainClusterResolvedReason,
+		})
+	})
+}
@@ -9,17 +9,55 @@ import (
 )
 
 const (
-	healthzOk    = "/healthz responded with ok"
-	healthzNotOk = "/healthz responded without ok"
+	readyzOk    = "/readyz responded with ok"
+	readyzNotOk = "/readyz responded without ok"
 )
 
-// getClusterHealth gets the kubernetes cluster health status by requesting "/healthz"
-func getClusterHealthStatus(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (bool, error) {
+// healthStatus is the result of the health check of a ToolchainCluster
+type healthStatus struct {
+	// healthy is true if all the checks of the cluster passed
+	healthy bool
+	// failedChecks contains the names of the individual checks that failed (eg. `etcd` or `poststarthook/rbac/bootstrap-roles`)
+	failedChecks []string
+}
+
+// getClusterHealthStatus gets the kubernetes cluster health status by requesting "/readyz?verbose".
+// The verbose output lists the result of every individual check, one per line (eg. `[+]ping ok` or `[-]etcd failed: reason withheld`),
+// so that the failing checks can be reported. If the server doesn't list the checks, then the whole body is compared to "ok".
+func getClusterHealthStatus(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (*healthStatus, error) {
 	lgr := log.FromContext(ctx)
-	body, err := remoteClusterClientset.DiscoveryClient.RESTClient().Get().AbsPath("/healthz").Do(ctx).Raw()
+	body, err := remoteClusterClientset.DiscoveryClient.RESTClient().Get().AbsPath("/readyz").Param("verbose", "").Do(ctx).Raw()
+	// the server responds with the 500 status code when some checks failed, but the body still contains the individual results
+	if failedChecks, found := parseReadyzChecks(body); found {
+		return &healthStatus{
+			healthy:      len(failedChecks) == 0,
+			failedChecks: failedChecks,
+		}, nil
+	}
 	if err != nil {
 		lgr.Error(err, "Failed to do cluster health check for a ToolchainCluster")
-		return false, err
+		return nil, err
+	}
+	return &healthStatus{
+		healthy: strings.EqualFold(strings.TrimSpace(string(body)), "ok"),
+	}, nil
+}
+
+// parseReadyzChecks returns the names of the failed checks listed in the verbose output of the "/readyz" endpoint.
+// It returns `false` if the output doesn't contain any check.
+func parseReadyzChecks(body []byte) ([]string, bool) {
+	var failedChecks []string
+	found := false
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "[+]"):
+			found = true
+		case strings.HasPrefix(line, "[-]"):
+			found = true
+			name, _, _ := strings.Cut(strings.TrimPrefix(line, "[-]"), " ")
+			failedChecks = append(failedChecks, name)
+		}
 	}
-	return strings.EqualFold(string(body), "ok"), nil
+	return failedChecks, found
 }
@@ -17,25 +17,36 @@ func TestClusterHealthChecks(t *testing.T) {
 	defer gock.Off()
 	tcNs := "test-namespace"
 	gock.New("https://cluster.com").
-		Get("healthz").
+		Get("readyz").
 		Persist().
 		Reply(200).
 		BodyString("ok")
 	gock.New("https://unstable.com").
-		Get("healthz").
+		Get("readyz").
 		Persist().
 		Reply(200).
 		BodyString("unstable")
+	gock.New("https://verbose.com").
+		Get("readyz").
+		Persist().
+		Reply(200).
+		BodyString("[+]ping ok\n[+]log ok\n[+]etcd ok\nreadyz check passed\n")
+	gock.New("https://failing.com").
+		Get("readyz").
+		Persist().
+		Reply(500).
+		BodyString("[+]ping ok\n[-]etcd failed: reason withheld\n[+]log ok\n[-]informer-sync failed: reason withheld\nreadyz check failed\n")
 	gock.New("https://not-found.com").
-		Get("healthz").
+		Get("readyz").
 		Persist().
 		Reply(404)
 
 	tests := map[string]struct {
-		tcType      string
-		apiEndPoint string
-		healthCheck bool
-		err         error
+		tcType       string
+		apiEndPoint  string
+		healthCheck  bool
+		failedChecks []string
+		err          error
 	}{
 		"HealthOkay": {
 			tcType:      "stable",
@@ -47,6 +58,17 @@ func TestClusterHealthChecks(t *testing.T) {
 			apiEndPoint: "https://unstable.com",
 			healthCheck: false,
 		},
+		"VerboseHealthOkay": {
+			tcType:      "verbose",
+			apiEndPoint: "https://verbose.com",
+			healthCheck: true,
+		},
+		"VerboseHealthWithFailedChecks": {
+			tcType:       "failing",
+			apiEndPoint:  "https://failing.com",
+			healthCheck:  false,
+			failedChecks: []string{"etcd", "informer-sync"},
+		},
 		"ErrorWhileDoingHealth": {
 			tcType:      "Notfound",
 			apiEndPoint: "https://not-found.com",
@@ -67,14 +89,16 @@ func TestClusterHealthChecks(t *testing.T) {
 			require.NoError(t, err)
 
 			// when
-			healthCheck, err := getClusterHealthStatus(context.TODO(), cacheClient)
+			healthStatus, err := getClusterHealthStatus(context.TODO(), cacheClient)
 
 			// then
-			require.Equal(t, tc.healthCheck, healthCheck)
 			if tc.err != nil {
 				require.EqualError(t, err, tc.err.Error())
+				require.Nil(t, healthStatus)
 			} else {
 				require.NoError(t, err)
+				require.Equal(t, tc.healthCheck, healthStatus.healthy)
+				require.Equal(t, tc.failedChecks, healthStatus.failedChecks)
 			}
 		})
 	}
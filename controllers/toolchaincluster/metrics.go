@@ -0,0 +1,121 @@
+package toolchaincluster
+
+import (
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	clusterNameLabel = "cluster_name"
+	outcomeLabel     = "outcome"
+	certificateLabel = "certificate"
+
+	// HealthCheckOutcomeReady all the checks of the cluster passed
+	HealthCheckOutcomeReady = "ready"
+	// HealthCheckOutcomeNotReady the cluster responded, but some of its checks failed
+	HealthCheckOutcomeNotReady = "not-ready"
+	// HealthCheckOutcomeUnreachable the cluster didn't respond
+	HealthCheckOutcomeUnreachable = "unreachable"
+)
+
+var (
+	// HealthCheckDuration the duration of the health checks of the ToolchainClusters
+	HealthCheckDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "toolchaincluster_health_check_duration_seconds",
+		Help:    "Duration of the health checks of the ToolchainClusters",
+		Buckets: prometheus.DefBuckets,
+	}, []string{clusterNameLabel})
+	// HealthChecksTotal the number of the health checks of the ToolchainClusters per outcome (`ready`, `not-ready` or `unreachable`)
+	HealthChecksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "toolchaincluster_health_checks_total",
+		Help: "Number of the health checks of the ToolchainClusters per outcome",
+	}, []string{clusterNameLabel, outcomeLabel})
+	// ReadyGauge is 1 if the ToolchainCluster is Ready, 0 otherwise
+	ReadyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "toolchaincluster_ready",
+		Help: "Whether the ToolchainCluster is Ready (1) or not (0)",
+	}, []string{clusterNameLabel})
+	// LastSuccessfulHealthCheckTimestampGauge the time (Unix time) of the last successful health check of the ToolchainCluster.
+	// The time since the last successful health check is computed in the queries, eg. `time() - toolchaincluster_last_successful_health_check_timestamp_seconds`
+	LastSuccessfulHealthCheckTimestampGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "toolchaincluster_last_successful_health_check_timestamp_seconds",
+		Help: "Time of the last successful health check of the ToolchainCluster as Unix time",
+	}, []string{clusterNameLabel})
+	// CertificateExpiryTimestampGauge the expiry (Unix time) of the client certificate (`client`) and of the CA certificates (`ca`) of the ToolchainCluster
+	CertificateExpiryTimestampGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "toolchaincluster_certificate_expiry_timestamp_seconds",
+		Help: "Expiry of the client certificate and of the CA certificates of the ToolchainCluster as Unix time",
+	}, []string{clusterNameLabel, certificateLabel})
+	// CertificateExpiringSoonGauge is 1 if a certificate of the ToolchainCluster expires within the warning window, 0 otherwise
+	CertificateExpiringSoonGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "toolchaincluster_certificate_expiring_soon",
+		Help: "Whether a certificate of the ToolchainCluster expires within the warning window (1) or not (0)",
+	}, []string{clusterNameLabel})
+)
+
+func init() {
+	metrics.Registry.MustRegister(HealthCheckDuration, HealthChecksTotal, ReadyGauge, LastSuccessfulHealthCheckTimestampGauge,
+		CertificateExpiryTimestampGauge, CertificateExpiringSoonGauge)
+}
+
+// recordHealthCheckMetrics records the result of the health check of the given ToolchainCluster
+func recordHealthCheckMetrics(toolchainCluster *toolchainv1alpha1.ToolchainCluster, healthCondition toolchainv1alpha1.Condition, latency time.Duration) {
+	name := toolchainCluster.Name
+	outcome := healthCheckOutcome(healthCondition)
+	HealthCheckDuration.WithLabelValues(name).Observe(latency.Seconds())
+	HealthChecksTotal.WithLabelValues(name, outcome).Inc()
+	if cluster.IsReady(&toolchainCluster.Status) {
+		ReadyGauge.WithLabelValues(name).Set(1)
+	} else {
+		ReadyGauge.WithLabelValues(name).Set(0)
+	}
+	if outcome == HealthCheckOutcomeReady {
+		LastSuccessfulHealthCheckTimestampGauge.WithLabelValues(name).SetToCurrentTime()
+	}
+}
+
+// recordCertificateMetrics records the expiries of the certificates of the given ToolchainCluster.
+// The metrics of the certificates which are not used anymore are removed.
+func recordCertificateMetrics(name string, expiries []certificateExpiry, expiringSoon bool) {
+	labels := prometheus.Labels{clusterNameLabel: name}
+	CertificateExpiryTimestampGauge.DeletePartialMatch(labels)
+	CertificateExpiringSoonGauge.DeletePartialMatch(labels)
+	if len(expiries) == 0 {
+		return
+	}
+	for _, expiry := range expiries {
+		CertificateExpiryTimestampGauge.WithLabelValues(name, expiry.certificate).Set(float64(expiry.notAfter.Unix()))
+	}
+	if expiringSoon {
+		CertificateExpiringSoonGauge.WithLabelValues(name).Set(1)
+	} else {
+		CertificateExpiringSoonGauge.WithLabelValues(name).Set(0)
+	}
+}
+
+// deleteHealthCheckMetrics removes the metrics of the deleted ToolchainCluster
+func deleteHealthCheckMetrics(name string) {
+	labels := prometheus.Labels{clusterNameLabel: name}
+	HealthCheckDuration.DeletePartialMatch(labels)
+	HealthChecksTotal.DeletePartialMatch(labels)
+	ReadyGauge.DeletePartialMatch(labels)
+	LastSuccessfulHealthCheckTimestampGauge.DeletePartialMatch(labels)
+	CertificateExpiryTimestampGauge.DeletePartialMatch(labels)
+	CertificateExpiringSoonGauge.DeletePartialMatch(labels)
+}
+
+func healthCheckOutcome(healthCondition toolchainv1alpha1.Condition) string {
+	switch {
+	case healthCondition.Status == corev1.ConditionTrue:
+		return HealthCheckOutcomeReady
+	case healthCondition.Reason == toolchainv1alpha1.ToolchainClusterClusterNotReachableReason:
+		return HealthCheckOutcomeUnreachable
+	default:
+		return HealthCheckOutcomeNotReady
+	}
+}
@@ -0,0 +1,125 @@
+package toolchaincluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kubeclientset "k8s.io/client-go/kubernetes"
+)
+
+func TestHealthCheckMetrics(t *testing.T) {
+	// given
+	tc, sec := newToolchainCluster(t, "metrics", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, tc, sec)
+	reset := setupCachedClusters(t, cl, tc)
+	defer reset()
+	deleteHealthCheckMetrics("metrics")
+	defer deleteHealthCheckMetrics("metrics")
+	controller, req := prepareReconcile(tc, cl, requeAfter)
+	now := time.Now()
+	controller.now = func() time.Time {
+		return now
+	}
+	probe := func(healthy bool, err error) func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+		return func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			now = now.Add(30 * time.Second)
+			if err != nil {
+				return nil, err
+			}
+			return &healthStatus{healthy: healthy}, nil
+		}
+	}
+
+	var lastSuccess float64
+
+	t.Run("successful health check", func(t *testing.T) {
+		// given
+		controller.checkHealth = probe(true, nil)
+		before := time.Now()
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		metrics.AssertHistogramSampleCountEquals(t, 1, HealthCheckDuration.WithLabelValues("metrics").(prometheus.Histogram))
+		metrics.AssertHistogramBucketEquals(t, 0, 10, HealthCheckDuration.WithLabelValues("metrics").(prometheus.Histogram))
+		metrics.AssertCounterEqualsInt(t, 1, HealthChecksTotal.WithLabelValues("metrics", HealthCheckOutcomeReady))
+		metrics.AssertMetricsGaugeEquals(t, 1, ReadyGauge.WithLabelValues("metrics"))
+		lastSuccess = promtestutil.ToFloat64(LastSuccessfulHealthCheckTimestampGauge.WithLabelValues("metrics"))
+		assert.GreaterOrEqual(t, lastSuccess, float64(before.Unix()))
+		assert.LessOrEqual(t, lastSuccess, float64(time.Now().Unix()+1))
+	})
+
+	t.Run("failed health checks", func(t *testing.T) {
+		// given
+		controller.checkHealth = probe(false, nil)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		metrics.AssertCounterEqualsInt(t, 1, HealthChecksTotal.WithLabelValues("metrics", HealthCheckOutcomeNotReady))
+		metrics.AssertMetricsGaugeEquals(t, 0, ReadyGauge.WithLabelValues("metrics"))
+		// the time of the last successful health check is kept
+		assert.InDelta(t, lastSuccess, promtestutil.ToFloat64(LastSuccessfulHealthCheckTimestampGauge.WithLabelValues("metrics")), 0)
+
+		// given
+		controller.checkHealth = probe(false, fmt.Errorf("connection refused"))
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		metrics.AssertHistogramSampleCountEquals(t, 3, HealthCheckDuration.WithLabelValues("metrics").(prometheus.Histogram))
+		metrics.AssertCounterEqualsInt(t, 1, HealthChecksTotal.WithLabelValues("metrics", HealthCheckOutcomeUnreachable))
+		metrics.AssertCounterEqualsInt(t, 1, HealthChecksTotal.WithLabelValues("metrics", HealthCheckOutcomeReady))
+		metrics.AssertMetricsGaugeEquals(t, 0, ReadyGauge.WithLabelValues("metrics"))
+		assert.InDelta(t, lastSuccess, promtestutil.ToFloat64(LastSuccessfulHealthCheckTimestampGauge.WithLabelValues("metrics")), 0)
+	})
+
+	t.Run("ready gauge follows the damped ready condition", func(t *testing.T) {
+		// given
+		controller.SuccessThreshold = 2
+		controller.checkHealth = probe(true, nil)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		metrics.AssertMetricsGaugeEquals(t, 0, ReadyGauge.WithLabelValues("metrics"))
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		metrics.AssertMetricsGaugeEquals(t, 1, ReadyGauge.WithLabelValues("metrics"))
+	})
+
+	t.Run("metrics are removed when the cluster is deleted", func(t *testing.T) {
+		// given
+		require.NoError(t, cl.Delete(context.TODO(), tc))
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, ReadyGauge.DeleteLabelValues("metrics"))
+		assert.False(t, HealthChecksTotal.DeleteLabelValues("metrics", HealthCheckOutcomeReady))
+		assert.False(t, HealthCheckDuration.DeleteLabelValues("metrics"))
+		assert.False(t, LastSuccessfulHealthCheckTimestampGauge.DeleteLabelValues("metrics"))
+	})
+}
@@ -3,6 +3,8 @@ package toolchaincluster
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -20,10 +22,25 @@ import (
 
 // Reconciler reconciles a ToolchainCluster object
 type Reconciler struct {
-	Client      client.Client
-	Scheme      *runtime.Scheme
-	RequeAfter  time.Duration
-	checkHealth func(context.Context, *kubeclientset.Clientset) (bool, error)
+	Client     client.Client
+	Scheme     *runtime.Scheme
+	RequeAfter time.Duration
+	// MaxRequeueAfter enables the exponential backoff of the health checks of the unreachable clusters: the RequeAfter is doubled
+	// after every consecutive failed check, up to the MaxRequeueAfter. The backoff is disabled if it's not greater than the RequeAfter.
+	MaxRequeueAfter time.Duration
+	// FailureThreshold is the number of consecutive failed health checks after which a Ready cluster becomes not Ready (default: 1)
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successful health checks after which a not Ready cluster becomes Ready (default: 1)
+	SuccessThreshold int
+	// CertificateExpiryWarningWindow is the time before the expiry of the client certificate or of the CA certificates of the cluster
+	// when the CertificatesExpiringSoon condition is set to True (default: DefaultCertificateExpiryWarningWindow)
+	CertificateExpiryWarningWindow time.Duration
+
+	checkHealth func(context.Context, *kubeclientset.Clientset) (*healthStatus, error)
+	now         func() time.Time
+	// history is initialized once, because the reconciles of the different clusters may run concurrently
+	historyOnce sync.Once
+	history     *healthHistory
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -48,6 +65,8 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	if err != nil {
 		if kerrors.IsNotFound(err) {
 			// Stop monitoring the toolchain cluster as it is deleted
+			r.getHealthHistory().forget(request.Name)
+			deleteHealthCheckMetrics(request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -57,7 +76,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	cachedCluster, ok := cluster.GetCachedToolchainCluster(toolchainCluster.Name)
 	if !ok {
 		err := fmt.Errorf("cluster %s not found in cache", toolchainCluster.Name)
-		if err := r.updateStatus(ctx, toolchainCluster, nil, clusterOfflineCondition(err.Error())); err != nil {
+		// diagnose why the cluster is not in the cache
+		_, configErr := cluster.CheckClusterConfig(r.Client, toolchainCluster)
+		conditions := append([]toolchainv1alpha1.Condition{clusterOfflineCondition(err.Error())},
+			failureCondition(toolchainCluster, ConditionCredentialsError, configErr)...)
+		if err := r.updateStatus(ctx, toolchainCluster, nil, conditions...); err != nil {
 			reqLogger.Error(err, "unable to update cluster status of ToolchainCluster")
 		}
 		return reconcile.Result{}, err
@@ -73,14 +96,24 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	}
 
 	// execute healthcheck
-	healthCheckResult := r.getClusterHealthCondition(ctx, clientSet)
+	healthCheckResult, latency, connectionErr := r.getClusterHealthCondition(ctx, clientSet)
+	now := r.currentTime()
+	history := r.getHealthHistory().record(toolchainCluster.Name, healthCheckResult.Status == corev1.ConditionTrue)
+	conditions, requeueAfter := r.dampHealthCondition(toolchainCluster, healthCheckResult, history)
+	conditions = append(conditions, failureCondition(toolchainCluster, ConditionCredentialsError, nil)...)
+	conditions = append(conditions, failureCondition(toolchainCluster, ConditionConnectionError, connectionErr)...)
+	certificates, expiries, expiringSoon := certificatesConditions(cachedCluster.Config, now, r.certificateExpiryWarningWindow())
+	conditions = append(conditions, certificates...)
 
 	// update the status of the individual cluster.
-	if err := r.updateStatus(ctx, toolchainCluster, cachedCluster, healthCheckResult); err != nil {
+	err = r.updateStatus(ctx, toolchainCluster, cachedCluster, conditions...)
+	recordHealthCheckMetrics(toolchainCluster, healthCheckResult, latency)
+	recordCertificateMetrics(toolchainCluster.Name, expiries, expiringSoon)
+	if err != nil {
 		reqLogger.Error(err, "unable to update cluster status of ToolchainCluster")
 		return reconcile.Result{}, err
 	}
-	return reconcile.Result{RequeueAfter: r.RequeAfter}, nil
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *Reconciler) updateStatus(ctx context.Context, toolchainCluster *toolchainv1alpha1.ToolchainCluster, cachedToolchainCluster *cluster.CachedToolchainCluster, currentConditions ...toolchainv1alpha1.Condition) error {
@@ -100,18 +133,29 @@ func (r *Reconciler) updateStatus(ctx context.Context, toolchainCluster *toolcha
 	return nil
 }
 
-func (r *Reconciler) getClusterHealthCondition(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) toolchainv1alpha1.Condition {
-	isHealthy, err := r.getClusterHealth(ctx, remoteClusterClientset)
+// getClusterHealthCondition checks the health of the cluster and returns the corresponding Ready condition together with the latency of the check,
+// and the error if the cluster could not be reached
+func (r *Reconciler) getClusterHealthCondition(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (toolchainv1alpha1.Condition, time.Duration, error) {
+	start := r.currentTime()
+	status, err := r.getClusterHealth(ctx, remoteClusterClientset)
+	latency := r.currentTime().Sub(start)
 	if err != nil {
-		return clusterOfflineCondition(err.Error())
+		return clusterOfflineCondition(err.Error()), latency, err
 	}
-	if !isHealthy {
-		return clusterNotReadyCondition()
+	if !status.healthy {
+		return clusterNotReadyCondition(status.failedChecks), latency, nil
 	}
-	return clusterReadyCondition()
+	return clusterReadyCondition(), latency, nil
 }
 
-func (r *Reconciler) getClusterHealth(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (bool, error) {
+func (r *Reconciler) currentTime() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+func (r *Reconciler) getClusterHealth(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (*healthStatus, error) {
 	if r.checkHealth != nil {
 		return r.checkHealth(ctx, remoteClusterClientset)
 	}
@@ -127,20 +171,27 @@ func clusterOfflineCondition(errMsg string) toolchainv1alpha1.Condition {
 	}
 }
 
+// clusterReadyCondition returns the Ready condition. The message doesn't change between the health checks, so that the status
+// is not updated on every check (the latency of the checks is available in the HealthCheckDuration metric).
 func clusterReadyCondition() toolchainv1alpha1.Condition {
 	return toolchainv1alpha1.Condition{
 		Type:    toolchainv1alpha1.ConditionReady,
 		Status:  corev1.ConditionTrue,
 		Reason:  toolchainv1alpha1.ToolchainClusterClusterReadyReason,
-		Message: healthzOk,
+		Message: readyzOk,
 	}
 }
 
-func clusterNotReadyCondition() toolchainv1alpha1.Condition {
+// clusterNotReadyCondition returns the not-Ready condition with the names of the failed checks in the message
+func clusterNotReadyCondition(failedChecks []string) toolchainv1alpha1.Condition {
+	msg := readyzNotOk
+	if len(failedChecks) > 0 {
+		msg = fmt.Sprintf("%s, failed checks: %s", readyzNotOk, strings.Join(failedChecks, ", "))
+	}
 	return toolchainv1alpha1.Condition{
 		Type:    toolchainv1alpha1.ConditionReady,
 		Status:  corev1.ConditionFalse,
 		Reason:  toolchainv1alpha1.ToolchainClusterClusterNotReadyReason,
-		Message: healthzNotOk,
+		Message: msg,
 	}
 }
@@ -9,6 +9,8 @@ import (
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/h2non/gock.v1"
 	corev1 "k8s.io/api/core/v1"
@@ -28,17 +30,17 @@ func TestClusterControllerChecks(t *testing.T) {
 	defer gock.Off()
 	tcNs := "test-namespace"
 	gock.New("https://cluster.com").
-		Get("healthz").
+		Get("readyz").
 		Persist().
 		Reply(200).
 		BodyString("ok")
 	gock.New("https://unstable.com").
-		Get("healthz").
+		Get("readyz").
 		Persist().
 		Reply(200).
 		BodyString("unstable")
 	gock.New("https://not-found.com").
-		Get("healthz").
+		Get("readyz").
 		Persist().
 		Reply(404)
 
@@ -111,7 +113,12 @@ func TestClusterControllerChecks(t *testing.T) {
 
 		// then
 		require.EqualError(t, err, "cluster unstable not found in cache")
-		assertClusterStatus(t, cl, "unstable", clusterOfflineCondition("cluster unstable not found in cache"))
+		assertClusterStatus(t, cl, "unstable", clusterOfflineCondition("cluster unstable not found in cache"), toolchainv1alpha1.Condition{
+			Type:    ConditionCredentialsError,
+			Status:  corev1.ConditionTrue,
+			Reason:  cluster.FailureReasonCredentialsMissing,
+			Message: `unable to get secret test-namespace/secret for cluster unstable: secrets "secret" not found`,
+		})
 	})
 
 	t.Run("error while updating a toolchain cluster status on cache not found", func(t *testing.T) {
@@ -146,8 +153,8 @@ func TestClusterControllerChecks(t *testing.T) {
 
 		defer reset()
 		controller, req := prepareReconcile(stable, cl, requeAfter)
-		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
-			return false, expectedErr
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			return nil, expectedErr
 		}
 		// when
 		recResult, err := controller.Reconcile(context.TODO(), req)
@@ -169,8 +176,8 @@ func TestGetClusterHealth(t *testing.T) {
 
 		defer reset()
 		controller, req := prepareReconcile(stable, cl, requeAfter)
-		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
-			return true, nil
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			return &healthStatus{healthy: true}, nil
 		}
 
 		// when
@@ -190,8 +197,8 @@ func TestGetClusterHealth(t *testing.T) {
 
 		defer reset()
 		controller, req := prepareReconcile(stable, cl, requeAfter)
-		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
-			return false, nil
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			return &healthStatus{healthy: false}, nil
 		}
 
 		// when
@@ -200,7 +207,43 @@ func TestGetClusterHealth(t *testing.T) {
 		// then
 		require.NoError(t, err)
 		require.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, recResult)
-		assertClusterStatus(t, cl, "stable", clusterNotReadyCondition())
+		assertClusterStatus(t, cl, "stable", clusterNotReadyCondition(nil))
+	})
+	t.Run("get health condition with failed checks and latency", func(t *testing.T) {
+		// given
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+
+		defer reset()
+		deleteHealthCheckMetrics("stable")
+		defer deleteHealthCheckMetrics("stable")
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		now := time.Now()
+		controller.now = func() time.Time {
+			return now
+		}
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (*healthStatus, error) {
+			now = now.Add(1234 * time.Microsecond)
+			return &healthStatus{healthy: false, failedChecks: []string{"etcd", "informer-sync"}}, nil
+		}
+
+		// when
+		recResult, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, recResult)
+		assertClusterStatus(t, cl, "stable", toolchainv1alpha1.Condition{
+			Type:    toolchainv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  toolchainv1alpha1.ToolchainClusterClusterNotReadyReason,
+			Message: "/readyz responded without ok, failed checks: etcd, informer-sync",
+		})
+		// the latency is only reported in the metrics
+		metrics.AssertHistogramSampleCountEquals(t, 1, HealthCheckDuration.WithLabelValues("stable").(prometheus.Histogram))
+		metrics.AssertHistogramBucketEquals(t, 1, 0.005, HealthCheckDuration.WithLabelValues("stable").(prometheus.Histogram))
 	})
 }
 
@@ -229,8 +272,8 @@ func newToolchainCluster(t *testing.T, name, tcNs string, apiEndpoint string) (*
 	return toolchainCluster, secret
 }
 
-func prepareReconcile(toolchainCluster *toolchainv1alpha1.ToolchainCluster, cl *test.FakeClient, requeAfter time.Duration) (Reconciler, reconcile.Request) {
-	controller := Reconciler{
+func prepareReconcile(toolchainCluster *toolchainv1alpha1.ToolchainCluster, cl *test.FakeClient, requeAfter time.Duration) (*Reconciler, reconcile.Request) {
+	controller := &Reconciler{
 		Client:     cl,
 		Scheme:     scheme.Scheme,
 		RequeAfter: requeAfter,
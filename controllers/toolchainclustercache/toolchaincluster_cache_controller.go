@@ -16,10 +16,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-// NewReconciler returns a new Reconciler
-func NewReconciler(mgr manager.Manager, namespace string, timeout time.Duration) *Reconciler {
+// NewReconciler returns a new Reconciler. The given options configure the service managing the cache of the clusters,
+// eg. cluster.WithClientOptions to tune the clients of the clusters.
+func NewReconciler(mgr manager.Manager, namespace string, timeout time.Duration, options ...cluster.ToolchainClusterServiceOption) *Reconciler {
 	cacheLog := log.Log.WithName("toolchaincluster_cache")
-	clusterCacheService := cluster.NewToolchainClusterService(mgr.GetClient(), cacheLog, namespace, timeout)
+	clusterCacheService := cluster.NewToolchainClusterService(mgr.GetClient(), cacheLog, namespace, timeout, options...)
 	return &Reconciler{
 		client:              mgr.GetClient(),
 		scheme:              mgr.GetScheme(),
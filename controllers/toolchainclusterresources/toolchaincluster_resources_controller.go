@@ -3,7 +3,9 @@ package toolchainclusterresources
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"fmt"
+	"sort"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	commoncontroller "github.com/codeready-toolchain/toolchain-common/controllers"
@@ -11,13 +13,20 @@ import (
 	commonpredicates "github.com/codeready-toolchain/toolchain-common/pkg/predicate"
 	"github.com/codeready-toolchain/toolchain-common/pkg/template"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -27,44 +36,87 @@ const ResourceControllerLabelValue = "toolchaincluster-resources-controller" //
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, operatorNamespace string) error {
-	// check for required templates FS directory
-	if r.Templates == nil {
-		return fmt.Errorf("no templates FS configured")
+	// check for required templates
+	if !r.hasTemplates() {
+		return fmt.Errorf("no templates configured")
 	}
+	r.operatorNamespace = operatorNamespace
 
 	build := ctrl.NewControllerManagedBy(mgr).
 		For(&v1.ServiceAccount{})
 
 	// add watcher for all kinds from given templates
-	var err error
-	r.templateObjects, err = template.LoadObjectsFromEmbedFS(r.Templates, &template.Variables{Namespace: operatorNamespace})
+	// (the cache of the manager is not started yet, so the ConfigMap and the configuration are read with the API reader)
+	templateObjects, err := r.loadTemplateObjects(context.TODO(), mgr.GetAPIReader())
 	if err != nil {
 		return err
 	}
 
 	mapToOwnerByLabel := handler.EnqueueRequestsFromMapFunc(commoncontroller.MapToControllerByMatchingLabel(toolchainv1alpha1.ProviderLabelKey, ResourceControllerLabelValue))
-	for _, obj := range r.templateObjects {
+	for _, obj := range templateObjects {
 		build = build.Watches(obj.DeepCopyObject().(runtimeclient.Object), mapToOwnerByLabel, builder.WithPredicates(commonpredicates.LabelsAndGenerationPredicate{}))
 	}
+	// the objects are re-applied when the templates in the ConfigMap or the configuration change
+	// (but the kinds which are not in the templates at the startup are not watched)
+	if r.TemplatesConfigMap != "" {
+		build = build.Watches(&v1.ConfigMap{}, &handler.EnqueueRequestForObject{}, builder.WithPredicates(r.isInOperatorNamespace(r.TemplatesConfigMap)))
+	}
+	if r.ConfigObj != nil {
+		build = build.Watches(r.ConfigObj.DeepCopyObject().(runtimeclient.Object), &handler.EnqueueRequestForObject{}, builder.WithPredicates(r.isInOperatorNamespace(configName)))
+	}
 	return build.Complete(r)
 }
 
 // Reconciler reconciles a ToolchainCluster object
 type Reconciler struct {
-	Client          runtimeclient.Client
-	Scheme          *runtime.Scheme
-	Templates       *embed.FS
-	FieldManager    string
-	templateObjects []*unstructured.Unstructured
+	Client runtimeclient.Client
+	Scheme *runtime.Scheme
+	// Templates is the embedded filesystem containing the templates
+	Templates *embed.FS
+	// TemplatesConfigMap is the name of the ConfigMap in the operator namespace whose data entries are templates
+	TemplatesConfigMap string
+	// TemplatesDir is the directory on disk containing the templates, either as plain files, tarballs or as an OCI image layout
+	TemplatesDir string
+	// ClusterName is the name of the cluster, available in the templates as {{.ClusterName}}
+	ClusterName string
+	// ClusterRoles are the roles of the cluster, available in the templates as {{.ClusterRoles}} and via {{.HasRole "role"}}
+	ClusterRoles []string
+	// Environment is the environment the operator runs in, available in the templates as {{.Environment}}
+	Environment string
+	// ConfigObj is an empty instance of the configuration (ToolchainConfig or MemberOperatorConfig) whose spec is available in the templates
+	// as {{.Config}}. The configuration is read from the `config` resource in the operator namespace.
+	ConfigObj    runtimeclient.Object
+	FieldManager string
+	// InventoryOwner is the owner of the inventory of the managed kinds (eg. the Deployment of the operator), so that the inventory
+	// is garbage collected together with it. The inventory is owned by the operator namespace when it's not set.
+	InventoryOwner runtimeclient.Object
+
+	operatorNamespace string
 }
 
-// Reconcile loads all the manifests from a given embed.FS folder, evaluates the supported variables and applies the objects in the cluster.
+// configName is the name of the configuration resource in the operator namespace
+const configName = "config"
+
+const (
+	// inventoryName is the name of the ConfigMap in the operator namespace which records the kinds of the applied objects,
+	// so that the objects of a kind removed from the templates are pruned too (even if the operator was restarted in the meantime)
+	inventoryName = "toolchaincluster-resources-inventory"
+	// inventoryKindsKey is the key of the inventory ConfigMap containing the JSON list of the kinds
+	inventoryKindsKey = "kinds"
+)
+
+// Reconcile loads all the manifests from the configured template sources, evaluates the supported variables and applies the objects in the cluster.
 func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
 	reqLogger := log.FromContext(ctx)
 	reqLogger.Info("Reconciling ToolchainCluster resources controller")
-	// check for required templates FS directory
-	if r.Templates == nil {
-		return reconcile.Result{}, fmt.Errorf("no templates FS configured")
+	// check for required templates
+	if !r.hasTemplates() {
+		return reconcile.Result{}, fmt.Errorf("no templates configured")
+	}
+
+	templateObjects, err := r.loadTemplateObjects(ctx, r.Client)
+	if err != nil {
+		return reconcile.Result{}, err
 	}
 
 	// apply all the objects with a custom label
@@ -72,8 +124,205 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 		toolchainv1alpha1.ProviderLabelKey: ResourceControllerLabelValue,
 	}
 
-	// TODO implement delete logic for objects that were renamed/removed from the templates
+	// the objects of the kinds recorded in the inventory by the previous reconciles are pruned too
+	kinds := kindsOf(templateObjects)
+	inventory, recordedKinds, err := r.getInventory(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	managedKinds := mergeKinds(recordedKinds, kinds)
 
+	// objects that were renamed/removed from the templates are pruned from the cluster, including the objects of the kinds
+	// that are not in the templates anymore
 	cl := applycl.NewSSAApplyClient(r.Client, r.FieldManager)
-	return reconcile.Result{}, applycl.ApplyAll(ctx, cl, r.templateObjects, applycl.EnsureLabels(newLabels)) // apply objects on the cluster
+	pruneKinds := make([]schema.GroupVersionKind, 0, len(managedKinds))
+	for _, kind := range managedKinds {
+		pruneKinds = append(pruneKinds, kind.GroupVersionKind())
+	}
+	if err := applycl.ApplyAll(ctx, cl, templateObjects, applycl.EnsureLabels(newLabels), // apply objects on the cluster
+		applycl.WithPrune(ResourceControllerLabelValue, applycl.PruneKinds(pruneKinds...))); err != nil {
+		return reconcile.Result{}, err
+	}
+	// the objects of the kinds that are not in the templates anymore were pruned, so only the current kinds are recorded
+	// (the inventory is not modified if the apply or the prune failed, so the recorded kinds are pruned at the next reconcile)
+	return reconcile.Result{}, r.saveInventory(ctx, inventory, kinds)
+}
+
+// getInventory returns the ConfigMap with the inventory of the managed kinds and the kinds it contains.
+// If the ConfigMap doesn't exist yet, then a new one (without any kind) is returned.
+func (r *Reconciler) getInventory(ctx context.Context) (*v1.ConfigMap, []metav1.TypeMeta, error) {
+	inventory := &v1.ConfigMap{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: r.operatorNamespace, Name: inventoryName}, inventory); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("unable to get the inventory of the managed kinds: %w", err)
+		}
+		inventory = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: r.operatorNamespace,
+				Name:      inventoryName,
+			},
+		}
+		return inventory, nil, nil
+	}
+	var kinds []metav1.TypeMeta
+	if content, found := inventory.Data[inventoryKindsKey]; found {
+		if err := json.Unmarshal([]byte(content), &kinds); err != nil {
+			return nil, nil, fmt.Errorf("invalid inventory of the managed kinds: %w", err)
+		}
+	}
+	return inventory, kinds, nil
+}
+
+// saveInventory stores the given kinds in the inventory together with its labels and its owner, unless they are already stored there
+func (r *Reconciler) saveInventory(ctx context.Context, inventory *v1.ConfigMap, kinds []metav1.TypeMeta) error {
+	content, err := json.Marshal(kinds)
+	if err != nil {
+		return err
+	}
+	owner, err := r.inventoryOwner(ctx)
+	if err != nil {
+		return err
+	}
+	original := inventory.DeepCopy()
+	applycl.MergeLabels(inventory, map[string]string{
+		toolchainv1alpha1.ProviderLabelKey: ResourceControllerLabelValue,
+	})
+	if err := controllerutil.SetOwnerReference(owner, inventory, r.Scheme); err != nil {
+		return fmt.Errorf("unable to set the owner of the inventory of the managed kinds: %w", err)
+	}
+	if inventory.Data == nil {
+		inventory.Data = map[string]string{}
+	}
+	inventory.Data[inventoryKindsKey] = string(content)
+	if inventory.ResourceVersion != "" && equality.Semantic.DeepEqual(original, inventory) {
+		return nil
+	}
+	if inventory.ResourceVersion == "" {
+		err = r.Client.Create(ctx, inventory)
+	} else {
+		err = r.Client.Update(ctx, inventory)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to save the inventory of the managed kinds: %w", err)
+	}
+	return nil
+}
+
+// inventoryOwner returns the InventoryOwner, or the operator namespace if it's not set
+func (r *Reconciler) inventoryOwner(ctx context.Context) (runtimeclient.Object, error) {
+	if r.InventoryOwner != nil {
+		return r.InventoryOwner, nil
+	}
+	namespace := &v1.Namespace{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: r.operatorNamespace}, namespace); err != nil {
+		return nil, fmt.Errorf("unable to get the operator namespace: %w", err)
+	}
+	return namespace, nil
+}
+
+// kindsOf returns the sorted kinds of the given objects
+func kindsOf(objects []*unstructured.Unstructured) []metav1.TypeMeta {
+	kinds := make([]metav1.TypeMeta, 0, len(objects))
+	for _, obj := range objects {
+		kinds = append(kinds, metav1.TypeMeta{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind()})
+	}
+	return mergeKinds(kinds)
+}
+
+// mergeKinds returns the sorted union of the given lists of kinds
+func mergeKinds(lists ...[]metav1.TypeMeta) []metav1.TypeMeta {
+	merged := []metav1.TypeMeta{}
+	seen := map[metav1.TypeMeta]bool{}
+	for _, kinds := range lists {
+		for _, kind := range kinds {
+			if !seen[kind] {
+				seen[kind] = true
+				merged = append(merged, kind)
+			}
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].APIVersion != merged[j].APIVersion {
+			return merged[i].APIVersion < merged[j].APIVersion
+		}
+		return merged[i].Kind < merged[j].Kind
+	})
+	return merged
+}
+
+func (r *Reconciler) hasTemplates() bool {
+	return r.Templates != nil || r.TemplatesConfigMap != "" || r.TemplatesDir != ""
+}
+
+func (r *Reconciler) isInOperatorNamespace(name string) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj runtimeclient.Object) bool {
+		return obj.GetNamespace() == r.operatorNamespace && obj.GetName() == name
+	})
+}
+
+// templateVariables returns the variables of the templates, including the values of the configuration (if configured)
+func (r *Reconciler) templateVariables(ctx context.Context, reader runtimeclient.Reader) (*template.Variables, error) {
+	variables := &template.Variables{
+		Namespace:    r.operatorNamespace,
+		ClusterName:  r.ClusterName,
+		ClusterRoles: r.ClusterRoles,
+		Environment:  r.Environment,
+		Config:       map[string]interface{}{},
+	}
+	if r.ConfigObj == nil {
+		return variables, nil
+	}
+	config := r.ConfigObj.DeepCopyObject().(runtimeclient.Object)
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: r.operatorNamespace, Name: configName}, config); err != nil {
+		if errors.IsNotFound(err) {
+			return variables, nil
+		}
+		return nil, fmt.Errorf("unable to get the configuration: %w", err)
+	}
+	values, err := template.ConfigValues(config)
+	if err != nil {
+		return nil, err
+	}
+	variables.Config = values
+	return variables, nil
+}
+
+// loadTemplateObjects loads the objects from the embedded templates, the templates of the ConfigMap and the templates of the directory, in this order
+func (r *Reconciler) loadTemplateObjects(ctx context.Context, reader runtimeclient.Reader) ([]*unstructured.Unstructured, error) {
+	variables, err := r.templateVariables(ctx, reader)
+	if err != nil {
+		return nil, err
+	}
+	var templateObjects []*unstructured.Unstructured
+	if r.Templates != nil {
+		objects, err := template.LoadObjectsFromEmbedFS(r.Templates, variables)
+		if err != nil {
+			return nil, err
+		}
+		templateObjects = append(templateObjects, objects...)
+	}
+	if r.TemplatesConfigMap != "" {
+		cm := &v1.ConfigMap{}
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: r.operatorNamespace, Name: r.TemplatesConfigMap}, cm); err != nil {
+			// the ConfigMap can be created later on
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("unable to get the ConfigMap with the templates: %w", err)
+			}
+			log.FromContext(ctx).Info("the ConfigMap with the templates was not found", "name", r.TemplatesConfigMap)
+		} else {
+			objects, err := template.LoadObjectsFromConfigMap(cm, variables)
+			if err != nil {
+				return nil, err
+			}
+			templateObjects = append(templateObjects, objects...)
+		}
+	}
+	if r.TemplatesDir != "" {
+		objects, err := template.LoadObjectsFromDirectory(r.TemplatesDir, variables)
+		if err != nil {
+			return nil, err
+		}
+		templateObjects = append(templateObjects, objects...)
+	}
+	return templateObjects, nil
 }
@@ -3,14 +3,19 @@ package toolchainclusterresources
 import (
 	"context"
 	"embed"
+	"fmt"
 	"testing"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
-	"github.com/codeready-toolchain/toolchain-common/pkg/template"
+	applycl "github.com/codeready-toolchain/toolchain-common/pkg/client"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -37,7 +42,8 @@ func TestToolchainClusterResources(t *testing.T) {
 			Namespace: test.MemberOperatorNs,
 		},
 	}
-	cl := test.NewFakeClient(t, sa)
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: test.MemberOperatorNs, UID: "ns-uid"}}
+	cl := test.NewFakeClient(t, ns, sa)
 
 	t.Run("controller should create service account resource", func(t *testing.T) {
 		// given
@@ -68,6 +74,144 @@ func TestToolchainClusterResources(t *testing.T) {
 		require.Equal(t, ResourceControllerLabelValue, cr.Labels[toolchainv1alpha1.ProviderLabelKey])
 	})
 
+	t.Run("controller should delete resources removed from the templates", func(t *testing.T) {
+		// given
+		removed := &rbac.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "removed-toolchaincluster-cr",
+				Labels: map[string]string{
+					toolchainv1alpha1.ProviderLabelKey: ResourceControllerLabelValue,
+					applycl.ApplyGroupLabelKey:         ResourceControllerLabelValue,
+				},
+			},
+		}
+		notManaged := &rbac.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "not-managed-cr",
+			},
+		}
+		cl := test.NewFakeClient(t, ns, sa, removed, notManaged)
+		controller, req := prepareReconcile(sa, cl, &clusterRoleFS)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		err = cl.Get(context.TODO(), types.NamespacedName{Name: removed.Name}, &rbac.ClusterRole{})
+		require.True(t, errors.IsNotFound(err))
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Name: notManaged.Name}, &rbac.ClusterRole{}))
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Name: "member-toolchaincluster-cr"}, &rbac.ClusterRole{}))
+	})
+
+	t.Run("controller should delete resources of the kinds removed from the templates", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, ns, sa)
+		controller, req := prepareReconcile(sa, cl, &serviceAccountFS)
+		_, err := controller.Reconcile(context.TODO(), req)
+		require.NoError(t, err)
+		checkExpectedServiceAccountResources(t, cl)
+		// none of the kinds of the previous templates is in the new templates
+		controller, req = prepareReconcile(sa, cl, &clusterRoleFS)
+
+		// when
+		_, err = controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		for _, obj := range []client.Object{&v1.ServiceAccount{}, &rbac.Role{}, &rbac.RoleBinding{}} {
+			err := cl.Get(context.TODO(), types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "toolchaincluster-host"}, obj)
+			require.True(t, errors.IsNotFound(err), "%T was not pruned", obj)
+		}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Name: "member-toolchaincluster-cr"}, &rbac.ClusterRole{}))
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: sa.Namespace, Name: sa.Name}, &v1.ServiceAccount{}))
+		// only the kinds of the current templates remain in the inventory
+		inventory := &v1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.MemberOperatorNs, Name: inventoryName}, inventory))
+		assert.JSONEq(t, `[{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole"}]`, inventory.Data[inventoryKindsKey])
+	})
+
+	t.Run("controller should save the inventory once with its labels and owner", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, ns, sa)
+		inventorySaves := 0
+		cl.MockCreate = func(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+			if obj.GetName() == inventoryName {
+				inventorySaves++
+			}
+			return cl.Client.Create(ctx, obj, opts...)
+		}
+		cl.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			if obj.GetName() == inventoryName {
+				inventorySaves++
+			}
+			return cl.Client.Update(ctx, obj, opts...)
+		}
+		controller, req := prepareReconcile(sa, cl, &serviceAccountFS)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, inventorySaves)
+		inventory := &v1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.MemberOperatorNs, Name: inventoryName}, inventory))
+		assert.Equal(t, ResourceControllerLabelValue, inventory.Labels[toolchainv1alpha1.ProviderLabelKey])
+		require.Len(t, inventory.OwnerReferences, 1)
+		assert.Equal(t, "Namespace", inventory.OwnerReferences[0].Kind)
+		assert.Equal(t, test.MemberOperatorNs, inventory.OwnerReferences[0].Name)
+		assert.Equal(t, ns.UID, inventory.OwnerReferences[0].UID)
+
+		t.Run("the unchanged inventory is not saved again", func(t *testing.T) {
+			// given
+			inventorySaves = 0
+
+			// when
+			_, err := controller.Reconcile(context.TODO(), req)
+
+			// then
+			require.NoError(t, err)
+			assert.Zero(t, inventorySaves)
+		})
+	})
+
+	t.Run("controller should not save the inventory when the apply fails", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, ns, sa)
+		cl.MockPatch = func(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return fmt.Errorf("some error")
+		}
+		controller, req := prepareReconcile(sa, cl, &serviceAccountFS)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.ErrorContains(t, err, "some error")
+		err = cl.Get(context.TODO(), types.NamespacedName{Namespace: test.MemberOperatorNs, Name: inventoryName}, &v1.ConfigMap{})
+		require.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("controller should set the configured owner of the inventory", func(t *testing.T) {
+		// given
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "member-operator", Namespace: test.MemberOperatorNs, UID: "deployment-uid"}}
+		cl := test.NewFakeClient(t, sa, deployment)
+		controller, req := prepareReconcile(sa, cl, &serviceAccountFS)
+		controller.InventoryOwner = deployment
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		inventory := &v1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.MemberOperatorNs, Name: inventoryName}, inventory))
+		require.Len(t, inventory.OwnerReferences, 1)
+		assert.Equal(t, "Deployment", inventory.OwnerReferences[0].Kind)
+		assert.Equal(t, deployment.UID, inventory.OwnerReferences[0].UID)
+	})
+
 	t.Run("controller should return error when not templates are configured", func(t *testing.T) {
 		// given
 		controller, req := prepareReconcile(sa, cl, nil) // no templates are passed to the controller initialization
@@ -80,6 +224,104 @@ func TestToolchainClusterResources(t *testing.T) {
 	})
 }
 
+func TestToolchainClusterResourcesTemplateSources(t *testing.T) {
+	// given
+	sa := &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "existing-sa",
+			Namespace: test.MemberOperatorNs,
+		},
+	}
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: test.MemberOperatorNs, UID: "ns-uid"}}
+	templates := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "templates",
+			Namespace: test.MemberOperatorNs,
+		},
+		Data: map[string]string{
+			"cluster-info.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{.ClusterName}}-info
+  namespace: {{.Namespace}}
+data:
+  environment: {{.Environment}}
+  {{- if .HasRole "tenant"}}
+  tenant: "true"
+  {{- end}}
+  {{- with .Config.environment}}
+  config-environment: {{.}}
+  {{- end}}
+`,
+		},
+	}
+	newReconciler := func(cl *test.FakeClient) (Reconciler, reconcile.Request) {
+		return Reconciler{
+			Client:             cl,
+			Scheme:             scheme.Scheme,
+			TemplatesConfigMap: "templates",
+			TemplatesDir:       "testdata",
+			ClusterName:        "member-1",
+			ClusterRoles:       []string{"tenant"},
+			Environment:        "e2e-tests",
+			ConfigObj:          &toolchainv1alpha1.MemberOperatorConfig{},
+			FieldManager:       "testOwner",
+			operatorNamespace:  test.MemberOperatorNs,
+		}, reconcile.Request{NamespacedName: test.NamespacedName(sa.Namespace, sa.Name)}
+	}
+
+	t.Run("controller should create resources from the ConfigMap and the directory", func(t *testing.T) {
+		// given
+		config := testconfig.NewMemberOperatorConfigObj(testconfig.MemberEnvironment("dev"))
+		cl := test.NewFakeClient(t, ns, sa, templates, config)
+		controller, req := newReconciler(cl)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		info := &v1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "member-1-info"}, info))
+		assert.Equal(t, map[string]string{
+			"environment":        "e2e-tests",
+			"tenant":             "true",
+			"config-environment": "dev",
+		}, info.Data)
+		assert.Equal(t, ResourceControllerLabelValue, info.Labels[toolchainv1alpha1.ProviderLabelKey])
+		checkExpectedServiceAccountResources(t, cl)
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Name: "member-toolchaincluster-cr"}, &rbac.ClusterRole{}))
+	})
+
+	t.Run("controller should create resources from the directory when the ConfigMap and the configuration are missing", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, ns, sa)
+		controller, req := newReconciler(cl)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		checkExpectedServiceAccountResources(t, cl)
+		err = cl.Get(context.TODO(), types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "member-1-info"}, &v1.ConfigMap{})
+		require.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("controller should return error when the directory does not exist", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, ns, sa, templates)
+		controller, req := newReconciler(cl)
+		controller.TemplatesDir = "missing"
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.ErrorContains(t, err, "unable to read the templates in missing")
+	})
+}
+
 func checkExpectedServiceAccountResources(t *testing.T, cl *test.FakeClient) {
 	expectedTypes := []client.Object{
 		&v1.ServiceAccount{},
@@ -102,16 +344,12 @@ func prepareReconcile(sa *v1.ServiceAccount, cl *test.FakeClient, templates *emb
 	if templates == nil {
 		return emptyReconciler(cl)
 	}
-	templateObjects, err := template.LoadObjectsFromEmbedFS(templates, &template.Variables{Namespace: sa.Namespace})
-	if err != nil {
-		return emptyReconciler(cl)
-	}
 	controller := Reconciler{
-		Client:          cl,
-		Scheme:          scheme.Scheme,
-		Templates:       templates,
-		FieldManager:    "testOwner",
-		templateObjects: templateObjects,
+		Client:            cl,
+		Scheme:            scheme.Scheme,
+		Templates:         templates,
+		FieldManager:      "testOwner",
+		operatorNamespace: sa.Namespace,
 	}
 	req := reconcile.Request{
 		NamespacedName: test.NamespacedName(sa.Namespace, sa.Name),
@@ -121,9 +359,8 @@ func prepareReconcile(sa *v1.ServiceAccount, cl *test.FakeClient, templates *emb
 
 func emptyReconciler(cl *test.FakeClient) (Reconciler, reconcile.Request) {
 	return Reconciler{
-		Client:          cl,
-		Scheme:          scheme.Scheme,
-		Templates:       nil,
-		templateObjects: nil,
+		Client:    cl,
+		Scheme:    scheme.Scheme,
+		Templates: nil,
 	}, reconcile.Request{}
 }
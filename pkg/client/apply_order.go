@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kindTiers defines the order in which the objects of the given kinds are applied.
+// The objects of the kinds that are not listed here (eg. workloads or custom resources) are applied last.
+var kindTiers = map[schema.GroupKind]int{
+	{Group: "", Kind: "Namespace"}:                                    0,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: 0,
+	{Group: "", Kind: "ServiceAccount"}:                               1,
+	{Group: "", Kind: "ConfigMap"}:                                    1,
+	{Group: "", Kind: "Secret"}:                                       1,
+	{Group: "", Kind: "LimitRange"}:                                   1,
+	{Group: "", Kind: "ResourceQuota"}:                                1,
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:                1,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:         1,
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:         2,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:  2,
+	{Group: "authorization.openshift.io", Kind: "RoleBinding"}:        2,
+}
+
+const lastKindTier = 3
+
+// KindTier returns the position of the given kind in the dependency order of the apply:
+// Namespaces and CRDs are first (0), then ServiceAccounts, Roles and other configuration (1),
+// then RoleBindings (2) and then everything else (3).
+func KindTier(gk schema.GroupKind) int {
+	if tier, ok := kindTiers[gk]; ok {
+		return tier
+	}
+	return lastKindTier
+}
+
+// SortObjectsByKindDependency returns a copy of the given objects sorted by the dependency order of their kinds (see KindTier).
+// The order of the objects with kinds in the same tier is preserved.
+func SortObjectsByKindDependency[T client.Object](objects []T, scheme *runtime.Scheme) []T {
+	sorted := make([]T, len(objects))
+	copy(sorted, objects)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return objectTier(sorted[i], scheme) < objectTier(sorted[j], scheme)
+	})
+	return sorted
+}
+
+func objectTier(obj client.Object, scheme *runtime.Scheme) int {
+	gvk, err := gvkForObject(obj, scheme)
+	if err != nil {
+		// the apply itself will fail on this object later on
+		return lastKindTier
+	}
+	return KindTier(gvk.GroupKind())
+}
+
+// applyInTiers applies the objects tier by tier in the dependency order of their kinds. The objects in the same tier
+// are applied concurrently using at most the given number of goroutines. All the objects of a tier are applied even if some of
+// them fail, but the next tiers are not applied at all, because their objects may depend on the ones that failed. No more objects
+// are applied once the context is cancelled. All the errors are returned as an aggregate. The results are returned in the order
+// of the given objects, with nil results for the objects that failed or that were not applied.
+func applyInTiers[T client.Object](ctx context.Context, objects []T, scheme *runtime.Scheme, parallelism int, apply func(context.Context, T) (*ApplyResult, error)) ([]*ApplyResult, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	tiers := make([][]int, lastKindTier+1)
+	for i, obj := range objects {
+		tier := objectTier(obj, scheme)
+		tiers[tier] = append(tiers[tier], i)
+	}
+
+	results := make([]*ApplyResult, len(objects))
+	errs := make([]error, len(objects))
+	for _, tier := range tiers {
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, parallelism)
+		var ctxErr error
+	launch:
+		for _, i := range tier {
+			// the select picks a random case if the context is cancelled and a goroutine is available at the same time
+			if ctxErr = ctx.Err(); ctxErr != nil {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				ctxErr = ctx.Err()
+				break launch
+			case semaphore <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-semaphore
+					wg.Done()
+				}()
+				results[i], errs[i] = apply(ctx, objects[i])
+			}(i)
+		}
+		wg.Wait()
+		if ctxErr != nil {
+			return results, utilerrors.NewAggregate(append(errs, ctxErr))
+		}
+		if err := utilerrors.NewAggregate(errs); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
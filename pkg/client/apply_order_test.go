@@ -0,0 +1,50 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestKindTier(t *testing.T) {
+	for gk, expected := range map[schema.GroupKind]int{
+		{Kind: "Namespace"}: 0,
+		{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: 0,
+		{Kind: "ServiceAccount"}:                                  1,
+		{Group: "rbac.authorization.k8s.io", Kind: "Role"}:        1,
+		{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}: 2,
+		{Group: "apps", Kind: "Deployment"}:                       3,
+		{Group: "toolchain.dev.openshift.com", Kind: "Space"}:     3,
+	} {
+		t.Run(gk.String(), func(t *testing.T) {
+			assert.Equal(t, expected, client.KindTier(gk))
+		})
+	}
+}
+
+func TestSortObjectsByKindDependency(t *testing.T) {
+	// given
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "deployment"}}
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb"}}
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "role"}}
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa"}}
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName("ns")
+
+	// when
+	sorted := client.SortObjectsByKindDependency([]runtimeclient.Object{deployment, roleBinding, role, sa, ns}, scheme.Scheme)
+
+	// then
+	assert.Equal(t, []runtimeclient.Object{ns, role, sa, roleBinding, deployment}, sorted)
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ApplyOutcome is the outcome of the apply of a single object
+type ApplyOutcome string
+
+const (
+	// ApplyOutcomeCreated the object didn't exist and was created
+	ApplyOutcomeCreated ApplyOutcome = "Created"
+	// ApplyOutcomeUpdated the object existed and was modified (its resourceVersion was changed by the server)
+	ApplyOutcomeUpdated ApplyOutcome = "Updated"
+	// ApplyOutcomeUnchanged the object was sent to the server, but it was not modified (its resourceVersion was not changed)
+	ApplyOutcomeUnchanged ApplyOutcome = "Unchanged"
+	// ApplyOutcomeRecreated the existing object was deleted and created again
+	ApplyOutcomeRecreated ApplyOutcome = "Recreated"
+	// ApplyOutcomeSkipped the object was not sent to the server at all
+	ApplyOutcomeSkipped ApplyOutcome = "Skipped"
+)
+
+const (
+	// ApplyReasonForceUpdate the update was forced by the ForceUpdate option
+	ApplyReasonForceUpdate = "ForceUpdate"
+	// ApplyReasonConfigurationChanged the last applied configuration stored in the object differs from the new one
+	ApplyReasonConfigurationChanged = "ConfigurationChanged"
+	// ApplyReasonConfigurationEncodingChanged the last applied configuration is the same as the new one, but it's stored with a different encoding
+	ApplyReasonConfigurationEncodingChanged = "ConfigurationEncodingChanged"
+	// ApplyReasonNoLastAppliedConfiguration the object has no last applied configuration that could be compared with the new one
+	ApplyReasonNoLastAppliedConfiguration = "NoLastAppliedConfiguration"
+	// ApplyReasonImmutableFieldChanged the update was rejected because of a change of an immutable field, so the object was recreated
+	ApplyReasonImmutableFieldChanged = "ImmutableFieldChanged"
+	// ApplyReasonServerSideApply the object was patched using SSA, the server decided if the object needed to be modified
+	ApplyReasonServerSideApply = "ServerSideApply"
+)
+
+// ApplyResult contains the details about the apply of a single object
+type ApplyResult struct {
+	GVK       schema.GroupVersionKind
+	Namespace string
+	Name      string
+	// Outcome says if the object was created, updated, left unchanged or skipped
+	Outcome ApplyOutcome
+	// Reason explains why the update of the object was sent to the server (empty for created and skipped objects)
+	Reason string
+	// OldGeneration and OldResourceVersion are taken from the object before the apply (empty if it didn't exist)
+	OldGeneration      int64
+	OldResourceVersion string
+	// NewGeneration and NewResourceVersion are taken from the object after the apply
+	NewGeneration      int64
+	NewResourceVersion string
+}
+
+// CreatedOrUpdated returns `true` if the object was either created, recreated or modified
+func (r *ApplyResult) CreatedOrUpdated() bool {
+	return r != nil && (r.Outcome == ApplyOutcomeCreated || r.Outcome == ApplyOutcomeUpdated || r.Outcome == ApplyOutcomeRecreated)
+}
+
+// createdOrGenerationChanged returns `true` if the object was either created, recreated or modified with its generation incremented
+// by the server, which is the meaning of the boolean returned by ApplyClient.ApplyObject and ApplyClient.Apply
+func (r *ApplyResult) createdOrGenerationChanged() bool {
+	if r == nil {
+		return false
+	}
+	switch r.Outcome {
+	case ApplyOutcomeCreated, ApplyOutcomeRecreated:
+		return true
+	case ApplyOutcomeUpdated:
+		return r.OldGeneration != r.NewGeneration
+	default:
+		return false
+	}
+}
+
+func newApplyResult(obj client.Object, scheme *runtime.Scheme) *ApplyResult {
+	// the GVK is only informative, so ignore the error if the kind cannot be determined
+	gvk, _ := gvkForObject(obj, scheme)
+	return &ApplyResult{
+		GVK:       gvk,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+// setOld records the generation and the resource version of the object before the apply
+func (r *ApplyResult) setOld(obj client.Object) {
+	r.OldGeneration = obj.GetGeneration()
+	r.OldResourceVersion = obj.GetResourceVersion()
+}
+
+// setNew records the generation and the resource version of the object after the apply
+func (r *ApplyResult) setNew(obj client.Object) {
+	r.NewGeneration = obj.GetGeneration()
+	r.NewResourceVersion = obj.GetResourceVersion()
+}
+
+// AnyCreatedOrUpdated returns `true` if at least one of the objects was either created or modified
+func AnyCreatedOrUpdated(results []*ApplyResult) bool {
+	for _, result := range results {
+		if result.CreatedOrUpdated() {
+			return true
+		}
+	}
+	return false
+}
@@ -4,10 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
@@ -27,19 +27,52 @@ var log = logf.Log.WithName("apply_client")
 // ApplyClient the client to use when creating or updating objects
 type ApplyClient struct {
 	client.Client
+	// updateStrategies is nil when the ApplyClient is not created by NewApplyClient, in which case the default ones are used
+	updateStrategies *UpdateStrategyRegistry
+	// encoding is the default encoding of the applied configuration (see SetConfigurationEncoding)
+	encoding ConfigurationEncoding
 }
 
-// NewApplyClient returns a new ApplyClient
+// defaultUpdateStrategies are used by the ApplyClients which were not created by NewApplyClient
+var defaultUpdateStrategies = NewDefaultUpdateStrategyRegistry()
+
+// NewApplyClient returns a new ApplyClient with the default UpdateStrategies (see NewDefaultUpdateStrategyRegistry)
 func NewApplyClient(cl client.Client) *ApplyClient {
 	return &ApplyClient{
-		Client: cl,
+		Client:           cl,
+		updateStrategies: NewDefaultUpdateStrategyRegistry(),
+	}
+}
+
+// RegisterUpdateStrategy sets the UpdateStrategy to use when updating the existing objects of the given kind.
+// Any strategy previously registered for the same kind (including the default ones) is replaced.
+func (c *ApplyClient) RegisterUpdateStrategy(gvk schema.GroupVersionKind, strategy UpdateStrategy) {
+	if c.updateStrategies == nil {
+		c.updateStrategies = NewDefaultUpdateStrategyRegistry()
+	}
+	c.updateStrategies.Register(gvk, strategy)
+}
+
+// SetConfigurationEncoding sets the encoding of the applied configuration saved in the resource annotations by all the methods
+// of the client, including Apply, ApplyWithResults and ApplyAndPrune (default: `ConfigurationEncodingJSON`).
+// The EncodeConfiguration option still takes precedence over it.
+func (c *ApplyClient) SetConfigurationEncoding(encoding ConfigurationEncoding) {
+	c.encoding = encoding
+}
+
+func (c ApplyClient) getUpdateStrategies() *UpdateStrategyRegistry {
+	if c.updateStrategies == nil {
+		return defaultUpdateStrategies
 	}
+	return c.updateStrategies
 }
 
 type applyObjectConfiguration struct {
 	owner             v1.Object
 	forceUpdate       bool
 	saveConfiguration bool
+	encoding          ConfigurationEncoding
+	recreatePolicy    *v1.DeletionPropagation
 }
 
 func newApplyObjectConfiguration(options ...ApplyObjectOption) applyObjectConfiguration {
@@ -47,6 +80,7 @@ func newApplyObjectConfiguration(options ...ApplyObjectOption) applyObjectConfig
 		owner:             nil,
 		forceUpdate:       false,
 		saveConfiguration: true,
+		encoding:          ConfigurationEncodingJSON,
 	}
 	for _, apply := range options {
 		apply(&config)
@@ -79,13 +113,33 @@ func SaveConfiguration(saveConfiguration bool) ApplyObjectOption {
 	}
 }
 
+// EncodeConfiguration sets the encoding of the applied configuration saved in the resource annotations
+// (default: the encoding of the client, see ApplyClient.SetConfigurationEncoding).
+// The last applied configuration stored with a different encoding is still compared with the new one, and if the configuration
+// didn't change, the resource is updated only to store the configuration with the new encoding.
+func EncodeConfiguration(encoding ConfigurationEncoding) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.encoding = encoding
+	}
+}
+
+// RecreateOnImmutableChange deletes and recreates the resource when its update is rejected because of a change
+// of an immutable field (see IsImmutableFieldError). The existing resource is deleted using the given propagation policy
+// and the new one is created only after the existing one disappeared from the cluster (default: disabled)
+func RecreateOnImmutableChange(propagationPolicy v1.DeletionPropagation) ApplyObjectOption {
+	return func(config *applyObjectConfiguration) {
+		config.recreatePolicy = &propagationPolicy
+	}
+}
+
 // ApplyRuntimeObject casts the provided object to client.Object and calls ApplyClient.ApplyObject method
 func (c ApplyClient) ApplyRuntimeObject(ctx context.Context, obj runtime.Object, options ...ApplyObjectOption) (bool, error) {
 	clientObj, ok := obj.(client.Object)
 	if !ok {
 		return false, fmt.Errorf("unable to cast of the object to client.Object: %+v", obj)
 	}
-	return c.applyObject(ctx, clientObj, options...)
+	result, err := c.applyObject(ctx, clientObj, options...)
+	return result.createdOrGenerationChanged(), err
 }
 
 // ApplyObject creates the object if is missing and if the owner object is provided, then it's set as a controller reference.
@@ -93,31 +147,50 @@ func (c ApplyClient) ApplyRuntimeObject(ctx context.Context, obj runtime.Object,
 // is automatically updated. If it looks to be same then based on the value of forceUpdate param it updates the object or not.
 // The return boolean says if the object was either created or updated (`true`). If nothing changed (ie, the generation was not
 // incremented by the server), then it returns `false`.
+// NOTE: this is kept for backwards compatibility. Prefer using the ApplyObjectWithResult() method, whose outcome also reports
+// the updates which don't increment the generation (eg. of the labels or of the ConfigMaps).
 func (c ApplyClient) ApplyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (bool, error) {
+	result, err := c.ApplyObjectWithResult(ctx, obj, options...)
+	return result.createdOrGenerationChanged(), err
+}
+
+// ApplyObjectWithResult works the same way as ApplyObject, but instead of a boolean it returns the details about the outcome
+// of the apply: if the object was created, updated, left unchanged or skipped, together with the reason of the update
+// and the generation and resourceVersion of the object before and after the apply.
+func (c ApplyClient) ApplyObjectWithResult(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (*ApplyResult, error) {
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	createdOrUpdated, err := c.applyObject(ctx, obj, options...)
+	result, err := c.applyObject(ctx, obj, options...)
 	if err != nil {
-		return createdOrUpdated, fmt.Errorf("unable to create resource of kind: %s, version: %s: %w", gvk.Kind, gvk.Version, err)
+		return result, fmt.Errorf("unable to create resource of kind: %s, version: %s: %w", gvk.Kind, gvk.Version, err)
 	}
-	return createdOrUpdated, nil
+	return result, nil
 }
 
-func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (bool, error) {
+func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (*ApplyResult, error) {
+	if c.encoding != "" {
+		// the encoding of the client comes first, so that it can be overridden by the EncodeConfiguration option
+		options = append([]ApplyObjectOption{EncodeConfiguration(c.encoding)}, options...)
+	}
 	// gets the meta accessor to the new resource
 	config := newApplyObjectConfiguration(options...)
+	result := newApplyResult(obj, c.Scheme())
 
 	// creates a deepcopy of the new resource to be used to check if it already exists
 	existing := obj.DeepCopyObject().(client.Object)
 
-	var newConfiguration string
+	var newConfiguration, encodedConfiguration string
 	if config.saveConfiguration {
 		// set current object as annotation
 		annotations := obj.GetAnnotations()
 		newConfiguration = GetNewConfiguration(obj)
+		var err error
+		if encodedConfiguration, err = encodeConfiguration(newConfiguration, config.encoding); err != nil {
+			return nil, err
+		}
 		if annotations == nil {
 			annotations = map[string]string{}
 		}
-		annotations[LastAppliedConfigurationAnnotationKey] = newConfiguration
+		annotations[LastAppliedConfigurationAnnotationKey] = encodedConfiguration
 		obj.SetAnnotations(annotations)
 	}
 	// gets current object (if exists)
@@ -125,18 +198,42 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 	if err := c.Get(ctx, namespacedName, existing); err != nil {
 		if apierrors.IsNotFound(err) {
 			obj.SetResourceVersion("") // reset resource version when creating to avoid error: resourceVersion should not be set on objects to be created
-			return true, c.createObj(ctx, obj, config.owner)
+			if err := c.createObj(ctx, obj, config.owner); err != nil {
+				return nil, err
+			}
+			result.Outcome = ApplyOutcomeCreated
+			result.setNew(obj)
+			return result, nil
 		}
-		return false, fmt.Errorf("unable to get the resource '%v': %w", existing, err)
+		return nil, fmt.Errorf("unable to get the resource '%v': %w", existing, err)
 	}
+	result.setOld(existing)
 
 	// as it already exists, check using the UpdateStrategy if it should be updated
+	result.Reason = ApplyReasonForceUpdate
 	if !config.forceUpdate {
+		result.Reason = ApplyReasonNoLastAppliedConfiguration
 		existingAnnotations := existing.GetAnnotations()
 		if existingAnnotations != nil {
 			lastApplied, lastAppliedFound := existingAnnotations[LastAppliedConfigurationAnnotationKey]
-			if lastAppliedFound && newConfiguration != "" && newConfiguration == lastApplied {
-				return false, nil
+			if lastAppliedFound && newConfiguration != "" {
+				matches, err := configurationMatches(lastApplied, newConfiguration)
+				if err != nil {
+					// the annotation is corrupted, so let's just overwrite it
+					log.Error(err, "unable to compare the last applied configuration", "object", client.ObjectKeyFromObject(existing))
+				}
+				switch {
+				case matches && configurationEncodingOf(lastApplied) == configurationEncodingOf(encodedConfiguration):
+					result.Outcome = ApplyOutcomeSkipped
+					result.Reason = ""
+					result.setNew(existing)
+					return result, nil
+				case matches:
+					// the configuration is the same, but it needs to be stored with the new encoding
+					result.Reason = ApplyReasonConfigurationEncodingChanged
+				default:
+					result.Reason = ApplyReasonConfigurationChanged
+				}
 			}
 		}
 	}
@@ -144,31 +241,52 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 	// retrieve the current 'resourceVersion' to set it in the resource passed to the `client.Update()`
 	// otherwise we would get an error with the following message:
 	// `nstemplatetiers.toolchain.dev.openshift.com "base1ns" is invalid: metadata.resourceVersion: Invalid value: 0x0: must be specified for an update`
-	originalGeneration := existing.GetGeneration()
 	obj.SetResourceVersion(existing.GetResourceVersion())
 
-	// Special handling of ServiceAccounts is required because if a ServiceAccount is reapplied when it already exists, it causes Kubernetes controllers to
-	// automatically create new Secrets for the ServiceAccounts. After enough time the number of Secrets created will hit the Secrets quota and then no new
-	// Secrets can be created. To prevent this from happening, we keep the existing refs to secrets.
-	if strings.EqualFold(obj.GetObjectKind().GroupVersionKind().Kind, "ServiceAccount") {
-		MergeAnnotations(existing, obj.GetAnnotations()) // copy existing annotations
-		MergeLabels(existing, obj.GetLabels())
-		// let's use the existing object so that we keep the references to the existing secrets
-		obj = existing
+	// apply the UpdateStrategy registered for the kind of the object (if any), eg. to keep the references to the existing secrets
+	// of ServiceAccounts or to retain the immutable `spec.clusterIP` of Services
+	action := UpdateActionUpdate
+	if gvk, err := gvkForObject(obj, c.Scheme()); err == nil {
+		if strategy, found := c.getUpdateStrategies().Get(gvk); found {
+			if obj, action, err = strategy(obj, existing); err != nil {
+				return nil, fmt.Errorf("unable to apply the update strategy for the resource '%s' of kind '%s': %w", existing.GetName(), gvk.Kind, err)
+			}
+		}
 	}
-
-	// also, if the resource to create is a Service and there's a previous version, we should retain its `spec.ClusterIP`, otherwise
-	// the update will fail with the following error:
-	// `Service "<name>" is invalid: spec.clusterIP: Invalid value: "": field is immutable`
-	if err := RetainClusterIP(obj, existing); err != nil {
-		return false, err
+	switch action {
+	case UpdateActionSkip:
+		result.Outcome = ApplyOutcomeSkipped
+		result.Reason = ""
+		result.setNew(existing)
+		return result, nil
+	case UpdateActionRecreate:
+		if err := c.recreate(ctx, obj, existing, config.owner); err != nil {
+			return nil, err
+		}
+		result.Outcome = ApplyOutcomeRecreated
+		result.setNew(obj)
+		return result, nil
 	}
 	if err := c.Update(ctx, obj); err != nil {
-		return false, fmt.Errorf("unable to update the resource '%v': %w", obj, err)
+		if config.recreatePolicy == nil || !IsImmutableFieldError(err) {
+			return nil, fmt.Errorf("unable to update the resource '%v': %w", obj, err)
+		}
+		if err := c.recreate(ctx, obj, existing, config.owner, client.PropagationPolicy(*config.recreatePolicy)); err != nil {
+			return nil, err
+		}
+		result.Outcome = ApplyOutcomeRecreated
+		result.Reason = ApplyReasonImmutableFieldChanged
+		result.setNew(obj)
+		return result, nil
 	}
 
-	// check if it was changed or not
-	return originalGeneration != obj.GetGeneration(), nil
+	// check if it was changed or not (the generation is not incremented for all the kinds, eg. ConfigMaps or ServiceAccounts)
+	result.setNew(obj)
+	result.Outcome = ApplyOutcomeUnchanged
+	if result.OldResourceVersion != result.NewResourceVersion {
+		result.Outcome = ApplyOutcomeUpdated
+	}
+	return result, nil
 }
 
 // RetainClusterIP sets the `spec.clusterIP` value from the given 'existing' object
@@ -231,6 +349,16 @@ func marshalObjectContent(newResource runtime.Object) ([]byte, error) {
 	return json.Marshal(newResource)
 }
 
+// recreate deletes the existing resource, waits until it disappears and then creates the new one
+func (c ApplyClient) recreate(ctx context.Context, newResource, existing client.Object, owner v1.Object, options ...client.DeleteOption) error {
+	if err := deleteAndWait(ctx, c.Client, existing, options...); err != nil {
+		return err
+	}
+	newResource.SetResourceVersion("")
+	newResource.SetUID("")
+	return c.createObj(ctx, newResource, owner)
+}
+
 func (c ApplyClient) createObj(ctx context.Context, newResource client.Object, owner v1.Object) error {
 	if owner != nil {
 		err := controllerutil.SetControllerReference(owner, newResource, c.Scheme())
@@ -242,20 +370,61 @@ func (c ApplyClient) createObj(ctx context.Context, newResource client.Object, o
 }
 
 // Apply applies the objects, ie, creates or updates them on the cluster
-// returns `true, nil` if at least one of the objects was created or modified,
+// returns `true, nil` if at least one of the objects was created or modified (ie, its generation was incremented by the server),
 // `false, nil` if nothing changed, and `false, err` if an error occurred
+// NOTE: this is kept for backwards compatibility. Prefer using the ApplyWithResults() method.
 func (c ApplyClient) Apply(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string) (bool, error) {
-	createdOrUpdated := false
+	results, err := c.ApplyWithResults(ctx, toolchainObjects, newLabels)
+	if err != nil {
+		return false, err
+	}
+	for _, result := range results {
+		if result.createdOrGenerationChanged() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ApplyWithResults applies the objects, ie, creates or updates them on the cluster and returns the result of the apply
+// of every object. If an error occurs, then it stops and returns the results of the objects applied so far.
+func (c ApplyClient) ApplyWithResults(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string) ([]*ApplyResult, error) {
+	results := make([]*ApplyResult, 0, len(toolchainObjects))
 	for _, toolchainObject := range toolchainObjects {
 		MergeLabels(toolchainObject, newLabels)
 
-		result, err := c.ApplyObject(ctx, toolchainObject, ForceUpdate(true))
+		result, err := c.ApplyObjectWithResult(ctx, toolchainObject, ForceUpdate(true))
 		if err != nil {
-			return false, fmt.Errorf("unable to create resource of kind: %s, version: %s: %w", toolchainObject.GetObjectKind().GroupVersionKind().Kind, toolchainObject.GetObjectKind().GroupVersionKind().Version, err)
+			return results, fmt.Errorf("unable to create resource of kind: %s, version: %s: %w", toolchainObject.GetObjectKind().GroupVersionKind().Kind, toolchainObject.GetObjectKind().GroupVersionKind().Version, err)
 		}
-		createdOrUpdated = createdOrUpdated || result
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ApplyAndPrune applies the objects the same way as Apply does, but it also labels them with the given apply group
+// and then deletes all the objects from the same apply group that were applied before but are no longer part of the given objects.
+// returns `true, nil` if at least one of the objects was created, modified or deleted,
+// `false, nil` if nothing changed, and `false, err` if an error occurred
+func (c ApplyClient) ApplyAndPrune(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string, group string, options ...PruneOption) (bool, error) {
+	if group == "" {
+		return false, fmt.Errorf("the apply group must not be empty")
+	}
+	groupLabels := make(map[string]string, len(newLabels)+1)
+	for key, value := range newLabels {
+		groupLabels[key] = value
+	}
+	groupLabels[ApplyGroupLabelKey] = group
+
+	createdOrUpdated, err := c.Apply(ctx, toolchainObjects, groupLabels)
+	if err != nil {
+		return false, err
+	}
+	pruned, err := Prune(ctx, c.Client, group, toolchainObjects, options...)
+	if err != nil {
+		return false, err
 	}
-	return createdOrUpdated, nil
+	return createdOrUpdated || (len(pruned) > 0 && !newPruneConfiguration(options...).dryRun), nil
 }
 
 // MergeLabels gets current exiting labels and merges them with the new ones provided
@@ -2,10 +2,13 @@ package client_test
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,11 +24,16 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes/scheme"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -441,6 +449,639 @@ func TestApplySingle(t *testing.T) {
 	})
 }
 
+func TestApplyObjectWithResult(t *testing.T) {
+	// given
+	addToScheme(t)
+	newCm := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "registration-service",
+				Namespace: "toolchain-host-operator",
+			},
+			Data: map[string]string{
+				"first-param": value,
+			},
+		}
+	}
+
+	t.Run("created", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("first-value"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeCreated, result.Outcome)
+		assert.Equal(t, "ConfigMap", result.GVK.Kind)
+		assert.Equal(t, "registration-service", result.Name)
+		assert.Equal(t, "toolchain-host-operator", result.Namespace)
+		assert.Empty(t, result.Reason)
+		assert.Empty(t, result.OldResourceVersion)
+		assert.Equal(t, int64(1), result.NewGeneration)
+		assert.NotEmpty(t, result.NewResourceVersion)
+		assert.True(t, result.CreatedOrUpdated())
+	})
+
+	t.Run("updated without generation change", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		sa := func(label string) *corev1.ServiceAccount {
+			return &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "toolchaincluster-host",
+					Namespace: "toolchain-host-operator",
+					Labels:    map[string]string{"version": label},
+				},
+			}
+		}
+		_, err := cl.ApplyObject(context.TODO(), sa("first"))
+		require.NoError(t, err)
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), sa("second"))
+		createdOrUpdated, err2 := cl.ApplyObject(context.TODO(), sa("third"))
+
+		// then
+		require.NoError(t, err)
+		require.NoError(t, err2)
+		assert.False(t, createdOrUpdated) // the boolean only reports the changes of the generation
+		// the server doesn't increment the generation of ServiceAccounts, but the object was modified
+		assert.Equal(t, client.ApplyOutcomeUpdated, result.Outcome)
+		assert.Equal(t, result.OldGeneration, result.NewGeneration)
+		assert.NotEqual(t, result.OldResourceVersion, result.NewResourceVersion)
+		assert.True(t, result.CreatedOrUpdated())
+	})
+
+	t.Run("updated because of changed configuration", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newCm("first-value"))
+		require.NoError(t, err)
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("second-value"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeUpdated, result.Outcome)
+		assert.Equal(t, client.ApplyReasonConfigurationChanged, result.Reason)
+		assert.Equal(t, int64(1), result.OldGeneration)
+		assert.Equal(t, int64(2), result.NewGeneration)
+		assert.NotEqual(t, result.OldResourceVersion, result.NewResourceVersion)
+		assert.True(t, result.CreatedOrUpdated())
+	})
+
+	t.Run("skipped because of same configuration", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newCm("first-value"))
+		require.NoError(t, err)
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("first-value"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeSkipped, result.Outcome)
+		assert.Empty(t, result.Reason)
+		assert.Equal(t, result.OldGeneration, result.NewGeneration)
+		assert.Equal(t, result.OldResourceVersion, result.NewResourceVersion)
+		assert.False(t, result.CreatedOrUpdated())
+	})
+
+	t.Run("unchanged when forced", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newCm("first-value"))
+		require.NoError(t, err)
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("first-value"), client.ForceUpdate(true))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeUnchanged, result.Outcome)
+		assert.Equal(t, client.ApplyReasonForceUpdate, result.Reason)
+		assert.Equal(t, result.OldGeneration, result.NewGeneration)
+		assert.False(t, result.CreatedOrUpdated())
+	})
+
+	t.Run("updated when configuration is not saved", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newCm("first-value"), client.SaveConfiguration(false))
+		require.NoError(t, err)
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("second-value"), client.SaveConfiguration(false))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeUpdated, result.Outcome)
+		assert.Equal(t, client.ApplyReasonNoLastAppliedConfiguration, result.Reason)
+	})
+
+	t.Run("apply with results", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newCm("first-value"))
+		require.NoError(t, err)
+		other := newCm("first-value")
+		other.Name = "other"
+
+		// when
+		results, err := cl.ApplyWithResults(context.TODO(), []runtimeclient.Object{newCm("first-value"), other}, map[string]string{"foo": "bar"})
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, client.ApplyOutcomeUpdated, results[0].Outcome) // only the labels changed, so the generation is the same, but not the resourceVersion
+		assert.Equal(t, client.ApplyOutcomeCreated, results[1].Outcome)
+		assert.True(t, client.AnyCreatedOrUpdated(results))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		cli.MockCreate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+			return errors.New("mock error")
+		}
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("first-value"))
+
+		// then
+		require.EqualError(t, err, "unable to create resource of kind: , version: : mock error")
+		assert.Nil(t, result)
+		assert.False(t, result.CreatedOrUpdated())
+	})
+}
+
+func TestUpdateStrategies(t *testing.T) {
+	// given
+	addToScheme(t)
+	newPVC := func(storage string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "data",
+				Namespace: "john-dev",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(storage)},
+				},
+			},
+		}
+	}
+	pvcGVK := corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim")
+
+	t.Run("retain fields of the existing object", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		cl.RegisterUpdateStrategy(pvcGVK, client.RetainFields([]string{"spec", "volumeName"}))
+		existing := newPVC("1Gi")
+		existing.Spec.VolumeName = "pv-123"
+		require.NoError(t, cli.Create(context.TODO(), existing))
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newPVC("2Gi"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeUpdated, result.Outcome)
+		actual := &corev1.PersistentVolumeClaim{}
+		require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Name: "data", Namespace: "john-dev"}, actual))
+		assert.Equal(t, "pv-123", actual.Spec.VolumeName)
+		assert.Equal(t, resource.MustParse("2Gi"), actual.Spec.Resources.Requests[corev1.ResourceStorage])
+	})
+
+	t.Run("recreate the existing object", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		cl.RegisterUpdateStrategy(pvcGVK, client.Recreate)
+		_, err := cl.ApplyObject(context.TODO(), newPVC("1Gi"))
+		require.NoError(t, err)
+		existing := &corev1.PersistentVolumeClaim{}
+		require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Name: "data", Namespace: "john-dev"}, existing))
+		existing.SetUID("initial-uid")
+		require.NoError(t, cli.Update(context.TODO(), existing))
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newPVC("2Gi"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeRecreated, result.Outcome)
+		assert.Equal(t, client.ApplyReasonConfigurationChanged, result.Reason)
+		assert.True(t, result.CreatedOrUpdated())
+		actual := &corev1.PersistentVolumeClaim{}
+		require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Name: "data", Namespace: "john-dev"}, actual))
+		assert.NotEqual(t, types.UID("initial-uid"), actual.UID)
+		assert.Equal(t, resource.MustParse("2Gi"), actual.Spec.Resources.Requests[corev1.ResourceStorage])
+	})
+
+	t.Run("skip the update of the existing object", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		cl.RegisterUpdateStrategy(pvcGVK, client.SkipUpdate)
+		_, err := cl.ApplyObject(context.TODO(), newPVC("1Gi"))
+		require.NoError(t, err)
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newPVC("2Gi"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeSkipped, result.Outcome)
+		assert.False(t, result.CreatedOrUpdated())
+		actual := &corev1.PersistentVolumeClaim{}
+		require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Name: "data", Namespace: "john-dev"}, actual))
+		assert.Equal(t, resource.MustParse("1Gi"), actual.Spec.Resources.Requests[corev1.ResourceStorage])
+	})
+
+	t.Run("strategy replaces the default one", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		cl.RegisterUpdateStrategy(corev1.SchemeGroupVersion.WithKind("ServiceAccount"), func(desired, _ runtimeclient.Object) (runtimeclient.Object, client.UpdateAction, error) {
+			return desired, client.UpdateActionUpdate, nil
+		})
+		existing := newSA()
+		existing.Secrets = []corev1.ObjectReference{{Name: "secret", Namespace: existing.Namespace}}
+		require.NoError(t, cli.Create(context.TODO(), existing))
+
+		// when
+		_, err := cl.ApplyObject(context.TODO(), newSA())
+
+		// then
+		require.NoError(t, err)
+		actual := &corev1.ServiceAccount{}
+		require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(existing), actual))
+		assert.Empty(t, actual.Secrets)
+	})
+
+	t.Run("client not created by NewApplyClient", func(t *testing.T) {
+		t.Run("uses the default strategies", func(t *testing.T) {
+			// given
+			cli := NewFakeClient(t)
+			cl := client.ApplyClient{Client: cli}
+			existing := newSA()
+			existing.Secrets = []corev1.ObjectReference{{Name: "secret", Namespace: existing.Namespace}}
+			require.NoError(t, cli.Create(context.TODO(), existing))
+
+			// when
+			_, err := cl.ApplyObject(context.TODO(), newSA())
+
+			// then
+			require.NoError(t, err)
+			actual := &corev1.ServiceAccount{}
+			require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(existing), actual))
+			assert.Equal(t, existing.Secrets, actual.Secrets)
+		})
+
+		t.Run("registers a strategy on top of the default ones", func(t *testing.T) {
+			// given
+			cli := NewFakeClient(t)
+			cl := client.ApplyClient{Client: cli}
+			cl.RegisterUpdateStrategy(pvcGVK, client.SkipUpdate)
+			_, err := cl.ApplyObject(context.TODO(), newPVC("1Gi"))
+			require.NoError(t, err)
+			existing := newSA()
+			existing.Secrets = []corev1.ObjectReference{{Name: "secret", Namespace: existing.Namespace}}
+			require.NoError(t, cli.Create(context.TODO(), existing))
+
+			// when
+			pvcResult, err := cl.ApplyObjectWithResult(context.TODO(), newPVC("2Gi"))
+			require.NoError(t, err)
+			_, err = cl.ApplyObject(context.TODO(), newSA())
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplyOutcomeSkipped, pvcResult.Outcome)
+			actual := &corev1.ServiceAccount{}
+			require.NoError(t, cli.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(existing), actual))
+			assert.Equal(t, existing.Secrets, actual.Secrets)
+		})
+	})
+
+	t.Run("strategy fails", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		cl.RegisterUpdateStrategy(pvcGVK, func(_, _ runtimeclient.Object) (runtimeclient.Object, client.UpdateAction, error) {
+			return nil, "", errors.New("mock error")
+		})
+		require.NoError(t, cli.Create(context.TODO(), newPVC("1Gi")))
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newPVC("2Gi"))
+
+		// then
+		require.EqualError(t, err, "unable to create resource of kind: , version: : unable to apply the update strategy for the resource 'data' of kind 'PersistentVolumeClaim': mock error")
+		assert.Nil(t, result)
+	})
+}
+
+func TestRecreateOnImmutableChange(t *testing.T) {
+	// given
+	addToScheme(t)
+	newJob := func(image string) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "migration",
+				Namespace: "toolchain-host-operator",
+			},
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "migration", Image: image}},
+					},
+				},
+			},
+		}
+	}
+	immutableErr := apierrors.NewInvalid(schema.GroupKind{Group: "batch", Kind: "Job"}, "migration", field.ErrorList{
+		field.Invalid(field.NewPath("spec", "template"), "", "field is immutable"),
+	})
+
+	t.Run("recreates the object", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newJob("image:1"))
+		require.NoError(t, err)
+		cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+			return immutableErr
+		}
+		var deleteOpts []runtimeclient.DeleteOption
+		cli.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+			deleteOpts = opts
+			return cli.Client.Delete(ctx, obj, opts...)
+		}
+
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), newJob("image:2"), client.RecreateOnImmutableChange(metav1.DeletePropagationBackground))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, client.ApplyOutcomeRecreated, result.Outcome)
+		assert.Equal(t, client.ApplyReasonImmutableFieldChanged, result.Reason)
+		assert.True(t, result.CreatedOrUpdated())
+		assert.Equal(t, []runtimeclient.DeleteOption{runtimeclient.PropagationPolicy(metav1.DeletePropagationBackground)}, deleteOpts)
+		actual := &batchv1.Job{}
+		require.NoError(t, cli.Get(context.TODO(), types.NamespacedName{Name: "migration", Namespace: "toolchain-host-operator"}, actual))
+		assert.Equal(t, "image:2", actual.Spec.Template.Spec.Containers[0].Image)
+	})
+
+	t.Run("returns the error when not enabled", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newJob("image:1"))
+		require.NoError(t, err)
+		cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+			return immutableErr
+		}
+
+		// when
+		_, err = cl.ApplyObject(context.TODO(), newJob("image:2"))
+
+		// then
+		require.Error(t, err)
+		assert.True(t, client.IsImmutableFieldError(err))
+	})
+
+	t.Run("returns other errors", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newJob("image:1"))
+		require.NoError(t, err)
+		cli.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+			return apierrors.NewInvalid(schema.GroupKind{Group: "batch", Kind: "Job"}, "migration", field.ErrorList{
+				field.Required(field.NewPath("spec", "template"), "missing"),
+			})
+		}
+
+		// when
+		_, err = cl.ApplyObject(context.TODO(), newJob("image:2"), client.RecreateOnImmutableChange(metav1.DeletePropagationBackground))
+
+		// then
+		require.Error(t, err)
+		assert.True(t, apierrors.IsInvalid(err))
+		assert.False(t, client.IsImmutableFieldError(err))
+	})
+}
+
+func TestEncodeConfiguration(t *testing.T) {
+	// given
+	addToScheme(t)
+	newCm := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "registration-service",
+				Namespace: "toolchain-host-operator",
+			},
+			Data: map[string]string{
+				"first-param": value,
+			},
+		}
+	}
+	getAnnotation := func(t *testing.T, cl runtimeclient.Client) string {
+		cm := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(newCm("")), cm))
+		return cm.Annotations[client.LastAppliedConfigurationAnnotationKey]
+	}
+
+	for _, encoding := range []client.ConfigurationEncoding{client.ConfigurationEncodingGzip, client.ConfigurationEncodingHash} {
+		t.Run(string(encoding), func(t *testing.T) {
+			t.Run("stores the encoded configuration", func(t *testing.T) {
+				// given
+				cl, cli := newClient(t)
+
+				// when
+				_, err := cl.ApplyObject(context.TODO(), newCm("first-value"), client.EncodeConfiguration(encoding))
+
+				// then
+				require.NoError(t, err)
+				annotation := getAnnotation(t, cli)
+				assert.True(t, strings.HasPrefix(annotation, string(encoding)+":"))
+				decoded, decodable, err := client.DecodeConfiguration(annotation)
+				require.NoError(t, err)
+				if encoding == client.ConfigurationEncodingGzip {
+					assert.True(t, decodable)
+					assert.Equal(t, client.GetNewConfiguration(newCm("first-value")), decoded)
+				} else {
+					assert.False(t, decodable)
+				}
+			})
+
+			t.Run("skips the same configuration", func(t *testing.T) {
+				// given
+				cl, _ := newClient(t)
+				_, err := cl.ApplyObject(context.TODO(), newCm("first-value"), client.EncodeConfiguration(encoding))
+				require.NoError(t, err)
+
+				// when
+				result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("first-value"), client.EncodeConfiguration(encoding))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeSkipped, result.Outcome)
+			})
+
+			t.Run("updates the changed configuration", func(t *testing.T) {
+				// given
+				cl, _ := newClient(t)
+				_, err := cl.ApplyObject(context.TODO(), newCm("first-value"), client.EncodeConfiguration(encoding))
+				require.NoError(t, err)
+
+				// when
+				result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("second-value"), client.EncodeConfiguration(encoding))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeUpdated, result.Outcome)
+				assert.Equal(t, client.ApplyReasonConfigurationChanged, result.Reason)
+			})
+
+			t.Run("migrates the plain configuration", func(t *testing.T) {
+				// given
+				cl, cli := newClient(t)
+				_, err := cl.ApplyObject(context.TODO(), newCm("first-value"))
+				require.NoError(t, err)
+				require.Equal(t, client.GetNewConfiguration(newCm("first-value")), getAnnotation(t, cli))
+
+				// when
+				result, err := cl.ApplyObjectWithResult(context.TODO(), newCm("first-value"), client.EncodeConfiguration(encoding))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyReasonConfigurationEncodingChanged, result.Reason)
+				assert.True(t, strings.HasPrefix(getAnnotation(t, cli), string(encoding)+":"))
+
+				// and the next apply is skipped
+				result, err = cl.ApplyObjectWithResult(context.TODO(), newCm("first-value"), client.EncodeConfiguration(encoding))
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeSkipped, result.Outcome)
+			})
+		})
+	}
+
+	t.Run("auto", func(t *testing.T) {
+		t.Run("keeps small configuration as plain JSON", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+
+			// when
+			_, err := cl.ApplyObject(context.TODO(), newCm("first-value"), client.EncodeConfiguration(client.ConfigurationEncodingAuto))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.GetNewConfiguration(newCm("first-value")), getAnnotation(t, cli))
+		})
+
+		t.Run("compresses large configuration", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+
+			// when
+			_, err := cl.ApplyObject(context.TODO(), newCm(strings.Repeat("a", client.MaxConfigurationSize)), client.EncodeConfiguration(client.ConfigurationEncodingAuto))
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(getAnnotation(t, cli), "gzip:"))
+		})
+
+		t.Run("hashes large incompressible configuration", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			data := make([]byte, client.MaxConfigurationSize)
+			_, err := rand.Read(data)
+			require.NoError(t, err)
+
+			// when
+			_, err = cl.ApplyObject(context.TODO(), newCm(base64.StdEncoding.EncodeToString(data)), client.EncodeConfiguration(client.ConfigurationEncodingAuto))
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(getAnnotation(t, cli), "sha256:"))
+		})
+	})
+
+	t.Run("client encoding", func(t *testing.T) {
+		t.Run("migrates the plain configuration with Apply", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.Apply(context.TODO(), []runtimeclient.Object{newCm("first-value")}, map[string]string{"foo": "bar"})
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(getAnnotation(t, cli), "{"))
+			cl.SetConfigurationEncoding(client.ConfigurationEncodingGzip)
+
+			// when
+			createdOrUpdated, err := cl.Apply(context.TODO(), []runtimeclient.Object{newCm("first-value")}, map[string]string{"foo": "bar"})
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, createdOrUpdated) // only the annotation was modified, so the generation was not incremented
+			annotation := getAnnotation(t, cli)
+			assert.True(t, strings.HasPrefix(annotation, "gzip:"))
+			decoded, _, err := client.DecodeConfiguration(annotation)
+			require.NoError(t, err)
+			assert.Contains(t, decoded, `"first-param":"first-value"`)
+
+			// and the next apply doesn't change anything
+			createdOrUpdated, err = cl.Apply(context.TODO(), []runtimeclient.Object{newCm("first-value")}, map[string]string{"foo": "bar"})
+			require.NoError(t, err)
+			assert.False(t, createdOrUpdated)
+		})
+
+		t.Run("migrates the plain configuration with ApplyAndPrune", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			_, err := cl.ApplyAndPrune(context.TODO(), []runtimeclient.Object{newCm("first-value")}, nil, "group")
+			require.NoError(t, err)
+			cl.SetConfigurationEncoding(client.ConfigurationEncodingHash)
+
+			// when
+			createdOrUpdated, err := cl.ApplyAndPrune(context.TODO(), []runtimeclient.Object{newCm("first-value")}, nil, "group")
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, createdOrUpdated) // only the annotation was modified, so the generation was not incremented
+			assert.True(t, strings.HasPrefix(getAnnotation(t, cli), "sha256:"))
+
+			// and the next apply doesn't change anything
+			results, err := cl.ApplyWithResults(context.TODO(), []runtimeclient.Object{newCm("first-value")}, map[string]string{client.ApplyGroupLabelKey: "group"})
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, client.ApplyOutcomeUnchanged, results[0].Outcome)
+		})
+
+		t.Run("the option takes precedence", func(t *testing.T) {
+			// given
+			cl, cli := newClient(t)
+			cl.SetConfigurationEncoding(client.ConfigurationEncodingHash)
+
+			// when
+			_, err := cl.ApplyObject(context.TODO(), newCm("first-value"), client.EncodeConfiguration(client.ConfigurationEncodingJSON))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.GetNewConfiguration(newCm("first-value")), getAnnotation(t, cli))
+		})
+	})
+
+	t.Run("unknown encoding", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+
+		// when
+		_, err := cl.ApplyObject(context.TODO(), newCm("first-value"), client.EncodeConfiguration("unknown"))
+
+		// then
+		require.EqualError(t, err, "unable to create resource of kind: , version: : unknown encoding of the last applied configuration: 'unknown'")
+	})
+}
+
 func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
 	content, err := json.Marshal(obj)
 	if err != nil {
@@ -750,6 +1391,52 @@ func TestProcessAndApply(t *testing.T) {
 		assert.False(t, updated)
 	})
 
+	t.Run("should prune role binding removed from the template", func(t *testing.T) {
+		// given
+		cl := NewFakeClient(t)
+		p := template.NewProcessor(s)
+		tmpl, err := DecodeTemplate(decoder,
+			CreateTemplate(WithObjects(Namespace, RoleBinding), WithParams(UsernameParam, CommitParam)))
+		require.NoError(t, err)
+		objs, err := p.Process(tmpl, values)
+		require.NoError(t, err)
+		labels := newLabels("base1ns", "john", "dev")
+		createdOrUpdated, err := client.NewApplyClient(cl).ApplyAndPrune(context.TODO(), objs, labels, "john")
+		require.NoError(t, err)
+		assert.True(t, createdOrUpdated)
+		rb := assertRoleBindingExists(t, cl, user, withApplyGroup(labels, "john"))
+
+		// when the role binding is removed from the template
+		tmpl, err = DecodeTemplate(decoder,
+			CreateTemplate(WithObjects(Namespace), WithParams(UsernameParam, CommitParam)))
+		require.NoError(t, err)
+		objs, err = p.Process(tmpl, values)
+		require.NoError(t, err)
+
+		t.Run("dry run", func(t *testing.T) {
+			// when
+			pruned, err := client.Prune(context.TODO(), cl, "john", objs, client.PruneKinds(rb.GroupVersionKind()), client.PruneDryRun(true))
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, pruned, 1)
+			assert.Equal(t, rb.Name, pruned[0].GetName())
+			assertRoleBindingExists(t, cl, user, withApplyGroup(labels, "john"))
+		})
+
+		t.Run("prune", func(t *testing.T) {
+			// when
+			createdOrUpdated, err := client.NewApplyClient(cl).ApplyAndPrune(context.TODO(), objs, labels, "john", client.PruneKinds(rb.GroupVersionKind()))
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, createdOrUpdated)
+			assertNamespaceExists(t, cl, user, withApplyGroup(labels, "john"), commit)
+			err = cl.Get(context.TODO(), types.NamespacedName{Namespace: rb.Namespace, Name: rb.Name}, &authv1.RoleBinding{})
+			require.True(t, apierrors.IsNotFound(err))
+		})
+	})
+
 	t.Run("failures", func(t *testing.T) {
 
 		t.Run("should fail to create template object", func(t *testing.T) {
@@ -1050,6 +1737,16 @@ func assertRoleBindingExists(t *testing.T, c runtimeclient.Client, ns string, la
 	return rb
 }
 
+func withApplyGroup(labels map[string]string, group string) map[string]string {
+	groupLabels := map[string]string{
+		client.ApplyGroupLabelKey: group,
+	}
+	for k, v := range labels {
+		groupLabels[k] = v
+	}
+	return groupLabels
+}
+
 func newLabels(tier, username, nsType string) map[string]string {
 	labels := map[string]string{
 		"toolchain.dev.openshift.com/provider": "codeready-toolchain",
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ConfigurationEncoding defines how the last applied configuration is stored in the LastAppliedConfigurationAnnotationKey annotation
+type ConfigurationEncoding string
+
+const (
+	// ConfigurationEncodingJSON stores the configuration as plain JSON
+	ConfigurationEncodingJSON ConfigurationEncoding = "json"
+	// ConfigurationEncodingGzip stores the configuration as gzip-compressed and base64-encoded JSON, prefixed with `gzip:`
+	ConfigurationEncodingGzip ConfigurationEncoding = "gzip"
+	// ConfigurationEncodingHash stores only the SHA-256 hash of the JSON configuration, prefixed with `sha256:`.
+	// It's still possible to tell whether the configuration changed, but the drift of the individual fields can't be detected.
+	ConfigurationEncodingHash ConfigurationEncoding = "sha256"
+	// ConfigurationEncodingAuto stores the configuration as plain JSON unless it exceeds the MaxConfigurationSize,
+	// in which case it's compressed. If the compressed configuration is still too large, then only its hash is stored.
+	ConfigurationEncodingAuto ConfigurationEncoding = "auto"
+)
+
+// MaxConfigurationSize is the maximum size of the last applied configuration annotation when using the ConfigurationEncodingAuto.
+// The total size of all the annotations of an object is limited to 256KiB, so keep some space for the other annotations.
+const MaxConfigurationSize = 128 * 1024
+
+const (
+	gzipConfigurationPrefix = "gzip:"
+	hashConfigurationPrefix = "sha256:"
+)
+
+// encodeConfiguration encodes the given JSON configuration using the given encoding
+func encodeConfiguration(configuration string, encoding ConfigurationEncoding) (string, error) {
+	switch encoding {
+	case ConfigurationEncodingJSON, "":
+		return configuration, nil
+	case ConfigurationEncodingGzip:
+		return gzipConfiguration(configuration)
+	case ConfigurationEncodingHash:
+		return hashConfiguration(configuration), nil
+	case ConfigurationEncodingAuto:
+		if len(configuration) <= MaxConfigurationSize {
+			return configuration, nil
+		}
+		compressed, err := gzipConfiguration(configuration)
+		if err != nil {
+			return "", err
+		}
+		if len(compressed) <= MaxConfigurationSize {
+			return compressed, nil
+		}
+		return hashConfiguration(configuration), nil
+	default:
+		return "", fmt.Errorf("unknown encoding of the last applied configuration: '%s'", encoding)
+	}
+}
+
+func gzipConfiguration(configuration string) (string, error) {
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	if _, err := writer.Write([]byte(configuration)); err != nil {
+		return "", fmt.Errorf("unable to compress the last applied configuration: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("unable to compress the last applied configuration: %w", err)
+	}
+	return gzipConfigurationPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func hashConfiguration(configuration string) string {
+	hash := sha256.Sum256([]byte(configuration))
+	return hashConfigurationPrefix + hex.EncodeToString(hash[:])
+}
+
+// configurationEncodingOf returns the encoding of the given last applied configuration
+func configurationEncodingOf(lastApplied string) ConfigurationEncoding {
+	switch {
+	case strings.HasPrefix(lastApplied, gzipConfigurationPrefix):
+		return ConfigurationEncodingGzip
+	case strings.HasPrefix(lastApplied, hashConfigurationPrefix):
+		return ConfigurationEncodingHash
+	default:
+		return ConfigurationEncodingJSON
+	}
+}
+
+// DecodeConfiguration returns the JSON configuration stored in the last applied configuration annotation, whatever its encoding is.
+// It returns `false` if the annotation contains only the hash of the configuration, which cannot be decoded.
+func DecodeConfiguration(lastApplied string) (string, bool, error) {
+	switch configurationEncodingOf(lastApplied) {
+	case ConfigurationEncodingGzip:
+		compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(lastApplied, gzipConfigurationPrefix))
+		if err != nil {
+			return "", false, fmt.Errorf("unable to decode the last applied configuration: %w", err)
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return "", false, fmt.Errorf("unable to decompress the last applied configuration: %w", err)
+		}
+		configuration, err := io.ReadAll(reader)
+		if err != nil {
+			return "", false, fmt.Errorf("unable to decompress the last applied configuration: %w", err)
+		}
+		return string(configuration), true, nil
+	case ConfigurationEncodingHash:
+		return "", false, nil
+	default:
+		return lastApplied, true, nil
+	}
+}
+
+// configurationMatches checks if the given last applied configuration (in any encoding) matches the given JSON configuration
+func configurationMatches(lastApplied, configuration string) (bool, error) {
+	if configurationEncodingOf(lastApplied) == ConfigurationEncodingHash {
+		return lastApplied == hashConfiguration(configuration), nil
+	}
+	decoded, _, err := DecodeConfiguration(lastApplied)
+	if err != nil {
+		return false, err
+	}
+	return decoded == configuration, nil
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ignoredMetadataFields are maintained by the API server and are therefore not part of the diff
+var ignoredMetadataFields = []string{"managedFields", "resourceVersion", "generation", "uid", "creationTimestamp"}
+
+// FieldDiff is a difference of a single field between the live object and the object resulting from the apply
+type FieldDiff struct {
+	// Path is the dot-separated path to the field, eg. `spec.selector.app`
+	Path string
+	// Old is the value of the field in the live object (nil for added fields)
+	Old interface{}
+	// New is the value of the field after the apply (nil for removed fields)
+	New interface{}
+}
+
+// ObjectDiff is a structured difference between the live object and the object that would result from the apply
+type ObjectDiff struct {
+	GVK       schema.GroupVersionKind
+	Namespace string
+	Name      string
+	// Created is `true` if the object doesn't exist in the cluster yet and would be created
+	Created bool
+	// Skipped is `true` if the apply of the object would be skipped because of the SkipIf option
+	Skipped bool
+	// Pruned is `true` if the object would be deleted because it is no longer part of its apply group
+	Pruned bool
+	// Recreated is `true` if the object would be deleted and created again because of a change of an immutable field
+	Recreated bool
+	Added     []FieldDiff
+	Changed   []FieldDiff
+	Removed   []FieldDiff
+}
+
+// HasChanges returns `true` if the apply would create or modify the object
+func (d ObjectDiff) HasChanges() bool {
+	return d.Created || d.Pruned || d.Recreated || len(d.Added) > 0 || len(d.Changed) > 0 || len(d.Removed) > 0
+}
+
+// DryRunReport collects the diffs of all the objects applied with the DryRun option
+type DryRunReport struct {
+	mutex sync.Mutex
+	// Diffs contains the diff of every object in the order they were applied
+	Diffs []ObjectDiff
+}
+
+func (r *DryRunReport) add(diff ObjectDiff) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Diffs = append(r.Diffs, diff)
+}
+
+// HasChanges returns `true` if the apply would create or modify at least one of the objects
+func (r *DryRunReport) HasChanges() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, diff := range r.Diffs {
+		if diff.HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// newObjectDiff computes the diff between the live object (nil if the object doesn't exist) and the resulting object
+func newObjectDiff(live, result client.Object) (ObjectDiff, error) {
+	diff := ObjectDiff{
+		GVK:       result.GetObjectKind().GroupVersionKind(),
+		Namespace: result.GetNamespace(),
+		Name:      result.GetName(),
+		Created:   live == nil,
+	}
+	resultContent, err := diffableContent(result)
+	if err != nil {
+		return diff, err
+	}
+	liveContent := map[string]interface{}{}
+	if live != nil {
+		if liveContent, err = diffableContent(live); err != nil {
+			return diff, err
+		}
+	}
+	diffValues(&diff, nil, liveContent, resultContent)
+	for _, fields := range [][]FieldDiff{diff.Added, diff.Changed, diff.Removed} {
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Path < fields[j].Path
+		})
+	}
+	return diff, nil
+}
+
+func diffableContent(obj client.Object) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert the object '%s' to unstructured content: %w", obj.GetName(), err)
+	}
+	// the status is not applied by the clients, so it's not part of the diff
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range ignoredMetadataFields {
+			delete(metadata, field)
+		}
+	}
+	return content, nil
+}
+
+func diffValues(diff *ObjectDiff, path []string, live, result interface{}) {
+	if isEmptyValue(live) && isEmptyValue(result) {
+		return
+	}
+	oldMap, oldIsMap := live.(map[string]interface{})
+	newMap, newIsMap := result.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := map[string]bool{}
+		for key := range oldMap {
+			keys[key] = true
+		}
+		for key := range newMap {
+			keys[key] = true
+		}
+		for key := range keys {
+			oldValue, inOld := oldMap[key]
+			newValue, inNew := newMap[key]
+			fieldPath := append(append([]string{}, path...), key)
+			switch {
+			case inOld && inNew:
+				diffValues(diff, fieldPath, oldValue, newValue)
+			case inNew && !isEmptyValue(newValue):
+				diff.Added = append(diff.Added, FieldDiff{Path: strings.Join(fieldPath, "."), New: newValue})
+			case inOld && !isEmptyValue(oldValue):
+				diff.Removed = append(diff.Removed, FieldDiff{Path: strings.Join(fieldPath, "."), Old: oldValue})
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(live, result) {
+		diff.Changed = append(diff.Changed, FieldDiff{Path: strings.Join(path, "."), Old: live, New: result})
+	}
+}
+
+// isEmptyValue returns true for values that are equivalent to a missing field
+func isEmptyValue(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(value) == 0
+	default:
+		return false
+	}
+}
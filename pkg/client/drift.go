@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DriftReport describes the differences between the object in the cluster and the configuration that was last applied
+// by the ApplyClient (see the LastAppliedConfigurationAnnotationKey annotation)
+type DriftReport struct {
+	GVK       schema.GroupVersionKind
+	Namespace string
+	Name      string
+	// Missing is `true` if the object doesn't exist in the cluster
+	Missing bool
+	// NoLastAppliedConfiguration is `true` if the object in the cluster has no last applied configuration (or if only
+	// the hash of the configuration is stored), so it's not possible to tell if it was modified out of band
+	NoLastAppliedConfiguration bool
+	// ConfigurationChanged is `true` if the desired object differs from the last applied configuration,
+	// ie. if the next apply would update the object
+	ConfigurationChanged bool
+	// Drifted contains the fields which were set by the last apply, but which were modified out of band
+	// by users or other controllers. The Old value is the last applied one, the New value is the one in the cluster.
+	Drifted []FieldDiff
+}
+
+// HasDrift returns `true` if the object is missing in the cluster or if any of the last applied fields were modified out of band
+func (r *DriftReport) HasDrift() bool {
+	return r.Missing || len(r.Drifted) > 0
+}
+
+// DetectDrift compares the desired object, the configuration that was last applied to the cluster (stored in the
+// LastAppliedConfigurationAnnotationKey annotation) and the live object in the cluster. It reports the fields which were
+// set by the last apply but which were modified out of band, and whether the desired object differs from the last applied
+// configuration. The fields that are not part of the last applied configuration (eg. the status, the fields defaulted by
+// the server or the annotations and labels added by other parties) are not considered.
+// Nothing is modified in the cluster and the desired object is left untouched.
+func (c ApplyClient) DetectDrift(ctx context.Context, obj client.Object) (*DriftReport, error) {
+	gvk, err := gvkForObject(obj, c.Scheme())
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine the kind of the resource '%s': %w", obj.GetName(), err)
+	}
+	report := &DriftReport{
+		GVK:       gvk,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+
+	live := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if apierrors.IsNotFound(err) {
+			report.Missing = true
+			report.ConfigurationChanged = true
+			return report, nil
+		}
+		return nil, fmt.Errorf("unable to get the resource '%s' of kind '%s' in namespace '%s': %w", obj.GetName(), gvk.Kind, obj.GetNamespace(), err)
+	}
+
+	lastApplied, found := live.GetAnnotations()[LastAppliedConfigurationAnnotationKey]
+	if !found {
+		report.NoLastAppliedConfiguration = true
+		report.ConfigurationChanged = true
+		return report, nil
+	}
+	// this is the same check as the one done by the ApplyObject method to decide whether the object should be updated
+	matches, err := configurationMatches(lastApplied, GetNewConfiguration(obj))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the last applied configuration of the resource '%s' of kind '%s' in namespace '%s': %w", obj.GetName(), gvk.Kind, obj.GetNamespace(), err)
+	}
+	report.ConfigurationChanged = !matches
+	decoded, decodable, err := DecodeConfiguration(lastApplied)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the last applied configuration of the resource '%s' of kind '%s' in namespace '%s': %w", obj.GetName(), gvk.Kind, obj.GetNamespace(), err)
+	}
+	if !decodable {
+		// only the hash of the configuration is stored, so the drift can't be detected
+		report.NoLastAppliedConfiguration = true
+		return report, nil
+	}
+	lastAppliedContent := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(decoded), &lastAppliedContent); err != nil {
+		return nil, fmt.Errorf("unable to parse the last applied configuration of the resource '%s' of kind '%s' in namespace '%s': %w", obj.GetName(), gvk.Kind, obj.GetNamespace(), err)
+	}
+
+	liveContent, err := jsonContent(live)
+	if err != nil {
+		return nil, err
+	}
+	removeIgnoredFields(lastAppliedContent)
+	removeIgnoredFields(liveContent)
+
+	collectDrift(report, nil, lastAppliedContent, liveContent)
+	sort.Slice(report.Drifted, func(i, j int) bool {
+		return report.Drifted[i].Path < report.Drifted[j].Path
+	})
+	return report, nil
+}
+
+// jsonContent returns the content of the object as it is when serialized to JSON, so that it can be compared with
+// the last applied configuration (which is stored as JSON)
+func jsonContent(obj client.Object) (map[string]interface{}, error) {
+	data, err := marshalObjectContent(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the resource '%s': %w", obj.GetName(), err)
+	}
+	content := map[string]interface{}{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the resource '%s': %w", obj.GetName(), err)
+	}
+	return content, nil
+}
+
+// removeIgnoredFields removes the type information (which is not always set in typed objects), the status
+// and the metadata fields maintained by the API server
+func removeIgnoredFields(content map[string]interface{}) {
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range ignoredMetadataFields {
+			delete(metadata, field)
+		}
+	}
+}
+
+// collectDrift walks through the last applied values and records all those which differ from the live ones.
+// The fields which are present only in the live object are ignored, as they were not set by the apply.
+func collectDrift(report *DriftReport, path []string, lastApplied, live interface{}) {
+	if isEmptyValue(lastApplied) {
+		return
+	}
+	switch lastApplied := lastApplied.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok && live != nil {
+			break
+		}
+		// a missing map is the same as an empty one, so report the individual fields that were removed
+		for key, value := range lastApplied {
+			collectDrift(report, append(append([]string{}, path...), key), value, liveMap[key])
+		}
+		return
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok || len(liveList) != len(lastApplied) {
+			break
+		}
+		// the server may default some fields in the list items (eg. in the containers of a pod template),
+		// so compare the items one by one
+		for i := range lastApplied {
+			collectDrift(report, append(append([]string{}, path...), strconv.Itoa(i)), lastApplied[i], liveList[i])
+		}
+		return
+	default:
+		if reflect.DeepEqual(lastApplied, live) {
+			return
+		}
+	}
+	report.Drifted = append(report.Drifted, FieldDiff{Path: strings.Join(path, "."), Old: lastApplied, New: live})
+}
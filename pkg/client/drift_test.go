@@ -0,0 +1,207 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	. "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDetectDrift(t *testing.T) {
+	// given
+	addToScheme(t)
+	newDeployment := func(replicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "registration-service",
+				Namespace: HostOperatorNs,
+				Labels: map[string]string{
+					"app": "registration-service",
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.To(replicas),
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "registration-service", Image: "quay.io/reg-service:v1"}},
+					},
+				},
+			},
+		}
+	}
+	getDeployment := func(t *testing.T, cl runtimeclient.Client) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(newDeployment(1)), deployment))
+		return deployment
+	}
+
+	t.Run("no drift", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newDeployment(3))
+		require.NoError(t, err)
+		// fields that were not applied are ignored
+		deployment := getDeployment(t, cli)
+		deployment.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+		deployment.Spec.MinReadySeconds = 10
+		deployment.Labels["other"] = "value"
+		deployment.Status.Replicas = 3
+		require.NoError(t, cli.Update(context.TODO(), deployment))
+
+		// when
+		report, err := cl.DetectDrift(context.TODO(), newDeployment(3))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, report.HasDrift())
+		assert.False(t, report.ConfigurationChanged)
+		assert.False(t, report.Missing)
+		assert.False(t, report.NoLastAppliedConfiguration)
+		assert.Equal(t, "Deployment", report.GVK.Kind)
+		assert.Empty(t, report.Drifted)
+	})
+
+	t.Run("fields modified out of band", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newDeployment(3))
+		require.NoError(t, err)
+		deployment := getDeployment(t, cli)
+		deployment.Spec.Replicas = ptr.To[int32](5)
+		deployment.Spec.Template.Spec.Containers[0].Image = "quay.io/reg-service:dev"
+		delete(deployment.Labels, "app")
+		require.NoError(t, cli.Update(context.TODO(), deployment))
+
+		// when
+		report, err := cl.DetectDrift(context.TODO(), newDeployment(3))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, report.HasDrift())
+		assert.False(t, report.ConfigurationChanged)
+		assert.Equal(t, []client.FieldDiff{
+			{Path: "metadata.labels.app", Old: "registration-service", New: nil},
+			{Path: "spec.replicas", Old: float64(3), New: float64(5)},
+			{Path: "spec.template.spec.containers.0.image", Old: "quay.io/reg-service:v1", New: "quay.io/reg-service:dev"},
+		}, report.Drifted)
+	})
+
+	t.Run("compressed configuration", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newDeployment(3), client.EncodeConfiguration(client.ConfigurationEncodingGzip))
+		require.NoError(t, err)
+		deployment := getDeployment(t, cli)
+		deployment.Spec.Replicas = ptr.To[int32](5)
+		require.NoError(t, cli.Update(context.TODO(), deployment))
+
+		// when
+		report, err := cl.DetectDrift(context.TODO(), newDeployment(3))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, report.ConfigurationChanged)
+		assert.Equal(t, []client.FieldDiff{
+			{Path: "spec.replicas", Old: float64(3), New: float64(5)},
+		}, report.Drifted)
+	})
+
+	t.Run("hashed configuration", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newDeployment(3), client.EncodeConfiguration(client.ConfigurationEncodingHash))
+		require.NoError(t, err)
+
+		// when
+		report, err := cl.DetectDrift(context.TODO(), newDeployment(1))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, report.ConfigurationChanged)
+		assert.True(t, report.NoLastAppliedConfiguration)
+		assert.False(t, report.HasDrift())
+	})
+
+	t.Run("desired configuration changed", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newDeployment(3))
+		require.NoError(t, err)
+
+		// when
+		report, err := cl.DetectDrift(context.TODO(), newDeployment(1))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, report.HasDrift())
+		assert.True(t, report.ConfigurationChanged)
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+
+		// when
+		report, err := cl.DetectDrift(context.TODO(), newDeployment(3))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, report.HasDrift())
+		assert.True(t, report.Missing)
+		assert.True(t, report.ConfigurationChanged)
+	})
+
+	t.Run("no last applied configuration", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		require.NoError(t, cli.Create(context.TODO(), newDeployment(3)))
+
+		// when
+		report, err := cl.DetectDrift(context.TODO(), newDeployment(3))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, report.HasDrift())
+		assert.True(t, report.NoLastAppliedConfiguration)
+		assert.True(t, report.ConfigurationChanged)
+	})
+
+	t.Run("does not modify the desired object", func(t *testing.T) {
+		// given
+		cl, _ := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), newDeployment(3))
+		require.NoError(t, err)
+		desired := newDeployment(3)
+
+		// when
+		_, err = cl.DetectDrift(context.TODO(), desired)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, newDeployment(3), desired)
+	})
+
+	t.Run("get error", func(t *testing.T) {
+		// given
+		cl, cli := newClient(t)
+		cli.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			return errors.New("mock error")
+		}
+
+		// when
+		report, err := cl.DetectDrift(context.TODO(), newDeployment(3))
+
+		// then
+		require.EqualError(t, err, "unable to get the resource 'registration-service' of kind 'Deployment' in namespace 'toolchain-host-operator': mock error")
+		assert.Nil(t, report)
+	})
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ApplyGroupLabelKey the key of the label that marks all the objects applied together as a part of the same "apply group".
+// It is used for finding the objects that were applied before, but are not part of the applied set anymore.
+const ApplyGroupLabelKey = "toolchain.dev.openshift.com/apply-group"
+
+type pruneConfiguration struct {
+	kinds  []schema.GroupVersionKind
+	dryRun bool
+}
+
+func newPruneConfiguration(options ...PruneOption) pruneConfiguration {
+	config := pruneConfiguration{}
+	for _, apply := range options {
+		apply(&config)
+	}
+	return config
+}
+
+// PruneOption an option when pruning the objects of an apply group
+type PruneOption func(*pruneConfiguration)
+
+// PruneKinds sets the kinds of the objects that should be checked for stale objects (default: the kinds of the applied objects).
+// The kinds need to be specified explicitly if all objects of some kind could be removed from the applied set.
+// The kinds which are not served by the cluster are skipped.
+func PruneKinds(kinds ...schema.GroupVersionKind) PruneOption {
+	return func(config *pruneConfiguration) {
+		config.kinds = append(config.kinds, kinds...)
+	}
+}
+
+// PruneDryRun only lists the objects that would be pruned without actually deleting them (default: `false`)
+func PruneDryRun(dryRun bool) PruneOption {
+	return func(config *pruneConfiguration) {
+		config.dryRun = dryRun
+	}
+}
+
+// Prune deletes all the objects labeled with the given apply group that are not present in the given list of applied objects.
+// It returns the objects that were deleted, or the objects that would be deleted when the PruneDryRun option is used.
+func Prune[T client.Object](ctx context.Context, cl client.Client, group string, applied []T, options ...PruneOption) ([]client.Object, error) {
+	if group == "" {
+		return nil, fmt.Errorf("the apply group must not be empty")
+	}
+	config := newPruneConfiguration(options...)
+
+	appliedKeys := map[pruneKey]bool{}
+	var appliedKinds []schema.GroupVersionKind
+	for _, obj := range applied {
+		gvk, err := gvkForObject(obj, cl.Scheme())
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine the kind of the applied object '%s': %w", obj.GetName(), err)
+		}
+		appliedKeys[newPruneKey(gvk.GroupKind(), obj)] = true
+		appliedKinds = append(appliedKinds, gvk)
+	}
+
+	kinds := config.kinds
+	if len(kinds) == 0 {
+		kinds = appliedKinds
+	}
+
+	var pruned []client.Object
+	listedKinds := map[schema.GroupKind]bool{}
+	for _, gvk := range kinds {
+		if listedKinds[gvk.GroupKind()] {
+			continue
+		}
+		listedKinds[gvk.GroupKind()] = true
+
+		existing := &unstructured.UnstructuredList{}
+		existing.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := cl.List(ctx, existing, client.MatchingLabels{ApplyGroupLabelKey: group}); err != nil {
+			if meta.IsNoMatchError(err) {
+				// the kind is not served (anymore), so there is nothing to prune
+				continue
+			}
+			return pruned, fmt.Errorf("unable to list the objects of kind '%s' in the apply group '%s': %w", gvk, group, err)
+		}
+		for i := range existing.Items {
+			obj := &existing.Items[i]
+			if appliedKeys[newPruneKey(gvk.GroupKind(), obj)] || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			if !config.dryRun {
+				log.Info("pruning object", "object_namespace", obj.GetNamespace(), "object_name", gvk.Kind+"/"+obj.GetName(), "apply_group", group)
+				if err := cl.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+					return pruned, fmt.Errorf("unable to prune the object of kind '%s' called '%s' in namespace '%s': %w", gvk, obj.GetName(), obj.GetNamespace(), err)
+				}
+			}
+			pruned = append(pruned, obj)
+		}
+	}
+	return pruned, nil
+}
+
+type pruneKey struct {
+	groupKind schema.GroupKind
+	namespace string
+	name      string
+}
+
+func newPruneKey(groupKind schema.GroupKind, obj client.Object) pruneKey {
+	return pruneKey{
+		groupKind: groupKind,
+		namespace: obj.GetNamespace(),
+		name:      obj.GetName(),
+	}
+}
+
+// gvkForObject returns the GVK of the object without modifying it. If the GVK is not set, then it consults the scheme.
+func gvkForObject(obj runtime.Object, scheme *runtime.Scheme) (schema.GroupVersionKind, error) {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+		return gvk, nil
+	}
+	return apiutil.GVKForObject(obj, scheme)
+}
@@ -0,0 +1,182 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPrune(t *testing.T) {
+	newConfigMap := func(name, group string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+		}
+		if group != "" {
+			cm.Labels = map[string]string{client.ApplyGroupLabelKey: group}
+		}
+		return cm
+	}
+	newSecret := func(name, group string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{client.ApplyGroupLabelKey: group},
+			},
+		}
+	}
+
+	t.Run("deletes stale objects of the same group", func(t *testing.T) {
+		// given
+		applied := newConfigMap("applied", "group")
+		stale := newConfigMap("stale", "group")
+		otherGroup := newConfigMap("other-group", "other")
+		noGroup := newConfigMap("no-group", "")
+		cl := test.NewFakeClient(t, applied, stale, otherGroup, noGroup)
+
+		// when
+		pruned, err := client.Prune(context.TODO(), cl, "group", []runtimeclient.Object{newConfigMap("applied", "group")})
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, pruned, 1)
+		assert.Equal(t, "stale", pruned[0].GetName())
+		assertNotFound(t, cl, stale)
+		assertExists(t, cl, applied)
+		assertExists(t, cl, otherGroup)
+		assertExists(t, cl, noGroup)
+	})
+
+	t.Run("checks only the kinds of the applied objects by default", func(t *testing.T) {
+		// given
+		stale := newSecret("stale", "group")
+		cl := test.NewFakeClient(t, stale)
+
+		// when
+		pruned, err := client.Prune(context.TODO(), cl, "group", []runtimeclient.Object{newConfigMap("applied", "group")})
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, pruned)
+		assertExists(t, cl, stale)
+	})
+
+	t.Run("checks the explicitly provided kinds", func(t *testing.T) {
+		// given
+		stale := newSecret("stale", "group")
+		cl := test.NewFakeClient(t, stale)
+
+		// when
+		pruned, err := client.Prune(context.TODO(), cl, "group", []runtimeclient.Object{newConfigMap("applied", "group")},
+			client.PruneKinds(corev1.SchemeGroupVersion.WithKind("ConfigMap"), corev1.SchemeGroupVersion.WithKind("Secret")))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, pruned, 1)
+		assert.Equal(t, "stale", pruned[0].GetName())
+		assertNotFound(t, cl, stale)
+	})
+
+	t.Run("skips the kinds which are not served", func(t *testing.T) {
+		// given
+		stale := newConfigMap("stale", "group")
+		cl := test.NewFakeClient(t, stale)
+		unknown := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Unknown"}
+		cl.MockList = func(ctx context.Context, list runtimeclient.ObjectList, opts ...runtimeclient.ListOption) error {
+			if list.GetObjectKind().GroupVersionKind().Group == unknown.Group {
+				return &meta.NoKindMatchError{GroupKind: unknown.GroupKind(), SearchedVersions: []string{unknown.Version}}
+			}
+			return cl.Client.List(ctx, list, opts...)
+		}
+
+		// when
+		pruned, err := client.Prune(context.TODO(), cl, "group", []runtimeclient.Object{newConfigMap("applied", "group")},
+			client.PruneKinds(unknown, corev1.SchemeGroupVersion.WithKind("ConfigMap")))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, pruned, 1)
+		assertNotFound(t, cl, stale)
+	})
+
+	t.Run("dry run only lists the objects", func(t *testing.T) {
+		// given
+		stale := newConfigMap("stale", "group")
+		cl := test.NewFakeClient(t, stale)
+
+		// when
+		pruned, err := client.Prune(context.TODO(), cl, "group", []runtimeclient.Object{newConfigMap("applied", "group")}, client.PruneDryRun(true))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, pruned, 1)
+		assert.Equal(t, "stale", pruned[0].GetName())
+		assertExists(t, cl, stale)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Run("empty group", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t)
+
+			// when
+			_, err := client.Prune(context.TODO(), cl, "", []runtimeclient.Object{newConfigMap("applied", "")})
+
+			// then
+			require.EqualError(t, err, "the apply group must not be empty")
+		})
+
+		t.Run("list error", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t)
+			cl.MockList = func(ctx context.Context, list runtimeclient.ObjectList, opts ...runtimeclient.ListOption) error {
+				return fmt.Errorf("mock error")
+			}
+
+			// when
+			_, err := client.Prune(context.TODO(), cl, "group", []runtimeclient.Object{newConfigMap("applied", "group")})
+
+			// then
+			require.EqualError(t, err, "unable to list the objects of kind '/v1, Kind=ConfigMap' in the apply group 'group': mock error")
+		})
+
+		t.Run("delete error", func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t, newConfigMap("stale", "group"))
+			cl.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+				return fmt.Errorf("mock error")
+			}
+
+			// when
+			_, err := client.Prune(context.TODO(), cl, "group", []runtimeclient.Object{newConfigMap("applied", "group")})
+
+			// then
+			require.EqualError(t, err, "unable to prune the object of kind '/v1, Kind=ConfigMap' called 'stale' in namespace 'default': mock error")
+		})
+	})
+}
+
+func assertExists(t *testing.T, cl runtimeclient.Client, obj runtimeclient.Object) {
+	inCluster := obj.DeepCopyObject().(runtimeclient.Object)
+	require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), inCluster))
+}
+
+func assertNotFound(t *testing.T, cl runtimeclient.Client, obj runtimeclient.Object) {
+	inCluster := obj.DeepCopyObject().(runtimeclient.Object)
+	err := cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), inCluster)
+	assert.True(t, errors.IsNotFound(err), "expected NotFound error, got: %v", err)
+}
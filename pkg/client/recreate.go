@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IsImmutableFieldError returns `true` if the given error is an Invalid API error caused by a change of an immutable field,
+// such as the selector of a Deployment or the pod template of a Job.
+func IsImmutableFieldError(err error) bool {
+	if !apierrors.IsInvalid(err) {
+		return false
+	}
+	var statusErr apierrors.APIStatus
+	if errors.As(err, &statusErr) {
+		if details := statusErr.Status().Details; details != nil {
+			for _, cause := range details.Causes {
+				if strings.Contains(cause.Message, "immutable") {
+					return true
+				}
+			}
+		}
+	}
+	return strings.Contains(err.Error(), "immutable")
+}
+
+var (
+	// deletionPollInterval and deletionTimeout configure the wait for the deletion of an object that is recreated
+	deletionPollInterval = 100 * time.Millisecond
+	deletionTimeout      = 30 * time.Second
+)
+
+// deleteAndWait deletes the given object and waits until it disappears from the cluster
+func deleteAndWait(ctx context.Context, cl client.Client, obj client.Object, options ...client.DeleteOption) error {
+	if err := cl.Delete(ctx, obj, options...); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unable to delete the resource '%s' in namespace '%s': %w", obj.GetName(), obj.GetNamespace(), err)
+	}
+	current := obj.DeepCopyObject().(client.Object)
+	err := wait.PollUntilContextTimeout(ctx, deletionPollInterval, deletionTimeout, true, func(ctx context.Context) (bool, error) {
+		if err := cl.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("the resource '%s' in namespace '%s' was not deleted: %w", obj.GetName(), obj.GetNamespace(), err)
+	}
+	return nil
+}
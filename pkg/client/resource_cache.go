@@ -1,34 +1,89 @@
 package client
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 )
 
+const (
+	// DefaultResourceCacheTTL is the default time after which the cached discovery data is refreshed
+	DefaultResourceCacheTTL = 10 * time.Minute
+	// DefaultRefreshOnMissInterval is the default minimal time between two refreshes of the discovery data caused by a lookup miss
+	DefaultRefreshOnMissInterval = 30 * time.Second
+)
+
 type ResourceCache struct {
-	mutex           sync.Mutex                // guard the initialization ofthe resourceLists
-	resourceLists   []*metav1.APIResourceList // All available API in the cluster
-	discoveryClient discovery.ServerResourcesInterface
+	mutex               sync.Mutex                // guard the resourceLists and the other discovery data
+	resourceLists       []*metav1.APIResourceList // All available API in the cluster (all the served versions, the preferred ones first)
+	groups              []*metav1.APIGroup        // the API groups with their preferred version
+	failedGroupVersions map[schema.GroupVersion]error
+	lastRefresh         time.Time
+	discoveryClient     discovery.ServerResourcesInterface
+	// mapper, shortNames and categories are computed from the resourceLists on every refresh
+	mapper     meta.RESTMapper
+	shortNames map[string][]schema.GroupResource
+	categories map[string][]schema.GroupResource
+
+	ttl                   time.Duration
+	refreshOnMissInterval time.Duration
+	now                   func() time.Time
+}
+
+// ResourceCacheOption an option to configure the ResourceCache
+type ResourceCacheOption func(*ResourceCache)
+
+// ResourceCacheTTL sets the time after which the cached discovery data is refreshed (default: `DefaultResourceCacheTTL`).
+// A zero or negative value means that the data never expires.
+func ResourceCacheTTL(ttl time.Duration) ResourceCacheOption {
+	return func(rc *ResourceCache) {
+		rc.ttl = ttl
+	}
+}
+
+// RefreshOnMissInterval sets the minimal time between two refreshes of the discovery data caused by a lookup of a resource that
+// was not found in the cache, such as a CRD installed after the cache was loaded (default: `DefaultRefreshOnMissInterval`).
+// A negative value disables the refresh on miss.
+func RefreshOnMissInterval(interval time.Duration) ResourceCacheOption {
+	return func(rc *ResourceCache) {
+		rc.refreshOnMissInterval = interval
+	}
 }
 
 // NewResourceCache creates a new ResourceCache  with the provided discovery client.
 // The discovery client is used to fetch available API resources.
-func NewResourceCache(discoveryClient discovery.ServerResourcesInterface) *ResourceCache {
-	return &ResourceCache{
-		discoveryClient: discoveryClient,
+func NewResourceCache(discoveryClient discovery.ServerResourcesInterface, options ...ResourceCacheOption) *ResourceCache {
+	rc := &ResourceCache{
+		discoveryClient:       discoveryClient,
+		ttl:                   DefaultResourceCacheTTL,
+		refreshOnMissInterval: DefaultRefreshOnMissInterval,
+		now:                   time.Now,
+	}
+	for _, apply := range options {
+		apply(rc)
 	}
+	return rc
+}
+
+// Invalidate drops the cached discovery data, so that it's loaded again by the next lookup
+func (rc *ResourceCache) Invalidate() {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	rc.resourceLists = nil
+	rc.groups = nil
+	rc.failedGroupVersions = nil
 }
 
 // GVRForKind returns a group-resource-version for the supplied kind and api version.
 func (rc *ResourceCache) GVRForKind(kind, apiVersion string) (gvr schema.GroupVersionResource, found bool, namespaced bool, err error) {
-	if err = rc.ensureResourceList(); err != nil {
-		return
-	}
-
 	// Parse the group and version from the APIVersion (e.g., "apps/v1" -> group: "apps", version: "v1")
 	var gv schema.GroupVersion
 	gv, err = schema.ParseGroupVersion(apiVersion)
@@ -37,74 +92,151 @@ func (rc *ResourceCache) GVRForKind(kind, apiVersion string) (gvr schema.GroupVe
 		return
 	}
 
-	// Look for a matching resource
-	for _, resourceList := range rc.resourceLists {
-		if resourceList.GroupVersion == apiVersion {
-			for _, apiResource := range resourceList.APIResources {
-				if apiResource.Kind == kind {
-					// Construct the GVR
-					found = true
-					gvr = schema.GroupVersionResource{
-						Group:    gv.Group,
-						Version:  gv.Version,
-						Resource: apiResource.Name,
+	err = rc.lookup(func(resourceLists []*metav1.APIResourceList) (bool, error) {
+		// Look for a matching resource
+		for _, resourceList := range resourceLists {
+			if resourceList.GroupVersion == apiVersion {
+				for _, apiResource := range resourceList.APIResources {
+					if apiResource.Kind == kind {
+						// Construct the GVR
+						found = true
+						gvr = schema.GroupVersionResource{
+							Group:    gv.Group,
+							Version:  gv.Version,
+							Resource: apiResource.Name,
+						}
+						namespaced = apiResource.Namespaced
+						return true, nil
 					}
-					namespaced = apiResource.Namespaced
-
-					return
 				}
 			}
 		}
-	}
-
+		if discoveryErr, failed := rc.failedGroupVersions[gv]; failed {
+			return false, fmt.Errorf("unable to discover the resources of the group version %s: %w", gv, discoveryErr)
+		}
+		return false, nil
+	})
 	return
 }
 
-// GVKForGR given the group-resource, returns the first matching GVK for it.
+// GVKForGR given the group-resource, returns the first matching GVK for it (ie, the one of the preferred version of the group if it serves the resource).
 func (rc *ResourceCache) GVKForGR(gr schema.GroupResource) (gvk schema.GroupVersionKind, found bool, err error) {
-	if err = rc.ensureResourceList(); err != nil {
-		return
-	}
-
-	for _, resourceList := range rc.resourceLists {
-		var gv schema.GroupVersion
-		gv, err = schema.ParseGroupVersion(resourceList.GroupVersion)
-		if err != nil {
-			err = fmt.Errorf("failed to parse GroupVersion %s: %w", resourceList.GroupVersion, err)
-			return
-		}
-		if gv.Group != gr.Group {
-			continue
+	err = rc.lookup(func(resourceLists []*metav1.APIResourceList) (bool, error) {
+		for _, resourceList := range resourceLists {
+			gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+			if err != nil {
+				return false, fmt.Errorf("failed to parse GroupVersion %s: %w", resourceList.GroupVersion, err)
+			}
+			if gv.Group != gr.Group {
+				continue
+			}
+			for _, res := range resourceList.APIResources {
+				if res.Name == gr.Resource {
+					gvk = schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: res.Kind}
+					found = true
+					return true, nil
+				}
+			}
 		}
-		for _, res := range resourceList.APIResources {
-			if res.Name == gr.Resource {
-				gvk = schema.GroupVersionKind{Group: gv.Group, Version: gv.Version, Kind: res.Kind}
-				found = true
-				return
+		for gv, discoveryErr := range rc.failedGroupVersions {
+			if gv.Group == gr.Group {
+				return false, fmt.Errorf("unable to discover the resources of the group version %s: %w", gv, discoveryErr)
 			}
 		}
-	}
-
+		return false, nil
+	})
 	return
 }
 
-func (rc *ResourceCache) ensureResourceList() error {
+// lookup calls the given function with the cached resource lists while holding the lock. The discovery data is loaded first
+// if it's missing or expired. If the function doesn't find what it's looking for, then the discovery data is refreshed
+// (unless it was refreshed recently) and the function is called once again.
+func (rc *ResourceCache) lookup(find func([]*metav1.APIResourceList) (bool, error)) error {
 	rc.mutex.Lock()
 	defer rc.mutex.Unlock()
 
-	if rc.resourceLists == nil {
-		// Get all API resources from the cluster using the discovery client. We need it for constructing GVRs for unstructured objects.
-		// Do it here once, so we do not have to list it multiple times before listing/getting every unstructured resource.
-		//
-		// The ServerPreferredResources() method is meant to return partial results on failure.
-		// We ignore them here for the sake of simplicity. Let's just retry to get the full results the next time.
-		resourceList, err := rc.discoveryClient.ServerPreferredResources()
-		if err != nil {
-			return err
-		}
+	if err := rc.ensureResourceList(); err != nil {
+		return err
+	}
+	found, err := find(rc.resourceLists)
+	if found || !rc.canRefreshOnMiss() {
+		return err
+	}
+	if err := rc.refresh(); err != nil {
+		return err
+	}
+	_, err = find(rc.resourceLists)
+	return err
+}
 
-		rc.resourceLists = resourceList
+func (rc *ResourceCache) canRefreshOnMiss() bool {
+	return rc.refreshOnMissInterval >= 0 && rc.now().Sub(rc.lastRefresh) >= rc.refreshOnMissInterval
+}
+
+// ensureResourceList loads the discovery data if it's missing or expired. It must be called while holding the lock.
+func (rc *ResourceCache) ensureResourceList() error {
+	if rc.resourceLists == nil || (rc.ttl > 0 && rc.now().Sub(rc.lastRefresh) >= rc.ttl) {
+		// Get all API resources from the cluster using the discovery client. We need it for constructing GVRs for unstructured objects.
+		// Do it here once (or once per TTL), so we do not have to list it multiple times before listing/getting every unstructured resource.
+		return rc.refresh()
 	}
+	return nil
+}
 
+// refresh loads the discovery data from the server. It must be called while holding the lock.
+//
+// All the served versions are loaded (not only the preferred ones), so that the objects of any version can be mapped.
+// The preferred versions are only used to order the priority of the versions (see sortByPreferredVersion).
+//
+// The ServerGroupsAndResources() method returns partial results when the discovery of some group versions fails
+// (eg. because of an unavailable aggregated API server). The partial results are accepted, and the previously discovered
+// resources of the failed group versions are kept. If the discovery fails completely, then the previously discovered
+// resources are kept (if there are any) and the refresh is retried later.
+func (rc *ResourceCache) refresh() error {
+	groups, resourceLists, err := rc.discoveryClient.ServerGroupsAndResources()
+	failedGroupVersions := map[schema.GroupVersion]error{}
+	if err != nil {
+		groupDiscoveryErr := &discovery.ErrGroupDiscoveryFailed{}
+		if !errors.As(err, &groupDiscoveryErr) {
+			if rc.resourceLists == nil {
+				return err
+			}
+			log.Error(err, "unable to refresh the discovery data, keeping the previous one")
+			rc.lastRefresh = rc.now()
+			return nil
+		}
+		failedGroupVersions = groupDiscoveryErr.Groups
+		for _, resourceList := range rc.resourceLists {
+			gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+			if err != nil {
+				continue
+			}
+			if _, failed := failedGroupVersions[gv]; failed {
+				resourceLists = append(resourceLists, resourceList)
+			}
+		}
+	}
+	if resourceLists == nil {
+		// make sure that the empty discovery data is not considered as missing
+		resourceLists = []*metav1.APIResourceList{}
+	}
+	sortByPreferredVersion(groups, resourceLists)
+	rc.resourceLists = resourceLists
+	rc.groups = groups
+	rc.failedGroupVersions = failedGroupVersions
+	rc.lastRefresh = rc.now()
+	rc.buildMapper()
 	return nil
 }
+
+// sortByPreferredVersion moves the resource lists of the preferred versions of the groups before the other ones,
+// so that the lookups which iterate over the resource lists find the preferred version first.
+func sortByPreferredVersion(groups []*metav1.APIGroup, resourceLists []*metav1.APIResourceList) {
+	preferred := map[string]bool{}
+	for _, group := range groups {
+		preferred[group.PreferredVersion.GroupVersion] = true
+	}
+	sort.SliceStable(resourceLists, func(i, j int) bool {
+		return preferred[resourceLists[i].GroupVersion] && !preferred[resourceLists[j].GroupVersion]
+	})
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/restmapper"
+)
+
+var _ meta.ResettableRESTMapper = &ResourceCache{}
+var _ restmapper.CategoryExpander = &ResourceCache{}
+
+// buildMapper computes the REST mapper, the short names and the categories from the groups and the resource lists.
+// The mapper knows all the served versions and prefers the preferred version of each group when the version is not specified.
+// It must be called while holding the lock.
+func (rc *ResourceCache) buildMapper() {
+	groupResources := make([]*restmapper.APIGroupResources, 0, len(rc.groups))
+	groupResourcesByName := map[string]*restmapper.APIGroupResources{}
+	for _, group := range rc.groups {
+		resources := &restmapper.APIGroupResources{
+			Group:              *group,
+			VersionedResources: map[string][]metav1.APIResource{},
+		}
+		groupResources = append(groupResources, resources)
+		groupResourcesByName[group.Name] = resources
+	}
+	shortNames := map[string][]schema.GroupResource{}
+	categories := map[string][]schema.GroupResource{}
+	// the same resource is usually served in several versions
+	seen := map[schema.GroupResource]bool{}
+	for _, resourceList := range rc.resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		resources, found := groupResourcesByName[gv.Group]
+		if !found {
+			continue
+		}
+		resources.VersionedResources[gv.Version] = append(resources.VersionedResources[gv.Version], resourceList.APIResources...)
+
+		for _, apiResource := range resourceList.APIResources {
+			gr := gv.WithResource(apiResource.Name).GroupResource()
+			if strings.Contains(apiResource.Name, "/") || seen[gr] {
+				// skip the subresources and the resources of the other versions
+				continue
+			}
+			seen[gr] = true
+			for _, shortName := range apiResource.ShortNames {
+				shortNames[shortName] = append(shortNames[shortName], gr)
+			}
+			for _, category := range apiResource.Categories {
+				categories[category] = append(categories[category], gr)
+			}
+		}
+	}
+	rc.mapper = restmapper.NewDiscoveryRESTMapper(groupResources)
+	rc.shortNames = shortNames
+	rc.categories = categories
+}
+
+// withMapper calls the given function with the REST mapper. If the function returns a "no match" error, then the discovery data
+// is refreshed (unless it was refreshed recently) and the function is called once again.
+func (rc *ResourceCache) withMapper(mapping func(meta.RESTMapper) error) error {
+	return rc.lookup(func(_ []*metav1.APIResourceList) (bool, error) {
+		err := mapping(rc.mapper)
+		return !meta.IsNoMatchError(err), err
+	})
+}
+
+// expandShortName replaces the short name of the resource (such as `deploy`) with the full resource name. It must be called while
+// holding the lock.
+func (rc *ResourceCache) expandShortName(resource schema.GroupVersionResource) schema.GroupVersionResource {
+	for _, gr := range rc.shortNames[resource.Resource] {
+		if resource.Group == "" || resource.Group == gr.Group {
+			resource.Group = gr.Group
+			resource.Resource = gr.Resource
+			return resource
+		}
+	}
+	return resource
+}
+
+// KindFor takes a partial resource (which may also be a short name) and returns the single match. Returns an error if there are multiple matches.
+func (rc *ResourceCache) KindFor(resource schema.GroupVersionResource) (gvk schema.GroupVersionKind, err error) {
+	err = rc.withMapper(func(mapper meta.RESTMapper) error {
+		gvk, err = mapper.KindFor(rc.expandShortName(resource))
+		return err
+	})
+	return
+}
+
+// KindsFor takes a partial resource (which may also be a short name) and returns the list of potential kinds in priority order.
+func (rc *ResourceCache) KindsFor(resource schema.GroupVersionResource) (gvks []schema.GroupVersionKind, err error) {
+	err = rc.withMapper(func(mapper meta.RESTMapper) error {
+		gvks, err = mapper.KindsFor(rc.expandShortName(resource))
+		return err
+	})
+	return
+}
+
+// ResourceFor takes a partial resource (which may also be a short name) and returns the single match. Returns an error if there are multiple matches.
+func (rc *ResourceCache) ResourceFor(input schema.GroupVersionResource) (gvr schema.GroupVersionResource, err error) {
+	err = rc.withMapper(func(mapper meta.RESTMapper) error {
+		gvr, err = mapper.ResourceFor(rc.expandShortName(input))
+		return err
+	})
+	return
+}
+
+// ResourcesFor takes a partial resource (which may also be a short name) and returns the list of potential resources in priority order.
+func (rc *ResourceCache) ResourcesFor(input schema.GroupVersionResource) (gvrs []schema.GroupVersionResource, err error) {
+	err = rc.withMapper(func(mapper meta.RESTMapper) error {
+		gvrs, err = mapper.ResourcesFor(rc.expandShortName(input))
+		return err
+	})
+	return
+}
+
+// RESTMapping identifies a preferred resource mapping for the provided group kind.
+func (rc *ResourceCache) RESTMapping(gk schema.GroupKind, versions ...string) (mapping *meta.RESTMapping, err error) {
+	err = rc.withMapper(func(mapper meta.RESTMapper) error {
+		mapping, err = mapper.RESTMapping(gk, versions...)
+		return err
+	})
+	return
+}
+
+// RESTMappings returns all resource mappings for the provided group kind if no version search is provided.
+// Otherwise identifies a preferred resource mapping for the provided version(s).
+func (rc *ResourceCache) RESTMappings(gk schema.GroupKind, versions ...string) (mappings []*meta.RESTMapping, err error) {
+	err = rc.withMapper(func(mapper meta.RESTMapper) error {
+		mappings, err = mapper.RESTMappings(gk, versions...)
+		return err
+	})
+	return
+}
+
+// ResourceSingularizer returns the singular form of the given resource
+func (rc *ResourceCache) ResourceSingularizer(resource string) (singular string, err error) {
+	err = rc.withMapper(func(mapper meta.RESTMapper) error {
+		singular, err = mapper.ResourceSingularizer(resource)
+		return err
+	})
+	return
+}
+
+// Reset drops the cached discovery data (see Invalidate)
+func (rc *ResourceCache) Reset() {
+	rc.Invalidate()
+}
+
+// Expand returns the group resources of the given category (such as `all`). It returns `false` if the category is unknown.
+func (rc *ResourceCache) Expand(category string) (grs []schema.GroupResource, found bool) {
+	// the error can only be a discovery error which is reported as an unknown category
+	_ = rc.lookup(func(_ []*metav1.APIResourceList) (bool, error) {
+		grs, found = rc.categories[category]
+		return found, nil
+	})
+	return
+}
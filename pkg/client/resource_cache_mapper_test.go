@@ -0,0 +1,236 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResourceCacheRESTMapper(t *testing.T) {
+	resources := []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", SingularName: "pod", Kind: "Pod", Namespaced: true, ShortNames: []string{"po"}, Categories: []string{"all"}},
+				{Name: "pods/status", Kind: "Pod", Namespaced: true},
+				{Name: "namespaces", SingularName: "namespace", Kind: "Namespace", Namespaced: false, ShortNames: []string{"ns"}},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true, ShortNames: []string{"deploy"}, Categories: []string{"all"}},
+			},
+		},
+	}
+	spaces := &metav1.APIResourceList{
+		GroupVersion: "toolchain.dev.openshift.com/v1alpha1",
+		APIResources: []metav1.APIResource{
+			{Name: "spaces", Kind: "Space", Namespaced: true},
+		},
+	}
+
+	t.Run("KindFor", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		gvk, err := rc.KindFor(schema.GroupVersionResource{Resource: "deployments"})
+
+		require.NoError(t, err)
+		assert.Equal(t, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, gvk)
+	})
+
+	t.Run("KindFor singular name", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		gvk, err := rc.KindFor(schema.GroupVersionResource{Resource: "deployment"})
+
+		require.NoError(t, err)
+		assert.Equal(t, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, gvk)
+	})
+
+	t.Run("KindFor short name", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		gvk, err := rc.KindFor(schema.GroupVersionResource{Resource: "po"})
+
+		require.NoError(t, err)
+		assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, gvk)
+	})
+
+	t.Run("ResourcesFor", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		gvrs, err := rc.ResourcesFor(schema.GroupVersionResource{Resource: "ns"})
+
+		require.NoError(t, err)
+		assert.Equal(t, []schema.GroupVersionResource{{Version: "v1", Resource: "namespaces"}}, gvrs)
+	})
+
+	t.Run("RESTMapping", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		mapping, err := rc.RESTMapping(schema.GroupKind{Group: "apps", Kind: "Deployment"})
+
+		require.NoError(t, err)
+		assert.Equal(t, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, mapping.Resource)
+		assert.Equal(t, meta.RESTScopeNameNamespace, mapping.Scope.Name())
+
+		mapping, err = rc.RESTMapping(schema.GroupKind{Kind: "Namespace"}, "v1")
+
+		require.NoError(t, err)
+		assert.Equal(t, meta.RESTScopeNameRoot, mapping.Scope.Name())
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		_, err := rc.RESTMapping(schema.GroupKind{Group: "apps", Kind: "StatefulSet"})
+
+		require.Error(t, err)
+		assert.True(t, meta.IsNoMatchError(err))
+	})
+
+	t.Run("refreshes on no match", func(t *testing.T) {
+		dc := &fakeDiscoveryClient{resources: resources}
+		rc := NewResourceCache(dc, RefreshOnMissInterval(time.Minute))
+		now := time.Now()
+		rc.now = func() time.Time {
+			return now
+		}
+		_, err := rc.KindFor(schema.GroupVersionResource{Resource: "pods"})
+		require.NoError(t, err)
+		dc.resources = append(resources, spaces)
+
+		now = now.Add(time.Minute)
+		mapping, err := rc.RESTMapping(schema.GroupKind{Group: "toolchain.dev.openshift.com", Kind: "Space"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "spaces", mapping.Resource.Resource)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+
+	t.Run("non-preferred versions", func(t *testing.T) {
+		// the non-preferred version is listed first
+		autoscaling := []*metav1.APIResourceList{
+			{
+				GroupVersion: "autoscaling/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true, ShortNames: []string{"hpa"}, Categories: []string{"all"}},
+				},
+			},
+			{
+				GroupVersion: "autoscaling/v2",
+				APIResources: []metav1.APIResource{
+					{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true, ShortNames: []string{"hpa"}, Categories: []string{"all"}},
+				},
+			},
+		}
+		newDiscoveryClient := func() *fakeDiscoveryClient {
+			return &fakeDiscoveryClient{
+				resources: autoscaling,
+				groups: []*metav1.APIGroup{
+					{
+						Name: "autoscaling",
+						Versions: []metav1.GroupVersionForDiscovery{
+							{GroupVersion: "autoscaling/v1", Version: "v1"},
+							{GroupVersion: "autoscaling/v2", Version: "v2"},
+						},
+						PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "autoscaling/v2", Version: "v2"},
+					},
+				},
+			}
+		}
+
+		t.Run("RESTMapping of a non-preferred version without refresh", func(t *testing.T) {
+			dc := newDiscoveryClient()
+			rc := NewResourceCache(dc, RefreshOnMissInterval(0))
+
+			mapping, err := rc.RESTMapping(schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}, "v1")
+
+			require.NoError(t, err)
+			assert.Equal(t, schema.GroupVersionResource{Group: "autoscaling", Version: "v1", Resource: "horizontalpodautoscalers"}, mapping.Resource)
+			assert.Equal(t, int32(1), dc.calls.Load())
+		})
+
+		t.Run("KindFor a non-preferred version without refresh", func(t *testing.T) {
+			dc := newDiscoveryClient()
+			rc := NewResourceCache(dc, RefreshOnMissInterval(0))
+
+			gvk, err := rc.KindFor(schema.GroupVersionResource{Group: "autoscaling", Version: "v1", Resource: "hpa"})
+
+			require.NoError(t, err)
+			assert.Equal(t, schema.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "HorizontalPodAutoscaler"}, gvk)
+			assert.Equal(t, int32(1), dc.calls.Load())
+		})
+
+		t.Run("preferred version when the version is not specified", func(t *testing.T) {
+			rc := NewResourceCache(newDiscoveryClient())
+
+			gvk, err := rc.KindFor(schema.GroupVersionResource{Resource: "horizontalpodautoscalers"})
+			require.NoError(t, err)
+			assert.Equal(t, "v2", gvk.Version)
+
+			mapping, err := rc.RESTMapping(schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"})
+			require.NoError(t, err)
+			assert.Equal(t, "v2", mapping.Resource.Version)
+
+			gvk, found, err := rc.GVKForGR(schema.GroupResource{Group: "autoscaling", Resource: "horizontalpodautoscalers"})
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "v2", gvk.Version)
+
+			// the resource is listed only once
+			grs, _ := rc.Expand("all")
+			assert.Equal(t, []schema.GroupResource{{Group: "autoscaling", Resource: "horizontalpodautoscalers"}}, grs)
+		})
+	})
+
+	t.Run("categories", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		grs, found := rc.Expand("all")
+
+		assert.True(t, found)
+		assert.Equal(t, []schema.GroupResource{{Resource: "pods"}, {Group: "apps", Resource: "deployments"}}, grs)
+
+		_, found = rc.Expand("unknown")
+
+		assert.False(t, found)
+	})
+
+	t.Run("ResourceSingularizer", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{resources: resources})
+
+		singular, err := rc.ResourceSingularizer("deployments")
+
+		require.NoError(t, err)
+		assert.Equal(t, "deployment", singular)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		dc := &fakeDiscoveryClient{resources: resources}
+		rc := NewResourceCache(dc)
+		_, err := rc.KindFor(schema.GroupVersionResource{Resource: "pods"})
+		require.NoError(t, err)
+
+		rc.Reset()
+		_, err = rc.KindFor(schema.GroupVersionResource{Resource: "pods"})
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+
+	t.Run("discovery error", func(t *testing.T) {
+		rc := NewResourceCache(&fakeDiscoveryClient{err: errors.New("discovery failed")})
+
+		_, err := rc.RESTMapping(schema.GroupKind{Kind: "Pod"})
+
+		require.EqualError(t, err, "discovery failed")
+	})
+}
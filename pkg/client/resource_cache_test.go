@@ -5,17 +5,21 @@ import (
 	"fmt"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 )
 
 type fakeDiscoveryClient struct {
 	resources []*metav1.APIResourceList
-	err       error
-	calls     atomic.Int32
+	// groups are the API groups of the resources. If not set, then the first version of each group is the preferred one.
+	groups []*metav1.APIGroup
+	err    error
+	calls  atomic.Int32
 }
 
 func (f *fakeDiscoveryClient) ServerResourcesForGroupVersion(string) (*metav1.APIResourceList, error) {
@@ -23,12 +27,31 @@ func (f *fakeDiscoveryClient) ServerResourcesForGroupVersion(string) (*metav1.AP
 }
 
 func (f *fakeDiscoveryClient) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
-	return nil, nil, nil
+	f.calls.Add(1)
+	if f.groups != nil {
+		return f.groups, f.resources, f.err
+	}
+	var groups []*metav1.APIGroup
+	groupsByName := map[string]*metav1.APIGroup{}
+	for _, resourceList := range f.resources {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		version := metav1.GroupVersionForDiscovery{GroupVersion: resourceList.GroupVersion, Version: gv.Version}
+		group, found := groupsByName[gv.Group]
+		if !found {
+			group = &metav1.APIGroup{Name: gv.Group, PreferredVersion: version}
+			groupsByName[gv.Group] = group
+			groups = append(groups, group)
+		}
+		group.Versions = append(group.Versions, version)
+	}
+	return groups, f.resources, f.err
 }
 
 func (f *fakeDiscoveryClient) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
-	f.calls.Add(1)
-	return f.resources, f.err
+	return nil, nil
 }
 
 func (f *fakeDiscoveryClient) ServerPreferredNamespacedResources() ([]*metav1.APIResourceList, error) {
@@ -239,3 +262,172 @@ func TestThreadSafety(t *testing.T) {
 	// then
 	assert.Equal(t, int32(1), cl.calls.Load())
 }
+
+func TestResourceCacheRefresh(t *testing.T) {
+	podsList := &metav1.APIResourceList{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true},
+		},
+	}
+	crdList := &metav1.APIResourceList{
+		GroupVersion: "toolchain.dev.openshift.com/v1alpha1",
+		APIResources: []metav1.APIResource{
+			{Name: "spaces", Kind: "Space", Namespaced: true},
+		},
+	}
+	newCache := func(dc *fakeDiscoveryClient, options ...ResourceCacheOption) (*ResourceCache, *time.Time) {
+		rc := NewResourceCache(dc, options...)
+		now := time.Now()
+		rc.now = func() time.Time {
+			return now
+		}
+		return rc, &now
+	}
+
+	t.Run("refreshes after TTL", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{podsList}}
+		rc, now := newCache(dc, ResourceCacheTTL(time.Minute), RefreshOnMissInterval(-1))
+		_, found, _, err := rc.GVRForKind("Space", "toolchain.dev.openshift.com/v1alpha1")
+		require.NoError(t, err)
+		require.False(t, found)
+		dc.resources = []*metav1.APIResourceList{podsList, crdList}
+
+		// when
+		*now = now.Add(30 * time.Second)
+		_, found, _, err = rc.GVRForKind("Space", "toolchain.dev.openshift.com/v1alpha1")
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, found, "the data should not be refreshed before the TTL expires")
+
+		// when
+		*now = now.Add(time.Minute)
+		_, found, _, err = rc.GVRForKind("Space", "toolchain.dev.openshift.com/v1alpha1")
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+
+	t.Run("refreshes on miss with rate limiting", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{podsList}}
+		rc, now := newCache(dc, RefreshOnMissInterval(time.Minute))
+		_, _, err := rc.GVKForGR(schema.GroupResource{Group: "", Resource: "pods"})
+		require.NoError(t, err)
+		dc.resources = []*metav1.APIResourceList{podsList, crdList}
+
+		// when
+		_, found, err := rc.GVKForGR(schema.GroupResource{Group: "toolchain.dev.openshift.com", Resource: "spaces"})
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, found, "the data should not be refreshed again so soon")
+		assert.Equal(t, int32(1), dc.calls.Load())
+
+		// when
+		*now = now.Add(time.Minute)
+		gvk, found, err := rc.GVKForGR(schema.GroupResource{Group: "toolchain.dev.openshift.com", Resource: "spaces"})
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, schema.GroupVersionKind{Group: "toolchain.dev.openshift.com", Version: "v1alpha1", Kind: "Space"}, gvk)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{podsList}}
+		rc, _ := newCache(dc, RefreshOnMissInterval(-1))
+		_, found, _, err := rc.GVRForKind("Space", "toolchain.dev.openshift.com/v1alpha1")
+		require.NoError(t, err)
+		require.False(t, found)
+		dc.resources = []*metav1.APIResourceList{podsList, crdList}
+
+		// when
+		rc.Invalidate()
+		_, found, _, err = rc.GVRForKind("Space", "toolchain.dev.openshift.com/v1alpha1")
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+
+	t.Run("partial discovery", func(t *testing.T) {
+		crdGV := schema.GroupVersion{Group: "toolchain.dev.openshift.com", Version: "v1alpha1"}
+		partialErr := &discovery.ErrGroupDiscoveryFailed{Groups: map[schema.GroupVersion]error{crdGV: errors.New("service unavailable")}}
+
+		t.Run("accepts partial results", func(t *testing.T) {
+			// given
+			dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{podsList}, err: partialErr}
+			rc, _ := newCache(dc)
+
+			// when
+			gvr, found, _, err := rc.GVRForKind("Pod", "v1")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "pods"}, gvr)
+		})
+
+		t.Run("reports the error of the failed group version", func(t *testing.T) {
+			// given
+			dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{podsList}, err: partialErr}
+			rc, _ := newCache(dc)
+
+			// when
+			_, found, _, err := rc.GVRForKind("Space", "toolchain.dev.openshift.com/v1alpha1")
+			_, grFound, grErr := rc.GVKForGR(schema.GroupResource{Group: "toolchain.dev.openshift.com", Resource: "spaces"})
+
+			// then
+			require.EqualError(t, err, "unable to discover the resources of the group version toolchain.dev.openshift.com/v1alpha1: service unavailable")
+			assert.False(t, found)
+			require.EqualError(t, grErr, "unable to discover the resources of the group version toolchain.dev.openshift.com/v1alpha1: service unavailable")
+			assert.False(t, grFound)
+		})
+
+		t.Run("keeps previous data of the failed group version on refresh", func(t *testing.T) {
+			// given
+			dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{podsList, crdList}}
+			rc, now := newCache(dc, ResourceCacheTTL(time.Minute))
+			_, _, _, err := rc.GVRForKind("Pod", "v1")
+			require.NoError(t, err)
+			dc.resources = []*metav1.APIResourceList{podsList}
+			dc.err = partialErr
+
+			// when
+			*now = now.Add(time.Minute)
+			_, found, _, err := rc.GVRForKind("Space", "toolchain.dev.openshift.com/v1alpha1")
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, int32(2), dc.calls.Load())
+		})
+	})
+
+	t.Run("keeps previous data when refresh fails", func(t *testing.T) {
+		// given
+		dc := &fakeDiscoveryClient{resources: []*metav1.APIResourceList{podsList}}
+		rc, now := newCache(dc, ResourceCacheTTL(time.Minute))
+		_, _, _, err := rc.GVRForKind("Pod", "v1")
+		require.NoError(t, err)
+		dc.resources = nil
+		dc.err = errors.New("discovery failed")
+
+		// when
+		*now = now.Add(time.Minute)
+		_, found, _, err := rc.GVRForKind("Pod", "v1")
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, int32(2), dc.calls.Load())
+	})
+}
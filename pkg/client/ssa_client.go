@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -82,6 +83,11 @@ type ssaApplyObjectConfiguration struct {
 	newLabels  map[string]string
 	skipIf     func(client.Object) bool
 	migrateSSA migrateSSA
+	pruneGroup string
+	pruneOpts  []PruneOption
+	dryRun     *DryRunReport
+	// recreatePolicy is the propagation policy used to delete the objects that need to be recreated (nil if recreate is disabled)
+	recreatePolicy *metav1.DeletionPropagation
 }
 
 func newSSAApplyObjectConfiguration(options ...SSAApplyObjectOption) ssaApplyObjectConfiguration {
@@ -132,6 +138,37 @@ func MigrateSSA(value bool) SSAApplyObjectOption {
 	}
 }
 
+// WithPrune labels the applied objects with the given apply group. When used with Apply or ApplyAll,
+// all the objects from the same apply group that were applied before but are no longer part of
+// the applied objects are deleted after all the objects are successfully applied.
+func WithPrune(group string, options ...PruneOption) SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.pruneGroup = group
+		config.pruneOpts = options
+	}
+}
+
+// DryRun sends the SSA patches with `dryRun=All`, so that nothing is changed in the cluster, and adds a diff
+// between the live object and the would-be result of the apply into the provided report for every applied object.
+// When used together with the MigrateSSA option, the migration of the managed fields is also only dry-run.
+// When used together with the WithPrune option, the objects that would be pruned are added to the report, too.
+// The supplied objects are not updated with the would-be result of the apply.
+func DryRun(report *DryRunReport) SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.dryRun = report
+	}
+}
+
+// WithRecreateOnImmutableChange deletes and recreates the object when the patch is rejected because of a change
+// of an immutable field (see IsImmutableFieldError). The existing object is deleted using the given propagation policy
+// and the new one is created only after the existing one disappeared from the cluster.
+// When used together with the DryRun option, nothing is deleted and the object is reported as recreated.
+func WithRecreateOnImmutableChange(propagationPolicy metav1.DeletionPropagation) SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.recreatePolicy = &propagationPolicy
+	}
+}
+
 // Configure sets the owner reference and merges the labels. Other options modify the logic
 // of apply function and therefore need to be checked manually.
 func (c *ssaApplyObjectConfiguration) Configure(obj client.Object, s *runtime.Scheme) error {
@@ -141,59 +178,192 @@ func (c *ssaApplyObjectConfiguration) Configure(obj client.Object, s *runtime.Sc
 		}
 	}
 	MergeLabels(obj, c.newLabels)
+	if c.pruneGroup != "" {
+		MergeLabels(obj, map[string]string{ApplyGroupLabelKey: c.pruneGroup})
+	}
 
 	return nil
 }
 
 // ApplyObject creates the object if is missing or update it if it already exists using an SSA patch.
 func (c *SSAApplyClient) ApplyObject(ctx context.Context, obj client.Object, options ...SSAApplyObjectOption) error {
+	_, err := c.applyObject(ctx, obj, false, options...)
+	return err
+}
+
+// ApplyObjectWithResult works the same way as ApplyObject, but it also returns the details about the outcome of the apply.
+// To be able to tell whether the object was created or updated, the object is read from the cluster before it is patched.
+// This means one additional GET request unless the SSA migration is checked, which reads the object anyway.
+func (c *SSAApplyClient) ApplyObjectWithResult(ctx context.Context, obj client.Object, options ...SSAApplyObjectOption) (*ApplyResult, error) {
+	return c.applyObject(ctx, obj, true, options...)
+}
+
+func (c *SSAApplyClient) applyObject(ctx context.Context, obj client.Object, withResult bool, options ...SSAApplyObjectOption) (*ApplyResult, error) {
 	config := newSSAApplyObjectConfiguration(options...)
 	if err := config.Configure(obj, c.Client.Scheme()); err != nil {
-		return composeError(obj, fmt.Errorf("failed to configure the apply function: %w", err))
+		return nil, composeError(obj, fmt.Errorf("failed to configure the apply function: %w", err))
 	}
 
 	if err := prepareForSSA(obj, c.Client.Scheme()); err != nil {
-		return composeError(obj, fmt.Errorf("failed to prepare the object for SSA: %w", err))
+		return nil, composeError(obj, fmt.Errorf("failed to prepare the object for SSA: %w", err))
+	}
+	result := newApplyResult(obj, c.Client.Scheme())
+
+	migrate := config.migrateSSA == migrateSSAYes || (config.migrateSSA == migrateSSANotSpecified && c.MigrateSSAByDefault)
+	var orig client.Object
+	if migrate || withResult {
+		var err error
+		if orig, err = c.getOriginal(ctx, obj); err != nil {
+			if migrate {
+				return nil, composeError(obj, fmt.Errorf("failed to get the object from the cluster while migrating managed fields: %w", err))
+			}
+			return nil, composeError(obj, fmt.Errorf("failed to get the object from the cluster: %w", err))
+		}
+		if orig != nil {
+			result.setOld(orig)
+		}
 	}
 
-	if config.migrateSSA == migrateSSAYes || (config.migrateSSA == migrateSSANotSpecified && c.MigrateSSAByDefault) {
-		if err := c.migrateSSA(ctx, obj); err != nil {
-			return composeError(obj, err)
+	if migrate && orig != nil {
+		if err := c.migrateSSA(ctx, orig.DeepCopyObject().(client.Object), config.dryRun != nil); err != nil {
+			return nil, composeError(obj, err)
 		}
 	}
 
 	if config.skipIf != nil && config.skipIf(obj) {
-		return nil
+		if config.dryRun != nil {
+			config.dryRun.add(ObjectDiff{
+				GVK:       obj.GetObjectKind().GroupVersionKind(),
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+				Skipped:   true,
+			})
+		}
+		result.Outcome = ApplyOutcomeSkipped
+		return result, nil
+	}
+
+	if config.dryRun != nil {
+		diff, err := c.dryRunApply(ctx, obj, config.dryRun)
+		if err != nil {
+			if config.recreatePolicy == nil || !IsImmutableFieldError(err) {
+				return nil, err
+			}
+			config.dryRun.add(ObjectDiff{
+				GVK:       obj.GetObjectKind().GroupVersionKind(),
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+				Recreated: true,
+			})
+			result.Outcome = ApplyOutcomeRecreated
+			result.Reason = ApplyReasonImmutableFieldChanged
+			return result, nil
+		}
+		switch {
+		case diff.Created:
+			result.Outcome = ApplyOutcomeCreated
+		case diff.HasChanges():
+			result.Outcome = ApplyOutcomeUpdated
+		default:
+			result.Outcome = ApplyOutcomeUnchanged
+		}
+		if !diff.Created {
+			result.Reason = ApplyReasonServerSideApply
+		}
+		return result, nil
 	}
 
 	if err := c.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(c.FieldOwner), client.ForceOwnership); err != nil {
-		return composeError(obj, err)
+		if config.recreatePolicy == nil || !IsImmutableFieldError(err) {
+			return nil, composeError(obj, err)
+		}
+		if err := c.recreate(ctx, obj, client.PropagationPolicy(*config.recreatePolicy)); err != nil {
+			return nil, composeError(obj, err)
+		}
+		result.setNew(obj)
+		result.Outcome = ApplyOutcomeRecreated
+		result.Reason = ApplyReasonImmutableFieldChanged
+		return result, nil
 	}
 
+	result.setNew(obj)
+	switch {
+	case !withResult:
+		// the original object was not necessarily read, so it's not possible to tell what happened
+	case orig == nil:
+		result.Outcome = ApplyOutcomeCreated
+	case orig.GetResourceVersion() != obj.GetResourceVersion():
+		// the generation is not incremented for all the kinds (eg. ConfigMaps or ServiceAccounts), but the resourceVersion is
+		result.Outcome = ApplyOutcomeUpdated
+		result.Reason = ApplyReasonServerSideApply
+	default:
+		result.Outcome = ApplyOutcomeUnchanged
+		result.Reason = ApplyReasonServerSideApply
+	}
+	return result, nil
+}
+
+// dryRunApply sends the SSA patch of a copy of the object in the dry-run mode and adds the diff between the live object
+// and the result of the patch to the report.
+func (c *SSAApplyClient) dryRunApply(ctx context.Context, obj client.Object, report *DryRunReport) (ObjectDiff, error) {
+	var live client.Object = &unstructured.Unstructured{}
+	live.GetObjectKind().SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ObjectDiff{}, composeError(obj, fmt.Errorf("failed to get the object from the cluster while computing the dry-run diff: %w", err))
+		}
+		live = nil
+	}
+
+	result := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Patch(ctx, result, client.Apply, client.FieldOwner(c.FieldOwner), client.ForceOwnership, client.DryRunAll); err != nil {
+		return ObjectDiff{}, composeError(obj, err)
+	}
+	// the result of the patch might lose the GVK when decoded into a typed object
+	result.GetObjectKind().SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+
+	diff, err := newObjectDiff(live, result)
+	if err != nil {
+		return ObjectDiff{}, composeError(obj, fmt.Errorf("failed to compute the dry-run diff: %w", err))
+	}
+	report.add(diff)
+	return diff, nil
+}
+
+// recreate deletes the object from the cluster, waits until it disappears and then creates it again using the SSA patch
+func (c *SSAApplyClient) recreate(ctx context.Context, obj client.Object, options ...client.DeleteOption) error {
+	if err := deleteAndWait(ctx, c.Client, obj.DeepCopyObject().(client.Object), options...); err != nil {
+		return err
+	}
+	if err := c.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(c.FieldOwner), client.ForceOwnership); err != nil {
+		return fmt.Errorf("failed to recreate the object: %w", err)
+	}
 	return nil
 }
 
-func (c *SSAApplyClient) migrateSSA(ctx context.Context, obj client.Object) error {
+// getOriginal returns the object as it is currently stored in the cluster or nil if it doesn't exist.
+func (c *SSAApplyClient) getOriginal(ctx context.Context, obj client.Object) (client.Object, error) {
 	orig := obj.DeepCopyObject().(client.Object)
 	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), orig); err != nil {
-		if !apierrors.IsNotFound(err) {
-			return fmt.Errorf("failed to get the object from the cluster while migrating managed fields: %w", err)
+		if apierrors.IsNotFound(err) {
+			return nil, nil
 		}
-		orig = nil
+		return nil, err
 	}
+	return orig, nil
+}
 
-	if orig != nil {
-		oldFieldOwner := c.NonSSAFieldOwner
-		if len(oldFieldOwner) == 0 {
-			// this is how the kubernetes api server determines the default owner from the user agent
-			// The default user agent has the form of "name-of-binary/version information etc.".
-			// The owner is the first part of the UA unless explicitly specified in the request URI.
-			oldFieldOwner = strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]
-		}
-		if isSsaMigrationNeeded(orig, oldFieldOwner) {
-			if err := migrateToSSA(ctx, c.Client, orig, oldFieldOwner, c.FieldOwner); err != nil {
-				return fmt.Errorf("failed to migrate the managed fields: %w", err)
-			}
+func (c *SSAApplyClient) migrateSSA(ctx context.Context, orig client.Object, dryRun bool) error {
+	oldFieldOwner := c.NonSSAFieldOwner
+	if len(oldFieldOwner) == 0 {
+		// this is how the kubernetes api server determines the default owner from the user agent
+		// The default user agent has the form of "name-of-binary/version information etc.".
+		// The owner is the first part of the UA unless explicitly specified in the request URI.
+		oldFieldOwner = strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]
+	}
+	if isSsaMigrationNeeded(orig, oldFieldOwner) {
+		if err := migrateToSSA(ctx, c.Client, orig, oldFieldOwner, c.FieldOwner, dryRun); err != nil {
+			return fmt.Errorf("failed to migrate the managed fields: %w", err)
 		}
 	}
 	return nil
@@ -243,9 +413,68 @@ func (c *SSAApplyClient) Apply(ctx context.Context, toolchainObjects []client.Ob
 
 // ApplyAll is a generic version of c.Apply that can accept a slice of anything that implements client.Object.
 func ApplyAll[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, opts ...SSAApplyObjectOption) error {
+	_, err := applyAll(ctx, cl, toolchainObjects, false, opts...)
+	return err
+}
+
+// ApplyAllWithResults works the same way as ApplyAll, but it also returns the result of the apply of every object.
+// If an error occurs, then it stops and returns the results of the objects applied so far.
+func ApplyAllWithResults[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, opts ...SSAApplyObjectOption) ([]*ApplyResult, error) {
+	return applyAll(ctx, cl, toolchainObjects, true, opts...)
+}
+
+// ApplyAllConcurrently applies the objects in the dependency order of their kinds: Namespaces and CRDs first, then ServiceAccounts
+// and Roles, then RoleBindings and then everything else (see KindTier). The objects of the kinds in the same tier are applied
+// concurrently using at most the given number of goroutines, so any function passed in the options (eg. SkipIf) must be safe
+// for concurrent use.
+//
+// Unlike ApplyAll, it doesn't stop at the first error but applies all the objects of the same tier and returns all the errors
+// as an aggregate. The objects of the next tiers are not applied, because they may depend on the ones that failed. No more objects
+// are applied once the context is cancelled. The results are returned in the order of the supplied objects, with nil results
+// for the objects that failed or that were not applied. The objects are pruned only if all of them were applied successfully.
+func ApplyAllConcurrently[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, parallelism int, opts ...SSAApplyObjectOption) ([]*ApplyResult, error) {
+	results, err := applyInTiers(ctx, toolchainObjects, cl.Client.Scheme(), parallelism, func(ctx context.Context, obj T) (*ApplyResult, error) {
+		return cl.applyObject(ctx, obj, true, opts...)
+	})
+	if err != nil {
+		return results, err
+	}
+	return results, prune(ctx, cl, toolchainObjects, opts...)
+}
+
+func applyAll[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, withResults bool, opts ...SSAApplyObjectOption) ([]*ApplyResult, error) {
+	results := make([]*ApplyResult, 0, len(toolchainObjects))
 	for _, toolchainObject := range toolchainObjects {
-		if err := cl.ApplyObject(ctx, toolchainObject, opts...); err != nil {
-			return err
+		result, err := cl.applyObject(ctx, toolchainObject, withResults, opts...)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, prune(ctx, cl, toolchainObjects, opts...)
+}
+
+// prune deletes the stale objects of the apply group if the WithPrune option is used
+func prune[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, opts ...SSAApplyObjectOption) error {
+	config := newSSAApplyObjectConfiguration(opts...)
+	if config.pruneGroup != "" {
+		pruneOpts := config.pruneOpts
+		if config.dryRun != nil {
+			pruneOpts = append(pruneOpts[:len(pruneOpts):len(pruneOpts)], PruneDryRun(true))
+		}
+		pruned, err := Prune(ctx, cl.Client, config.pruneGroup, toolchainObjects, pruneOpts...)
+		if err != nil {
+			return fmt.Errorf("failed to prune the objects of the apply group '%s': %w", config.pruneGroup, err)
+		}
+		if config.dryRun != nil {
+			for _, obj := range pruned {
+				config.dryRun.add(ObjectDiff{
+					GVK:       obj.GetObjectKind().GroupVersionKind(),
+					Namespace: obj.GetNamespace(),
+					Name:      obj.GetName(),
+					Pruned:    true,
+				})
+			}
 		}
 	}
 	return nil
@@ -260,9 +489,12 @@ func isSsaMigrationNeeded(obj client.Object, expectedOwner string) bool {
 	return false
 }
 
-func migrateToSSA(ctx context.Context, cl client.Client, obj client.Object, oldFieldOwner, newFieldOwner string) error {
+func migrateToSSA(ctx context.Context, cl client.Client, obj client.Object, oldFieldOwner, newFieldOwner string, dryRun bool) error {
 	if err := csaupgrade.UpgradeManagedFields(obj, sets.New(oldFieldOwner), newFieldOwner); err != nil {
 		return err
 	}
+	if dryRun {
+		return cl.Update(ctx, obj, client.DryRunAll)
+	}
 	return cl.Update(ctx, obj)
 }
@@ -5,6 +5,7 @@ import (
 	stderrors "errors"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/codeready-toolchain/toolchain-common/pkg/client"
@@ -12,11 +13,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/rest"
 	"k8s.io/utils/ptr"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -242,6 +245,270 @@ func TestSsaClient(t *testing.T) {
 				})
 			}
 		})
+		t.Run("DryRun", func(t *testing.T) {
+			t.Run("reports diff of an existing object", func(t *testing.T) {
+				// given
+				obj := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "obj",
+						Namespace: "default",
+					},
+					Data: map[string]string{"a": "b", "c": "d"},
+				}
+				cl, acl := NewTestSsaApplyClient(t, obj)
+				updated := obj.DeepCopy()
+				updated.Data["a"] = "changed"
+				updated.Data["e"] = "f"
+				report := &client.DryRunReport{}
+
+				// when
+				require.NoError(t, acl.ApplyObject(context.TODO(), updated, client.DryRun(report), client.EnsureLabels(map[string]string{"l": "v"})))
+
+				// then
+				inCluster := &corev1.ConfigMap{}
+				require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), inCluster))
+				assert.Equal(t, obj.Data, inCluster.Data)
+				assert.Empty(t, inCluster.Labels)
+
+				require.Len(t, report.Diffs, 1)
+				diff := report.Diffs[0]
+				assert.Equal(t, "ConfigMap", diff.GVK.Kind)
+				assert.Equal(t, "obj", diff.Name)
+				assert.Equal(t, "default", diff.Namespace)
+				assert.False(t, diff.Created)
+				assert.Equal(t, []client.FieldDiff{
+					{Path: "data.e", New: "f"},
+					{Path: "metadata.labels", New: map[string]any{"l": "v"}},
+				}, diff.Added)
+				assert.Equal(t, []client.FieldDiff{{Path: "data.a", Old: "b", New: "changed"}}, diff.Changed)
+				assert.Empty(t, diff.Removed)
+				assert.True(t, report.HasChanges())
+			})
+			t.Run("reports no changes", func(t *testing.T) {
+				// given
+				obj := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "obj",
+						Namespace: "default",
+					},
+					Data: map[string]string{"a": "b"},
+				}
+				_, acl := NewTestSsaApplyClient(t, obj)
+				report := &client.DryRunReport{}
+
+				// when
+				require.NoError(t, acl.ApplyObject(context.TODO(), obj.DeepCopy(), client.DryRun(report)))
+
+				// then
+				require.Len(t, report.Diffs, 1)
+				assert.False(t, report.Diffs[0].HasChanges())
+				assert.False(t, report.HasChanges())
+			})
+			t.Run("reports creation", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				obj := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "obj",
+						Namespace: "default",
+					},
+					Data: map[string]string{"a": "b"},
+				}
+				report := &client.DryRunReport{}
+
+				// when
+				require.NoError(t, acl.ApplyObject(context.TODO(), obj, client.DryRun(report)))
+
+				// then
+				require.True(t, errors.IsNotFound(cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), &corev1.ConfigMap{})))
+				require.Len(t, report.Diffs, 1)
+				assert.True(t, report.Diffs[0].Created)
+				assert.Contains(t, report.Diffs[0].Added, client.FieldDiff{Path: "data", New: map[string]any{"a": "b"}})
+			})
+			t.Run("reports skipped objects", func(t *testing.T) {
+				// given
+				_, acl := NewTestSsaApplyClient(t)
+				obj := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "obj",
+						Namespace: "default",
+					},
+				}
+				report := &client.DryRunReport{}
+
+				// when
+				require.NoError(t, acl.ApplyObject(context.TODO(), obj, client.DryRun(report), client.SkipIf(func(runtimeclient.Object) bool {
+					return true
+				})))
+
+				// then
+				require.Len(t, report.Diffs, 1)
+				assert.True(t, report.Diffs[0].Skipped)
+				assert.False(t, report.HasChanges())
+			})
+			t.Run("doesn't migrate managed fields", func(t *testing.T) {
+				// given
+				obj := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "obj",
+						Namespace: "default",
+						ManagedFields: []metav1.ManagedFieldsEntry{
+							{
+								FieldsType: "FieldsV1",
+								FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data": {"f:a": {}}}`)},
+								Manager:    strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0],
+								Operation:  metav1.ManagedFieldsOperationUpdate,
+							},
+						},
+					},
+					Data: map[string]string{"a": "b"},
+				}
+				cl, acl := NewTestSsaApplyClient(t, obj)
+				toApply := obj.DeepCopy()
+				toApply.SetManagedFields(nil)
+				report := &client.DryRunReport{}
+
+				// when
+				require.NoError(t, acl.ApplyObject(context.TODO(), toApply, client.DryRun(report), client.MigrateSSA(true)))
+
+				// then
+				inCluster := &corev1.ConfigMap{}
+				require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), inCluster))
+				require.Len(t, inCluster.ManagedFields, 1)
+				assert.Equal(t, metav1.ManagedFieldsOperationUpdate, inCluster.ManagedFields[0].Operation)
+				require.Len(t, report.Diffs, 1)
+			})
+			t.Run("reports pruned objects", func(t *testing.T) {
+				// given
+				stale := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "stale",
+						Namespace: "default",
+						Labels:    map[string]string{client.ApplyGroupLabelKey: "group"},
+					},
+				}
+				cl, acl := NewTestSsaApplyClient(t, stale)
+				obj := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "obj",
+						Namespace: "default",
+					},
+				}
+				report := &client.DryRunReport{}
+
+				// when
+				require.NoError(t, acl.Apply(context.TODO(), []runtimeclient.Object{obj}, client.DryRun(report), client.WithPrune("group")))
+
+				// then
+				require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(stale), &corev1.ConfigMap{}))
+				require.Len(t, report.Diffs, 2)
+				assert.True(t, report.Diffs[0].Created)
+				assert.True(t, report.Diffs[1].Pruned)
+				assert.Equal(t, "stale", report.Diffs[1].Name)
+			})
+		})
+		t.Run("WithResult", func(t *testing.T) {
+			newCm := func(value string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "obj",
+						Namespace: "default",
+					},
+					Data: map[string]string{"a": value},
+				}
+			}
+			t.Run("created", func(t *testing.T) {
+				// given
+				_, acl := NewTestSsaApplyClient(t)
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newCm("b"))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeCreated, result.Outcome)
+				assert.Equal(t, "ConfigMap", result.GVK.Kind)
+				assert.Empty(t, result.OldResourceVersion)
+				assert.NotEmpty(t, result.NewResourceVersion)
+			})
+			t.Run("updated", func(t *testing.T) {
+				// given
+				_, acl := NewTestSsaApplyClient(t, newCm("b"))
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newCm("c"))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeUpdated, result.Outcome)
+				assert.Equal(t, client.ApplyReasonServerSideApply, result.Reason)
+				assert.Equal(t, result.OldGeneration+1, result.NewGeneration)
+				assert.NotEqual(t, result.OldResourceVersion, result.NewResourceVersion)
+			})
+			t.Run("updated without generation change", func(t *testing.T) {
+				// given
+				_, acl := NewTestSsaApplyClient(t, newCm("b"))
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newCm("b"), client.EnsureLabels(map[string]string{"version": "2"}))
+
+				// then
+				require.NoError(t, err)
+				// only the labels changed, so the generation is the same
+				assert.Equal(t, client.ApplyOutcomeUpdated, result.Outcome)
+				assert.Equal(t, result.OldGeneration, result.NewGeneration)
+				assert.NotEqual(t, result.OldResourceVersion, result.NewResourceVersion)
+			})
+			t.Run("unchanged", func(t *testing.T) {
+				// given
+				_, acl := NewTestSsaApplyClient(t, newCm("b"))
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newCm("b"), client.MigrateSSA(true))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeUnchanged, result.Outcome)
+				assert.Equal(t, result.OldGeneration, result.NewGeneration)
+			})
+			t.Run("skipped", func(t *testing.T) {
+				// given
+				_, acl := NewTestSsaApplyClient(t)
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newCm("b"), client.SkipIf(func(runtimeclient.Object) bool {
+					return true
+				}))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeSkipped, result.Outcome)
+			})
+			t.Run("dry run", func(t *testing.T) {
+				// given
+				_, acl := NewTestSsaApplyClient(t, newCm("b"))
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newCm("c"), client.DryRun(&client.DryRunReport{}))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeUpdated, result.Outcome)
+			})
+			t.Run("get error", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+					return fmt.Errorf("boom")
+				}
+
+				// when
+				_, err := acl.ApplyObjectWithResult(context.TODO(), newCm("b"))
+
+				// then
+				require.EqualError(t, err, "unable to patch '/v1, Kind=ConfigMap' called 'obj' in namespace 'default': failed to get the object from the cluster: boom")
+			})
+		})
 		t.Run("propagates k8s errors", func(t *testing.T) {
 			// given
 			cl, acl := NewTestSsaApplyClient(t)
@@ -261,6 +528,88 @@ func TestSsaClient(t *testing.T) {
 			// then
 			assert.True(t, errors.IsForbidden(err))
 		})
+		t.Run("recreate on immutable change", func(t *testing.T) {
+			newCm := func(value string) *corev1.ConfigMap {
+				return &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "obj",
+						Namespace: "default",
+					},
+					Data: map[string]string{"a": value},
+				}
+			}
+			immutableErr := errors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "obj", field.ErrorList{
+				field.Invalid(field.NewPath("data"), "b", "field is immutable"),
+			})
+			// rejects the patch of the existing object, but allows its creation
+			setupImmutable := func(cl *test.FakeClient) {
+				cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+					if err := cl.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(obj), &corev1.ConfigMap{}); err == nil {
+						return immutableErr
+					}
+					return test.Patch(ctx, cl, obj, patch, opts...)
+				}
+			}
+
+			t.Run("recreates the object", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				require.NoError(t, acl.ApplyObject(context.TODO(), newCm("a")))
+				setupImmutable(cl)
+				var deleteOpts []runtimeclient.DeleteOption
+				cl.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+					deleteOpts = opts
+					return cl.Client.Delete(ctx, obj, opts...)
+				}
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newCm("b"), client.WithRecreateOnImmutableChange(metav1.DeletePropagationForeground))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeRecreated, result.Outcome)
+				assert.Equal(t, client.ApplyReasonImmutableFieldChanged, result.Reason)
+				assert.Equal(t, []runtimeclient.DeleteOption{runtimeclient.PropagationPolicy(metav1.DeletePropagationForeground)}, deleteOpts)
+				inCluster := &corev1.ConfigMap{}
+				require.NoError(t, cl.Client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(newCm("")), inCluster))
+				assert.Equal(t, "b", inCluster.Data["a"])
+			})
+
+			t.Run("reports the recreate in dry-run", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				require.NoError(t, acl.ApplyObject(context.TODO(), newCm("a")))
+				setupImmutable(cl)
+				report := &client.DryRunReport{}
+
+				// when
+				result, err := acl.ApplyObjectWithResult(context.TODO(), newCm("b"), client.WithRecreateOnImmutableChange(metav1.DeletePropagationBackground), client.DryRun(report))
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, client.ApplyOutcomeRecreated, result.Outcome)
+				require.Len(t, report.Diffs, 1)
+				assert.True(t, report.Diffs[0].Recreated)
+				assert.True(t, report.HasChanges())
+				inCluster := &corev1.ConfigMap{}
+				require.NoError(t, cl.Client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(newCm("")), inCluster))
+				assert.Equal(t, "a", inCluster.Data["a"])
+			})
+
+			t.Run("returns the error when not enabled", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				require.NoError(t, acl.ApplyObject(context.TODO(), newCm("a")))
+				setupImmutable(cl)
+
+				// when
+				err := acl.ApplyObject(context.TODO(), newCm("b"))
+
+				// then
+				require.Error(t, err)
+				assert.True(t, client.IsImmutableFieldError(err))
+			})
+		})
 		t.Run("error message format", func(t *testing.T) {
 			t.Run("on option application error", func(t *testing.T) {
 				// given
@@ -383,6 +732,167 @@ func TestSsaClient(t *testing.T) {
 			require.NoError(t, cl.List(context.TODO(), inCluster))
 			assert.Len(t, inCluster.Items, 1)
 		})
+		t.Run("with results", func(t *testing.T) {
+			// given
+			existing := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "obj1",
+					Namespace: "default",
+				},
+			}
+			_, acl := NewTestSsaApplyClient(t, existing)
+			obj2 := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "obj2",
+					Namespace: "default",
+				},
+			}
+
+			// when
+			results, err := client.ApplyAllWithResults(context.TODO(), acl, []*corev1.ConfigMap{existing.DeepCopy(), obj2})
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assert.Equal(t, client.ApplyOutcomeUnchanged, results[0].Outcome)
+			assert.Equal(t, client.ApplyOutcomeCreated, results[1].Outcome)
+		})
+		t.Run("concurrently", func(t *testing.T) {
+			newObjects := func() []runtimeclient.Object {
+				return []runtimeclient.Object{
+					&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "ns"}},
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm1", Namespace: "ns"}},
+					&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "ns"}},
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm2", Namespace: "ns"}},
+					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+				}
+			}
+
+			t.Run("applies in dependency order", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				var lock sync.Mutex
+				var appliedTiers []int
+				cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+					lock.Lock()
+					appliedTiers = append(appliedTiers, client.KindTier(obj.GetObjectKind().GroupVersionKind().GroupKind()))
+					lock.Unlock()
+					return test.Patch(ctx, cl, obj, patch, opts...)
+				}
+
+				// when
+				results, err := client.ApplyAllConcurrently(context.TODO(), acl, newObjects(), 2)
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, []int{0, 1, 1, 1, 2}, appliedTiers)
+				require.Len(t, results, 5)
+				assert.Equal(t, "rb", results[0].Name)
+				assert.Equal(t, "ns", results[4].Name)
+				for _, result := range results {
+					assert.Equal(t, client.ApplyOutcomeCreated, result.Outcome)
+				}
+			})
+
+			t.Run("aggregates errors and stops after the failed tier", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+					if obj.GetObjectKind().GroupVersionKind().Kind == "ConfigMap" {
+						return fmt.Errorf("boom")
+					}
+					return test.Patch(ctx, cl, obj, patch, opts...)
+				}
+
+				// when
+				results, err := client.ApplyAllConcurrently(context.TODO(), acl, newObjects(), 0)
+
+				// then
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unable to patch '/v1, Kind=ConfigMap' called 'cm1' in namespace 'ns': boom")
+				assert.Contains(t, err.Error(), "unable to patch '/v1, Kind=ConfigMap' called 'cm2' in namespace 'ns': boom")
+				require.Len(t, results, 5)
+				assert.Nil(t, results[1])
+				assert.Nil(t, results[3])
+				// the other objects of the same tier are still applied
+				require.NotNil(t, results[2])
+				assert.Equal(t, client.ApplyOutcomeCreated, results[2].Outcome)
+				// but not the objects in the later tiers
+				assert.Nil(t, results[0])
+				err = cl.Get(context.TODO(), runtimeclient.ObjectKey{Namespace: "ns", Name: "rb"}, &rbacv1.RoleBinding{})
+				assert.True(t, errors.IsNotFound(err))
+			})
+
+			t.Run("stops when the context is cancelled", func(t *testing.T) {
+				// given
+				cl, acl := NewTestSsaApplyClient(t)
+				ctx, cancel := context.WithCancel(context.TODO())
+				cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+					// the context is cancelled while the namespace is applied
+					cancel()
+					return test.Patch(ctx, cl, obj, patch, opts...)
+				}
+
+				// when
+				results, err := client.ApplyAllConcurrently(ctx, acl, newObjects(), 1)
+
+				// then
+				require.ErrorIs(t, err, context.Canceled)
+				require.Len(t, results, 5)
+				require.NotNil(t, results[4])
+				assert.Equal(t, client.ApplyOutcomeCreated, results[4].Outcome)
+				for _, result := range results[:4] {
+					assert.Nil(t, result)
+				}
+			})
+		})
+		t.Run("WithPrune", func(t *testing.T) {
+			// given
+			stale := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "stale",
+					Namespace: "default",
+					Labels:    map[string]string{client.ApplyGroupLabelKey: "group"},
+				},
+			}
+			cl, acl := NewTestSsaApplyClient(t, stale)
+			obj := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "obj",
+					Namespace: "default",
+				},
+			}
+
+			// when
+			err := acl.Apply(context.TODO(), []runtimeclient.Object{obj}, client.WithPrune("group"))
+
+			// then
+			require.NoError(t, err)
+			inCluster := &corev1.ConfigMapList{}
+			require.NoError(t, cl.List(context.TODO(), inCluster))
+			require.Len(t, inCluster.Items, 1)
+			assert.Equal(t, "obj", inCluster.Items[0].Name)
+			assert.Equal(t, "group", inCluster.Items[0].Labels[client.ApplyGroupLabelKey])
+		})
+		t.Run("WithPrune fails", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t)
+			cl.MockList = func(ctx context.Context, list runtimeclient.ObjectList, opts ...runtimeclient.ListOption) error {
+				return fmt.Errorf("boom")
+			}
+			obj := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "obj",
+					Namespace: "default",
+				},
+			}
+
+			// when
+			err := acl.Apply(context.TODO(), []runtimeclient.Object{obj}, client.WithPrune("group"))
+
+			// then
+			require.EqualError(t, err, "failed to prune the objects of the apply group 'group': unable to list the objects of kind '/v1, Kind=ConfigMap' in the apply group 'group': boom")
+		})
 	})
 }
 
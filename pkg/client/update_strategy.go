@@ -0,0 +1,134 @@
+package client
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UpdateAction tells the ApplyClient what to do with an object that already exists in the cluster
+type UpdateAction string
+
+const (
+	// UpdateActionUpdate the existing object is updated with the object returned by the UpdateStrategy
+	UpdateActionUpdate UpdateAction = "Update"
+	// UpdateActionRecreate the existing object is deleted and the object returned by the UpdateStrategy is created instead
+	UpdateActionRecreate UpdateAction = "Recreate"
+	// UpdateActionSkip the existing object is left untouched
+	UpdateActionSkip UpdateAction = "Skip"
+)
+
+// UpdateStrategy is called by the ApplyClient before an existing object is updated. It receives the desired object
+// and the object that exists in the cluster, and it returns the object that should be sent to the server (typically the desired
+// object with some fields copied from the existing one) together with the action to perform.
+// The returned object must have the same kind as the desired object.
+type UpdateStrategy func(desired, existing client.Object) (client.Object, UpdateAction, error)
+
+// UpdateStrategyRegistry holds the UpdateStrategies used by the ApplyClient for the objects of the given kinds
+type UpdateStrategyRegistry struct {
+	mutex      sync.RWMutex
+	strategies map[schema.GroupVersionKind]UpdateStrategy
+}
+
+// NewUpdateStrategyRegistry returns a new registry without any UpdateStrategy
+func NewUpdateStrategyRegistry() *UpdateStrategyRegistry {
+	return &UpdateStrategyRegistry{
+		strategies: map[schema.GroupVersionKind]UpdateStrategy{},
+	}
+}
+
+// NewDefaultUpdateStrategyRegistry returns a new registry with the UpdateStrategies for ServiceAccounts and Services
+func NewDefaultUpdateStrategyRegistry() *UpdateStrategyRegistry {
+	registry := NewUpdateStrategyRegistry()
+	registry.Register(corev1.SchemeGroupVersion.WithKind("ServiceAccount"), KeepExistingServiceAccount)
+	registry.Register(corev1.SchemeGroupVersion.WithKind("Service"), RetainServiceClusterIP)
+	return registry
+}
+
+// Register sets the UpdateStrategy for the objects of the given kind. Any strategy that was previously registered for the same kind is replaced.
+func (r *UpdateStrategyRegistry) Register(gvk schema.GroupVersionKind, strategy UpdateStrategy) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.strategies[gvk] = strategy
+}
+
+// Get returns the UpdateStrategy registered for the given kind
+func (r *UpdateStrategyRegistry) Get(gvk schema.GroupVersionKind) (UpdateStrategy, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	strategy, found := r.strategies[gvk]
+	return strategy, found
+}
+
+// KeepExistingServiceAccount is the UpdateStrategy for ServiceAccounts.
+// If a ServiceAccount is reapplied when it already exists, it causes Kubernetes controllers to automatically create new Secrets
+// for the ServiceAccount. After enough time the number of Secrets created will hit the Secrets quota and then no new Secrets
+// can be created. To prevent this from happening, only the labels and annotations are merged into the existing object, so that
+// the references to the existing secrets are kept.
+func KeepExistingServiceAccount(desired, existing client.Object) (client.Object, UpdateAction, error) {
+	merged := existing.DeepCopyObject().(client.Object)
+	MergeAnnotations(merged, desired.GetAnnotations())
+	MergeLabels(merged, desired.GetLabels())
+	return merged, UpdateActionUpdate, nil
+}
+
+// RetainServiceClusterIP is the UpdateStrategy for Services. It retains the `spec.clusterIP` of the existing Service,
+// otherwise the update would fail with the following error:
+// `Service "<name>" is invalid: spec.clusterIP: Invalid value: "": field is immutable`
+func RetainServiceClusterIP(desired, existing client.Object) (client.Object, UpdateAction, error) {
+	if err := RetainClusterIP(desired, existing); err != nil {
+		return nil, "", err
+	}
+	return desired, UpdateActionUpdate, nil
+}
+
+// RetainFields returns an UpdateStrategy that copies the fields at the given paths from the existing object into the desired one.
+// This is useful for the fields that are immutable or that are populated by the server, such as `spec.volumeName` of a PersistentVolumeClaim
+// or `spec.host` of a Route. The fields that are not set in the existing object are left untouched.
+func RetainFields(paths ...[]string) UpdateStrategy {
+	return func(desired, existing client.Object) (client.Object, UpdateAction, error) {
+		existingContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+		if err != nil {
+			return nil, "", err
+		}
+		desiredContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, path := range paths {
+			value, found, err := unstructured.NestedFieldCopy(existingContent, path...)
+			if err != nil {
+				return nil, "", err
+			}
+			if !found {
+				continue
+			}
+			if err := unstructured.SetNestedField(desiredContent, value, path...); err != nil {
+				return nil, "", err
+			}
+		}
+		if u, ok := desired.(*unstructured.Unstructured); ok {
+			u.SetUnstructuredContent(desiredContent)
+			return u, UpdateActionUpdate, nil
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(desiredContent, desired); err != nil {
+			return nil, "", err
+		}
+		return desired, UpdateActionUpdate, nil
+	}
+}
+
+// Recreate is an UpdateStrategy that always deletes the existing object and creates the desired one instead.
+// This is useful for the kinds which are (mostly) immutable, such as Jobs.
+func Recreate(desired, _ client.Object) (client.Object, UpdateAction, error) {
+	return desired, UpdateActionRecreate, nil
+}
+
+// SkipUpdate is an UpdateStrategy that never updates the existing object. The object is only created when it's missing.
+func SkipUpdate(desired, _ client.Object) (client.Object, UpdateAction, error) {
+	return desired, UpdateActionSkip, nil
+}
@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"k8s.io/client-go/rest"
+)
+
+// bearerToken is the bearer token used by the client of a cached ToolchainCluster. The transport of the client reads the current
+// token on every request, so the token can be replaced (eg. when it's rotated) without creating a new client.
+type bearerToken struct {
+	value atomic.Pointer[string]
+}
+
+// newBearerToken returns the replaceable token of the given rest config, or nil if the config doesn't use a static bearer token
+// (the tokens read from a file are already reloaded by the transport)
+func newBearerToken(restConfig *rest.Config) *bearerToken {
+	if restConfig.BearerToken == "" || restConfig.BearerTokenFile != "" {
+		return nil
+	}
+	t := &bearerToken{}
+	t.set(restConfig.BearerToken)
+	return t
+}
+
+func (t *bearerToken) get() string {
+	return *t.value.Load()
+}
+
+func (t *bearerToken) set(token string) {
+	t.value.Store(&token)
+}
+
+// wrap returns a copy of the given rest config whose transport sends the current token instead of the static one
+func (t *bearerToken) wrap(restConfig *rest.Config) *rest.Config {
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// the round trippers must not modify the request
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+t.get())
+			return rt.RoundTrip(req)
+		})
+	})
+	return restConfig
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -1,19 +1,81 @@
 package cluster
 
 import (
+	"context"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-var clusterCache = toolchainClusterClients{clusters: map[string]*CachedToolchainCluster{}}
+// clusterCache is the default ClusterCache used by the package-level functions (such as GetCachedToolchainCluster or GetMemberClusters)
+// and by the ToolchainClusterServices which are not created with their own ClusterCache
+var clusterCache = NewClusterCache()
 
-type toolchainClusterClients struct {
+// ClusterCache holds the CachedToolchainClusters. It's safe for concurrent use.
+//
+// When a cluster is missing in the cache, then it's loaded by the loader of the ToolchainClusterService which uses the cache.
+// Only one load per cluster name is done at a time, and the concurrent lookups of the same cluster wait for its result
+// instead of loading the cluster again.
+type ClusterCache struct {
 	sync.RWMutex
-	clusters     map[string]*CachedToolchainCluster
+	clusters map[string]*CachedToolchainCluster
+	// loadCluster loads the single cluster with the given name in the cache
+	loadCluster func(name string)
+	// refreshCache loads all the clusters in the cache
 	refreshCache func()
+	loading      singleflight.Group
+	// watchers receive the events of the changes of the clusters (see Watch)
+	watchers map[*clusterWatcher]struct{}
+	// removedCallbacks are called when the clusters are removed (see OnClusterRemoved)
+	removedCallbacks []ClusterRemovedCallback
+}
+
+// NewClusterCache returns a new empty ClusterCache
+func NewClusterCache() *ClusterCache {
+	return &ClusterCache{
+		clusters: map[string]*CachedToolchainCluster{},
+	}
+}
+
+// setLoaders sets the functions which load a single cluster and all the clusters when they are missing in the cache
+func (c *ClusterCache) setLoaders(loadCluster func(name string), refreshCache func()) {
+	c.Lock()
+	defer c.Unlock()
+	c.loadCluster = loadCluster
+	c.refreshCache = refreshCache
+}
+
+// Get returns the cluster with the given name. If the cluster is missing, then it's loaded first.
+func (c *ClusterCache) Get(name string) (*CachedToolchainCluster, bool) {
+	return c.getCachedToolchainCluster(name, true)
+}
+
+// List returns all the clusters matching the given conditions. If the cache is empty, then the clusters are loaded first.
+func (c *ClusterCache) List(conditions ...Condition) []*CachedToolchainCluster {
+	clusters := c.getCachedToolchainClusters(conditions...)
+	if len(clusters) == 0 {
+		c.refresh()
+		clusters = c.getCachedToolchainClusters(conditions...)
+	}
+	return clusters
+}
+
+// Add adds the given cluster in the cache, or replaces the cluster with the same name
+func (c *ClusterCache) Add(cluster *CachedToolchainCluster) {
+	c.addCachedToolchainCluster(cluster)
+}
+
+// Delete removes the cluster with the given name from the cache
+func (c *ClusterCache) Delete(name string) {
+	c.deleteCachedToolchainCluster(name)
 }
 
 type Config struct {
@@ -35,6 +97,16 @@ type Config struct {
 	// Labels contains all the labels of the corresponding ToolchainCluster.
 	// They will be used for filtering ToolchainCluster's based on a given list of cluster-role labels.
 	Labels map[string]string `json:"labels,omitempty"`
+
+	// ClientOptions are the options which were used to tune the RestConfig
+	ClientOptions ClientOptions `json:"-"`
+
+	// ClientCertificateNotAfter is the expiry of the client certificate used to connect to the cluster, if any
+	ClientCertificateNotAfter *time.Time `json:"-"`
+	// CANotAfter is the earliest expiry of the CA certificates used to verify the cluster, if any
+	CANotAfter *time.Time `json:"-"`
+	// CertificatesError is the error of the parsing of the client certificate or of the CA certificates, if any
+	CertificatesError error `json:"-"`
 }
 
 // CachedToolchainCluster stores cluster client; cluster related info and previous health check probe results
@@ -44,33 +116,102 @@ type CachedToolchainCluster struct {
 	Client client.Client
 	// ClusterStatus is the cluster result as of the last health check probe.
 	ClusterStatus *toolchainv1alpha1.ToolchainClusterStatus
+	// ctx is cancelled when the cluster is removed from the cache (see Context)
+	ctx    context.Context
+	cancel context.CancelFunc
+	// runtime is the lazily started controller-runtime cluster (see RuntimeCluster)
+	runtime *runtimeCluster
+	// token is the bearer token used by the Client and the runtime cluster, nil if they don't use a static bearer token
+	token *bearerToken
 }
 
-func (c *toolchainClusterClients) addCachedToolchainCluster(cluster *CachedToolchainCluster) {
+func (c *ClusterCache) addCachedToolchainCluster(cluster *CachedToolchainCluster) {
 	c.Lock()
 	defer c.Unlock()
+	previous := c.clusters[cluster.Name]
+	cluster.inheritContext(previous)
 	c.clusters[cluster.Name] = cluster
+	c.publish(clusterEvents(previous, cluster)...)
 }
 
-func (c *toolchainClusterClients) deleteCachedToolchainCluster(name string) {
+// replaceCachedToolchainCluster replaces the given cached cluster with the new one, but only if the given cluster is still the one
+// cached with the same name. It returns false if the cluster was replaced or removed in the meantime.
+func (c *ClusterCache) replaceCachedToolchainCluster(previous, cluster *CachedToolchainCluster) bool {
 	c.Lock()
 	defer c.Unlock()
-	delete(c.clusters, name)
+	if c.clusters[cluster.Name] != previous {
+		return false
+	}
+	cluster.inheritContext(previous)
+	c.clusters[cluster.Name] = cluster
+	c.publish(clusterEvents(previous, cluster)...)
+	return true
+}
+
+func (c *ClusterCache) deleteCachedToolchainCluster(name string) {
+	c.Lock()
+	previous, ok := c.clusters[name]
+	if ok {
+		delete(c.clusters, name)
+		c.publish(clusterEvents(previous, nil)...)
+	}
+	callbacks := c.removedCallbacks
+	c.Unlock()
+	if ok {
+		c.removed(previous, callbacks)
+	}
+}
+
+func (c *ClusterCache) getCachedToolchainCluster(name string, canRefreshCache bool) (*CachedToolchainCluster, bool) {
+	cluster, ok := c.get(name)
+	if ok || !canRefreshCache {
+		return cluster, ok
+	}
+	c.load(name)
+	return c.get(name)
 }
 
-func (c *toolchainClusterClients) getCachedToolchainCluster(name string, canRefreshCache bool) (*CachedToolchainCluster, bool) {
+func (c *ClusterCache) get(name string) (*CachedToolchainCluster, bool) {
 	c.RLock()
 	defer c.RUnlock()
-	_, ok := c.clusters[name]
-	if !ok && canRefreshCache && c.refreshCache != nil {
-		c.RUnlock()
-		c.refreshCache()
-		c.RLock()
-	}
 	cluster, ok := c.clusters[name]
 	return cluster, ok
 }
 
+// load loads the cluster with the given name using the loadCluster function, or loads all the clusters using the refreshCache
+// function if the former is not set. The lock must not be held, as the functions add the loaded clusters to the cache.
+func (c *ClusterCache) load(name string) {
+	c.RLock()
+	loadCluster := c.loadCluster
+	c.RUnlock()
+	if loadCluster == nil {
+		c.refresh()
+		return
+	}
+	_, _, _ = c.loading.Do("cluster/"+name, func() (interface{}, error) {
+		// the cluster may have been loaded by a previous lookup which completed in the meantime
+		if _, ok := c.get(name); ok {
+			return nil, nil
+		}
+		loadCluster(name)
+		return nil, nil
+	})
+}
+
+// refresh loads all the clusters using the refreshCache function. The lock must not be held.
+func (c *ClusterCache) refresh() {
+	c.RLock()
+	refreshCache := c.refreshCache
+	c.RUnlock()
+	if refreshCache == nil {
+		return
+	}
+	_, _, _ = c.loading.Do("all", func() (interface{}, error) {
+		refreshCache()
+		return nil, nil
+	})
+}
+
 // Condition an expected cluster condition
 type Condition func(cluster *CachedToolchainCluster) bool
 
@@ -79,7 +220,81 @@ var Ready Condition = func(cluster *CachedToolchainCluster) bool {
 	return IsReady(cluster.ClusterStatus)
 }
 
-func (c *toolchainClusterClients) getCachedToolchainClusters(conditions ...Condition) []*CachedToolchainCluster {
+// WithRole checks that the cluster has the given role (see RoleLabel)
+func WithRole(role Role) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		_, found := cluster.Labels[RoleLabel(role)]
+		return found
+	}
+}
+
+// MatchingSelector checks that the labels of the cluster match the given selector
+func MatchingSelector(selector labels.Selector) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return selector.Matches(labels.Set(cluster.Labels))
+	}
+}
+
+// OwnedBy checks that the ToolchainCluster resource of the cluster is created in the cluster with the given name (see Config.OwnerClusterName)
+func OwnedBy(name string) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return cluster.OwnerClusterName == name
+	}
+}
+
+// ReadyWithin checks that the cluster is in a 'Ready' status condition, and that it was reported as such
+// by a health check done within the given duration
+func ReadyWithin(duration time.Duration) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		probeTime := lastProbeTime(cluster)
+		return Ready(cluster) && !probeTime.IsZero() && time.Since(probeTime) <= duration
+	}
+}
+
+// lastProbeTime returns the time of the last health check of the cluster, ie, the time when its Ready condition was updated
+// for the last time, or the zero time if the cluster wasn't checked yet
+func lastProbeTime(cluster *CachedToolchainCluster) time.Time {
+	if cluster.ClusterStatus == nil {
+		return time.Time{}
+	}
+	ready, found := condition.FindConditionByType(cluster.ClusterStatus.Conditions, toolchainv1alpha1.ConditionReady)
+	if !found || ready.LastUpdatedTime == nil {
+		return time.Time{}
+	}
+	return ready.LastUpdatedTime.Time
+}
+
+// Order compares two clusters. It returns a negative number if the first cluster goes before the second one,
+// a positive number if it goes after, and zero if the order of the clusters doesn't matter.
+type Order func(a, b *CachedToolchainCluster) int
+
+// ByName orders the clusters by their names
+var ByName Order = func(a, b *CachedToolchainCluster) int {
+	return strings.Compare(a.Name, b.Name)
+}
+
+// LeastRecentlyProbed orders the clusters by the time of their last health check, starting with the cluster checked
+// the longest time ago. The clusters which weren't checked yet go first.
+var LeastRecentlyProbed Order = func(a, b *CachedToolchainCluster) int {
+	return lastProbeTime(a).Compare(lastProbeTime(b))
+}
+
+// Sort sorts the given clusters by the given orders: the clusters which are equal in the first order are sorted by the second one, and so on.
+// The clusters which are equal in all the orders are sorted by their names, so that the result is always the same.
+func Sort(clusters []*CachedToolchainCluster, orders ...Order) []*CachedToolchainCluster {
+	orders = append(orders, ByName)
+	slices.SortFunc(clusters, func(a, b *CachedToolchainCluster) int {
+		for _, order := range orders {
+			if result := order(a, b); result != 0 {
+				return result
+			}
+		}
+		return 0
+	})
+	return clusters
+}
+
+func (c *ClusterCache) getCachedToolchainClusters(conditions ...Condition) []*CachedToolchainCluster {
 	c.RLock()
 	defer c.RUnlock()
 	return Filter(c.clusters, conditions...)
@@ -113,15 +328,9 @@ var HostCluster GetHostClusterFunc = GetHostCluster
 // GetHostCluster returns the kube client for the host cluster from the cache of the clusters
 // and info if such a client exists
 func GetHostCluster() (*CachedToolchainCluster, bool) {
-	clusters := clusterCache.getCachedToolchainClusters()
+	clusters := clusterCache.List()
 	if len(clusters) == 0 {
-		if clusterCache.refreshCache != nil {
-			clusterCache.refreshCache()
-		}
-		clusters = clusterCache.getCachedToolchainClusters()
-		if len(clusters) == 0 {
-			return nil, false
-		}
+		return nil, false
 	}
 	return clusters[0], true
 }
@@ -134,14 +343,13 @@ var MemberClusters GetMemberClustersFunc = GetMemberClusters
 
 // GetMemberClusters returns the kube clients for the host clusters from the cache of the clusters
 func GetMemberClusters(conditions ...Condition) []*CachedToolchainCluster {
-	clusters := clusterCache.getCachedToolchainClusters(conditions...)
-	if len(clusters) == 0 {
-		if clusterCache.refreshCache != nil {
-			clusterCache.refreshCache()
-		}
-		clusters = clusterCache.getCachedToolchainClusters(conditions...)
-	}
-	return clusters
+	return clusterCache.List(conditions...)
+}
+
+// GetMemberClustersOrderedBy returns the kube clients for the member clusters matching the given conditions
+// from the cache of the clusters, sorted by the given order (see Sort)
+func GetMemberClustersOrderedBy(order Order, conditions ...Condition) []*CachedToolchainCluster {
+	return Sort(clusterCache.List(conditions...), order)
 }
 
 // Role defines the role of the cluster.
@@ -2,13 +2,17 @@ package cluster
 
 import (
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 func getOrFetchCachedToolchainCluster() func(name string) (*CachedToolchainCluster, bool) {
@@ -254,6 +258,54 @@ func TestUpdateCluster(t *testing.T) {
 	assert.Equal(t, falseCluster, clusterCache.clusters["testCluster"])
 }
 
+func TestReplaceCluster(t *testing.T) {
+	t.Run("replaced when the cluster is still cached", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		trueCluster := newTestCachedToolchainCluster(t, "testCluster", ready)
+		falseCluster := newTestCachedToolchainCluster(t, "testCluster", notReady)
+		clusterCache.addCachedToolchainCluster(trueCluster)
+
+		// when
+		replaced := clusterCache.replaceCachedToolchainCluster(trueCluster, falseCluster)
+
+		// then
+		assert.True(t, replaced)
+		assert.Same(t, falseCluster, clusterCache.clusters["testCluster"])
+	})
+
+	t.Run("not replaced when the cluster was updated in the meantime", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		trueCluster := newTestCachedToolchainCluster(t, "testCluster", ready)
+		newerCluster := newTestCachedToolchainCluster(t, "testCluster", ready)
+		clusterCache.addCachedToolchainCluster(trueCluster)
+		clusterCache.addCachedToolchainCluster(newerCluster)
+
+		// when
+		replaced := clusterCache.replaceCachedToolchainCluster(trueCluster, newTestCachedToolchainCluster(t, "testCluster", notReady))
+
+		// then
+		assert.False(t, replaced)
+		assert.Same(t, newerCluster, clusterCache.clusters["testCluster"])
+	})
+
+	t.Run("not replaced when the cluster was removed in the meantime", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		trueCluster := newTestCachedToolchainCluster(t, "testCluster", ready)
+		clusterCache.addCachedToolchainCluster(trueCluster)
+		clusterCache.deleteCachedToolchainCluster("testCluster")
+
+		// when
+		replaced := clusterCache.replaceCachedToolchainCluster(trueCluster, newTestCachedToolchainCluster(t, "testCluster", notReady))
+
+		// then
+		assert.False(t, replaced)
+		assert.Empty(t, clusterCache.clusters)
+	})
+}
+
 func TestDeleteCluster(t *testing.T) {
 	// given
 	defer resetClusterCache()
@@ -429,5 +481,203 @@ func newTestCachedToolchainCluster(t *testing.T, name string, options ...cluster
 }
 
 func resetClusterCache() {
-	clusterCache = toolchainClusterClients{clusters: map[string]*CachedToolchainCluster{}}
+	clusterCache.Lock()
+	defer clusterCache.Unlock()
+	clusterCache.clusters = map[string]*CachedToolchainCluster{}
+	clusterCache.loadCluster = nil
+	clusterCache.refreshCache = nil
+	clusterCache.removedCallbacks = nil
+}
+
+func TestClusterCacheInstance(t *testing.T) {
+	// given
+	defer resetClusterCache()
+	cache := NewClusterCache()
+	member1 := newTestCachedToolchainCluster(t, "member-1", ready)
+	member2 := newTestCachedToolchainCluster(t, "member-2", notReady)
+
+	// when
+	cache.Add(member1)
+	cache.Add(member2)
+
+	// then
+	returnedCluster, ok := cache.Get("member-1")
+	assert.True(t, ok)
+	assert.Equal(t, member1, returnedCluster)
+	assert.ElementsMatch(t, []*CachedToolchainCluster{member1, member2}, cache.List())
+	assert.ElementsMatch(t, []*CachedToolchainCluster{member1}, cache.List(Ready))
+	// the default cache is not affected
+	assert.Empty(t, GetMemberClusters())
+
+	t.Run("delete", func(t *testing.T) {
+		// when
+		cache.Delete("member-1")
+
+		// then
+		_, ok := cache.Get("member-1")
+		assert.False(t, ok)
+		assert.ElementsMatch(t, []*CachedToolchainCluster{member2}, cache.List())
+	})
+}
+
+func TestClusterCacheLoadsMissingClusterOnce(t *testing.T) {
+	// given
+	cache := NewClusterCache()
+	member := newTestCachedToolchainCluster(t, "member", ready)
+	var loads int32
+	release := make(chan struct{})
+	refreshed := false
+	cache.setLoaders(func(name string) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		if name == "member" {
+			cache.Add(member)
+		}
+	}, func() {
+		refreshed = true
+	})
+
+	// when
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			returnedCluster, ok := cache.Get("member")
+			assert.True(t, ok)
+			assert.Equal(t, member, returnedCluster)
+		}()
+	}
+	// the loader blocks until it's released, so the other lookups either wait for its result or find the loaded cluster
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&loads) == 1
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// then
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	assert.False(t, refreshed)
+
+	t.Run("cluster that is present is not loaded", func(t *testing.T) {
+		// when
+		_, ok := cache.Get("member")
+
+		// then
+		assert.True(t, ok)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	})
+
+	t.Run("missing cluster is loaded every time", func(t *testing.T) {
+		// when
+		_, ok := cache.Get("unknown")
+		_, ok2 := cache.Get("unknown")
+
+		// then
+		assert.False(t, ok)
+		assert.False(t, ok2)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&loads))
+	})
+}
+
+func TestConditions(t *testing.T) {
+	// given
+	now := time.Now()
+	tenant := newTestCachedToolchainCluster(t, "tenant", readyProbedAt(now.Add(-time.Minute)), withLabels(map[string]string{
+		RoleLabel(Tenant):      "",
+		RoleLabel("workloads"): "",
+		"topology.region":      "eu",
+	}))
+	tenant.OwnerClusterName = "host"
+	stale := newTestCachedToolchainCluster(t, "stale", readyProbedAt(now.Add(-time.Hour)), withLabels(map[string]string{
+		"topology.region": "us",
+	}))
+	stale.OwnerClusterName = "other-host"
+	notProbed := newTestCachedToolchainCluster(t, "not-probed", ready)
+	offline := newTestCachedToolchainCluster(t, "offline", notReady, withLabels(map[string]string{
+		RoleLabel(Tenant): "",
+	}))
+	clusters := map[string]*CachedToolchainCluster{
+		"tenant":     tenant,
+		"stale":      stale,
+		"not-probed": notProbed,
+		"offline":    offline,
+	}
+
+	t.Run("with role", func(t *testing.T) {
+		assert.ElementsMatch(t, []*CachedToolchainCluster{tenant, offline}, Filter(clusters, WithRole(Tenant)))
+		assert.ElementsMatch(t, []*CachedToolchainCluster{tenant}, Filter(clusters, WithRole(Tenant), Ready))
+		assert.Empty(t, Filter(clusters, WithRole("unknown")))
+	})
+
+	t.Run("matching selector", func(t *testing.T) {
+		// given
+		selector, err := labels.Parse("topology.region in (eu,us)")
+		require.NoError(t, err)
+
+		// then
+		assert.ElementsMatch(t, []*CachedToolchainCluster{tenant, stale}, Filter(clusters, MatchingSelector(selector)))
+		assert.ElementsMatch(t, []*CachedToolchainCluster{tenant, stale, notProbed, offline}, Filter(clusters, MatchingSelector(labels.Everything())))
+		assert.Empty(t, Filter(clusters, MatchingSelector(labels.Nothing())))
+	})
+
+	t.Run("owned by", func(t *testing.T) {
+		assert.ElementsMatch(t, []*CachedToolchainCluster{tenant}, Filter(clusters, OwnedBy("host")))
+		assert.ElementsMatch(t, []*CachedToolchainCluster{stale}, Filter(clusters, OwnedBy("other-host")))
+	})
+
+	t.Run("ready within", func(t *testing.T) {
+		assert.ElementsMatch(t, []*CachedToolchainCluster{tenant}, Filter(clusters, ReadyWithin(5*time.Minute)))
+		assert.ElementsMatch(t, []*CachedToolchainCluster{tenant, stale}, Filter(clusters, ReadyWithin(2*time.Hour)))
+	})
+}
+
+func TestSort(t *testing.T) {
+	// given
+	now := time.Now()
+	member1 := newTestCachedToolchainCluster(t, "member-1", readyProbedAt(now.Add(-time.Minute)))
+	member2 := newTestCachedToolchainCluster(t, "member-2", readyProbedAt(now.Add(-time.Hour)))
+	member3 := newTestCachedToolchainCluster(t, "member-3", ready)
+	member4 := newTestCachedToolchainCluster(t, "member-4", readyProbedAt(now.Add(-time.Hour)))
+
+	t.Run("by name", func(t *testing.T) {
+		assert.Equal(t, []*CachedToolchainCluster{member1, member2, member3, member4}, Sort([]*CachedToolchainCluster{member4, member2, member3, member1}, ByName))
+	})
+
+	t.Run("least recently probed", func(t *testing.T) {
+		assert.Equal(t, []*CachedToolchainCluster{member3, member2, member4, member1}, Sort([]*CachedToolchainCluster{member4, member1, member2, member3}, LeastRecentlyProbed))
+	})
+
+	t.Run("default is by name", func(t *testing.T) {
+		assert.Equal(t, []*CachedToolchainCluster{member1, member2, member3, member4}, Sort([]*CachedToolchainCluster{member3, member4, member1, member2}))
+	})
+
+	t.Run("get member clusters ordered by", func(t *testing.T) {
+		// given
+		defer resetClusterCache()
+		for _, member := range []*CachedToolchainCluster{member1, member2, member3, member4} {
+			clusterCache.addCachedToolchainCluster(member)
+		}
+
+		// then
+		assert.Equal(t, []*CachedToolchainCluster{member2, member4, member1}, GetMemberClustersOrderedBy(LeastRecentlyProbed, ReadyWithin(2*time.Hour)))
+	})
+}
+
+// readyProbedAt an option to state the cluster as "ready" since the health check done at the given time
+func readyProbedAt(probeTime time.Time) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		c.ClusterStatus.Conditions = append(c.ClusterStatus.Conditions, toolchainv1alpha1.Condition{
+			Type:            toolchainv1alpha1.ConditionReady,
+			Status:          v1.ConditionTrue,
+			LastUpdatedTime: &metav1.Time{Time: probeTime},
+		})
+	}
+}
+
+// withLabels an option to set the labels of the cluster
+func withLabels(clusterLabels map[string]string) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		c.Labels = clusterLabels
+	}
 }
@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"k8s.io/client-go/rest"
+)
+
+// Annotations of the ToolchainCluster which tune the client of the cluster. They take precedence over the ClientOptions
+// configured in the ToolchainClusterService (see WithClientOptions and WithClusterClientOptions).
+const (
+	// QPSAnnotationKey the maximum number of queries per second to the cluster, eg. `"50"`
+	QPSAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-qps"
+	// BurstAnnotationKey the maximum burst of queries to the cluster, eg. `"100"`
+	BurstAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-burst"
+	// UserAgentAnnotationKey the user agent of the requests to the cluster
+	UserAgentAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-user-agent"
+	// TLSServerNameAnnotationKey the server name used to verify the certificate of the cluster (when it differs from the host of the API URL)
+	TLSServerNameAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-tls-server-name"
+	// ProxyURLAnnotationKey the URL of the proxy used to connect to the cluster
+	ProxyURLAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-proxy-url"
+	// ImpersonateUserAnnotationKey the user to impersonate in the requests to the cluster
+	ImpersonateUserAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-impersonate-user"
+	// ImpersonateGroupsAnnotationKey the comma-separated groups to impersonate in the requests to the cluster
+	ImpersonateGroupsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-impersonate-groups"
+)
+
+// ClientOptions tune the client of a cluster. The zero values keep the defaults of the rest config.
+type ClientOptions struct {
+	QPS               float32
+	Burst             int
+	UserAgent         string
+	TLSServerName     string
+	ProxyURL          string
+	ImpersonateUser   string
+	ImpersonateGroups []string
+}
+
+// ToolchainClusterServiceOption configures the ToolchainClusterService
+type ToolchainClusterServiceOption func(*ToolchainClusterService)
+
+// WithClientOptions sets the ClientOptions used for all the clusters
+func WithClientOptions(options ClientOptions) ToolchainClusterServiceOption {
+	return func(s *ToolchainClusterService) {
+		s.clientOptions = options
+	}
+}
+
+// WithClusterClientOptions sets the ClientOptions used for the cluster with the given name.
+// They take precedence over the ClientOptions set by WithClientOptions.
+func WithClusterClientOptions(name string, options ClientOptions) ToolchainClusterServiceOption {
+	return func(s *ToolchainClusterService) {
+		if s.clusterClientOptions == nil {
+			s.clusterClientOptions = map[string]ClientOptions{}
+		}
+		s.clusterClientOptions[name] = options
+	}
+}
+
+// clientOptionsFromAnnotations reads the ClientOptions from the annotations of the given ToolchainCluster
+func clientOptionsFromAnnotations(toolchainCluster *toolchainv1alpha1.ToolchainCluster) (ClientOptions, error) {
+	annotations := toolchainCluster.Annotations
+	options := ClientOptions{
+		UserAgent:       annotations[UserAgentAnnotationKey],
+		TLSServerName:   annotations[TLSServerNameAnnotationKey],
+		ProxyURL:        annotations[ProxyURLAnnotationKey],
+		ImpersonateUser: annotations[ImpersonateUserAnnotationKey],
+	}
+	invalid := func(key string, err error) error {
+		return fmt.Errorf("invalid value '%s' of the annotation %s of the ToolchainCluster %s: %w", annotations[key], key, toolchainCluster.Name, err)
+	}
+	if value, found := annotations[QPSAnnotationKey]; found {
+		qps, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return ClientOptions{}, invalid(QPSAnnotationKey, err)
+		}
+		options.QPS = float32(qps)
+	}
+	if value, found := annotations[BurstAnnotationKey]; found {
+		burst, err := strconv.Atoi(value)
+		if err != nil {
+			return ClientOptions{}, invalid(BurstAnnotationKey, err)
+		}
+		options.Burst = burst
+	}
+	if options.ProxyURL != "" {
+		if _, err := url.Parse(options.ProxyURL); err != nil {
+			return ClientOptions{}, invalid(ProxyURLAnnotationKey, err)
+		}
+	}
+	for _, group := range strings.Split(annotations[ImpersonateGroupsAnnotationKey], ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			options.ImpersonateGroups = append(options.ImpersonateGroups, group)
+		}
+	}
+	return options, nil
+}
+
+// withDefaults returns the options with the zero values replaced by the values of the given defaults
+func (o ClientOptions) withDefaults(defaults ClientOptions) ClientOptions {
+	if o.QPS == 0 {
+		o.QPS = defaults.QPS
+	}
+	if o.Burst == 0 {
+		o.Burst = defaults.Burst
+	}
+	if o.UserAgent == "" {
+		o.UserAgent = defaults.UserAgent
+	}
+	if o.TLSServerName == "" {
+		o.TLSServerName = defaults.TLSServerName
+	}
+	if o.ProxyURL == "" {
+		o.ProxyURL = defaults.ProxyURL
+	}
+	if o.ImpersonateUser == "" {
+		o.ImpersonateUser = defaults.ImpersonateUser
+	}
+	if len(o.ImpersonateGroups) == 0 {
+		o.ImpersonateGroups = defaults.ImpersonateGroups
+	}
+	return o
+}
+
+// applyTo sets the non-zero options in the given rest config
+func (o ClientOptions) applyTo(restCfg *rest.Config) error {
+	if o.QPS != 0 {
+		restCfg.QPS = o.QPS
+	}
+	if o.Burst != 0 {
+		restCfg.Burst = o.Burst
+	}
+	if o.UserAgent != "" {
+		restCfg.UserAgent = o.UserAgent
+	}
+	if o.TLSServerName != "" {
+		restCfg.ServerName = o.TLSServerName
+	}
+	if o.ProxyURL != "" {
+		proxyURL, err := url.Parse(o.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL '%s': %w", o.ProxyURL, err)
+		}
+		restCfg.Proxy = http.ProxyURL(proxyURL)
+	}
+	if o.ImpersonateUser != "" {
+		restCfg.Impersonate.UserName = o.ImpersonateUser
+	}
+	if len(o.ImpersonateGroups) > 0 {
+		restCfg.Impersonate.Groups = o.ImpersonateGroups
+	}
+	return nil
+}
+
+// sameRestConfig checks if the client created for the other config can be reused for the given one.
+// The proxy functions can't be compared, so the proxy URLs in the ClientOptions are compared instead.
+func sameRestConfig(config, other *Config) bool {
+	if config.RestConfig == nil || other.RestConfig == nil {
+		return config.RestConfig == other.RestConfig
+	}
+	restCfg, otherRestCfg := *config.RestConfig, *other.RestConfig
+	restCfg.Proxy, otherRestCfg.Proxy = nil, nil
+	return reflect.DeepEqual(restCfg, otherRestCfg) &&
+		reflect.DeepEqual(config.ClientOptions, other.ClientOptions) &&
+		(config.RestConfig.Proxy == nil) == (other.RestConfig.Proxy == nil)
+}
@@ -0,0 +1,165 @@
+package cluster
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestClientOptionsFromAnnotations(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+
+	t.Run("all the options are set", func(t *testing.T) {
+		// given
+		toolchainCluster, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+		toolchainCluster.Annotations = map[string]string{
+			QPSAnnotationKey:               "50.5",
+			BurstAnnotationKey:             "100",
+			UserAgentAnnotationKey:         "host-operator/member",
+			TLSServerNameAnnotationKey:     "api.member.com",
+			ProxyURLAnnotationKey:          "http://proxy.com:3128",
+			ImpersonateUserAnnotationKey:   "system:serviceaccount:toolchain-member-operator:member-operator",
+			ImpersonateGroupsAnnotationKey: "group-1, group-2,",
+		}
+		cl := test.NewFakeClient(t, sec)
+
+		// when
+		config, err := NewClusterConfig(cl, toolchainCluster, 0)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ClientOptions{
+			QPS:               50.5,
+			Burst:             100,
+			UserAgent:         "host-operator/member",
+			TLSServerName:     "api.member.com",
+			ProxyURL:          "http://proxy.com:3128",
+			ImpersonateUser:   "system:serviceaccount:toolchain-member-operator:member-operator",
+			ImpersonateGroups: []string{"group-1", "group-2"},
+		}, config.ClientOptions)
+		assert.InDelta(t, 50.5, config.RestConfig.QPS, 0.01)
+		assert.Equal(t, 100, config.RestConfig.Burst)
+		assert.Equal(t, "host-operator/member", config.RestConfig.UserAgent)
+		assert.Equal(t, "api.member.com", config.RestConfig.ServerName)
+		assert.Equal(t, rest.ImpersonationConfig{
+			UserName: "system:serviceaccount:toolchain-member-operator:member-operator",
+			Groups:   []string{"group-1", "group-2"},
+		}, config.RestConfig.Impersonate)
+		require.NotNil(t, config.RestConfig.Proxy)
+		proxyURL, err := config.RestConfig.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "cluster.com"}})
+		require.NoError(t, err)
+		assert.Equal(t, "http://proxy.com:3128", proxyURL.String())
+	})
+
+	t.Run("no option is set", func(t *testing.T) {
+		// given
+		toolchainCluster, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+		cl := test.NewFakeClient(t, sec)
+
+		// when
+		config, err := NewClusterConfig(cl, toolchainCluster, 0)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ClientOptions{}, config.ClientOptions)
+		assert.Zero(t, config.RestConfig.QPS)
+		assert.Zero(t, config.RestConfig.Burst)
+		assert.Nil(t, config.RestConfig.Proxy)
+		assert.Equal(t, rest.ImpersonationConfig{}, config.RestConfig.Impersonate)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		for key, value := range map[string]string{
+			QPSAnnotationKey:      "fast",
+			BurstAnnotationKey:    "1.5",
+			ProxyURLAnnotationKey: "http://proxy .com",
+		} {
+			t.Run(key, func(t *testing.T) {
+				// given
+				toolchainCluster, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+				toolchainCluster.Annotations = map[string]string{key: value}
+				cl := test.NewFakeClient(t, sec)
+
+				// when
+				_, err := NewClusterConfig(cl, toolchainCluster, 0)
+
+				// then
+				require.ErrorContains(t, err, "invalid value '"+value+"' of the annotation "+key+" of the ToolchainCluster member")
+			})
+		}
+	})
+}
+
+func TestServiceClientOptions(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	toolchainCluster.Annotations = map[string]string{
+		UserAgentAnnotationKey: "annotated",
+	}
+	cl := test.NewFakeClient(t, toolchainCluster, sec)
+	var createdClients int
+	newClient := func(_ *rest.Config, _ client.Options) (client.Client, error) {
+		createdClients++
+		return test.NewFakeClient(t), nil
+	}
+	cache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCache(cl, logf.Log, test.HostOperatorNs, 0, newClient, cache,
+		WithClientOptions(ClientOptions{QPS: 20, Burst: 30, UserAgent: "default", ProxyURL: "http://proxy.com"}),
+		WithClusterClientOptions("member", ClientOptions{Burst: 40, UserAgent: "member"}),
+		WithClusterClientOptions("other", ClientOptions{QPS: 100}))
+
+	t.Run("annotations take precedence over the cluster options which take precedence over the default options", func(t *testing.T) {
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		cachedCluster, ok := cache.getCachedToolchainCluster("member", false)
+		require.True(t, ok)
+		assert.Equal(t, ClientOptions{QPS: 20, Burst: 40, UserAgent: "annotated", ProxyURL: "http://proxy.com"}, cachedCluster.ClientOptions)
+		assert.InDelta(t, 20, cachedCluster.RestConfig.QPS, 0.01)
+		assert.Equal(t, 40, cachedCluster.RestConfig.Burst)
+		assert.Equal(t, "annotated", cachedCluster.RestConfig.UserAgent)
+		assert.NotNil(t, cachedCluster.RestConfig.Proxy)
+		assert.Equal(t, 1, createdClients)
+	})
+
+	t.Run("client is reused when nothing changed", func(t *testing.T) {
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, createdClients)
+	})
+
+	t.Run("client is recreated when an option changed", func(t *testing.T) {
+		// given
+		updated := toolchainCluster.DeepCopy()
+		updated.Annotations[ProxyURLAnnotationKey] = "http://other-proxy.com"
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(updated)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 2, createdClients)
+		cachedCluster, ok := cache.getCachedToolchainCluster("member", false)
+		require.True(t, ok)
+		assert.Equal(t, "http://other-proxy.com", cachedCluster.ClientOptions.ProxyURL)
+	})
+}
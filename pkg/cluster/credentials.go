@@ -0,0 +1,212 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// CredentialSourceAnnotationKey is the annotation of the ToolchainCluster secret that defines how the credentials
+// stored in the secret should be interpreted. When the annotation is not set, the credential source is determined by the type of the secret:
+// - `kubernetes.io/service-account-token` secrets use the CredentialSourceToken
+// - `kubernetes.io/tls` secrets use the CredentialSourceClientCertificate
+// - all the other secrets use the CredentialSourceKubeConfig
+const CredentialSourceAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "credential-source"
+
+// CredentialSource identifies a CredentialProvider
+type CredentialSource string
+
+const (
+	// CredentialSourceKubeConfig reads the whole config from the kubeconfig stored in the `kubeconfig` key.
+	// The operator namespace is the namespace of the current context.
+	CredentialSourceKubeConfig CredentialSource = "kubeconfig"
+	// CredentialSourceToken uses the bearer token stored in the `token` key
+	CredentialSourceToken CredentialSource = "token"
+	// CredentialSourceClientCertificate uses the client certificate and key stored in the `tls.crt` and `tls.key` keys
+	CredentialSourceClientCertificate CredentialSource = "client-certificate"
+	// CredentialSourceExec gets the token from the exec plugin whose command is stored in the `exec-command` key.
+	// The arguments of the command can be stored in the `exec-args` key (one per line) and the API version of the plugin
+	// in the `exec-api-version` key (default: `client.authentication.k8s.io/v1`).
+	CredentialSourceExec CredentialSource = "exec"
+	// CredentialSourceFile reads the bearer token from the file whose path is stored in the `token-file` key,
+	// typically a ServiceAccount token projected into the pod. The file is read again when the token is rotated.
+	CredentialSourceFile CredentialSource = "file"
+)
+
+// Keys of the ToolchainCluster secret used by the credential sources other than CredentialSourceKubeConfig.
+// The API URL and the operator namespace are required by all of them, the CA certificate is optional
+// (the system roots are used when it's not set).
+const (
+	SecretKeyKubeConfig     = "kubeconfig"
+	SecretKeyAPIURL         = "api-url"
+	SecretKeyNamespace      = v1.ServiceAccountNamespaceKey
+	SecretKeyCA             = v1.ServiceAccountRootCAKey
+	SecretKeyToken          = v1.ServiceAccountTokenKey
+	SecretKeyTokenFile      = "token-file"
+	SecretKeyExecCommand    = "exec-command"
+	SecretKeyExecArgs       = "exec-args"
+	SecretKeyExecAPIVersion = "exec-api-version"
+)
+
+const defaultExecAPIVersion = "client.authentication.k8s.io/v1"
+
+// CredentialProvider builds the rest config and returns the operator namespace from the content of the ToolchainCluster secret
+type CredentialProvider func(secret *v1.Secret) (*rest.Config, string, error)
+
+var credentialProviders = struct {
+	sync.RWMutex
+	providers map[CredentialSource]CredentialProvider
+}{
+	providers: map[CredentialSource]CredentialProvider{
+		CredentialSourceKubeConfig:        kubeConfigCredentials,
+		CredentialSourceToken:             tokenCredentials,
+		CredentialSourceClientCertificate: clientCertificateCredentials,
+		CredentialSourceExec:              execCredentials,
+		CredentialSourceFile:              fileCredentials,
+	},
+}
+
+// RegisterCredentialProvider registers the CredentialProvider for the given source, so that it can be selected
+// with the CredentialSourceAnnotationKey annotation. Any provider previously registered for the same source is replaced.
+func RegisterCredentialProvider(source CredentialSource, provider CredentialProvider) {
+	credentialProviders.Lock()
+	defer credentialProviders.Unlock()
+	credentialProviders.providers[source] = provider
+}
+
+// CredentialSourceOf returns the credential source of the given ToolchainCluster secret,
+// based on its CredentialSourceAnnotationKey annotation (if set) or on its type
+func CredentialSourceOf(secret *v1.Secret) CredentialSource {
+	if source, found := secret.Annotations[CredentialSourceAnnotationKey]; found {
+		return CredentialSource(source)
+	}
+	switch secret.Type {
+	case v1.SecretTypeServiceAccountToken:
+		return CredentialSourceToken
+	case v1.SecretTypeTLS:
+		return CredentialSourceClientCertificate
+	default:
+		return CredentialSourceKubeConfig
+	}
+}
+
+// credentialsFromSecret builds the rest config and returns the operator namespace using the CredentialProvider selected for the given secret
+func credentialsFromSecret(secret *v1.Secret) (*rest.Config, string, error) {
+	source := CredentialSourceOf(secret)
+	credentialProviders.RLock()
+	provider, found := credentialProviders.providers[source]
+	credentialProviders.RUnlock()
+	if !found {
+		return nil, "", fmt.Errorf("unknown credential source '%s' of secret %s/%s", source, secret.Namespace, secret.Name)
+	}
+	return provider(secret)
+}
+
+func kubeConfigCredentials(secret *v1.Secret) (*rest.Config, string, error) {
+	data, err := requiredSecretValue(secret, CredentialSourceKubeConfig, SecretKeyKubeConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	return restConfigFromKubeConfig([]byte(data))
+}
+
+func tokenCredentials(secret *v1.Secret) (*rest.Config, string, error) {
+	token, err := requiredSecretValue(secret, CredentialSourceToken, SecretKeyToken)
+	if err != nil {
+		return nil, "", err
+	}
+	return newRestConfig(secret, CredentialSourceToken, func(cfg *rest.Config) error {
+		cfg.BearerToken = token
+		return nil
+	})
+}
+
+func clientCertificateCredentials(secret *v1.Secret) (*rest.Config, string, error) {
+	return newRestConfig(secret, CredentialSourceClientCertificate, func(cfg *rest.Config) error {
+		cert, err := requiredSecretValue(secret, CredentialSourceClientCertificate, v1.TLSCertKey)
+		if err != nil {
+			return err
+		}
+		key, err := requiredSecretValue(secret, CredentialSourceClientCertificate, v1.TLSPrivateKeyKey)
+		if err != nil {
+			return err
+		}
+		cfg.CertData = []byte(cert)
+		cfg.KeyData = []byte(key)
+		return nil
+	})
+}
+
+func execCredentials(secret *v1.Secret) (*rest.Config, string, error) {
+	return newRestConfig(secret, CredentialSourceExec, func(cfg *rest.Config) error {
+		command, err := requiredSecretValue(secret, CredentialSourceExec, SecretKeyExecCommand)
+		if err != nil {
+			return err
+		}
+		var args []string
+		for _, arg := range strings.Split(string(secret.Data[SecretKeyExecArgs]), "\n") {
+			if arg = strings.TrimSpace(arg); arg != "" {
+				args = append(args, arg)
+			}
+		}
+		apiVersion := string(secret.Data[SecretKeyExecAPIVersion])
+		if apiVersion == "" {
+			apiVersion = defaultExecAPIVersion
+		}
+		cfg.ExecProvider = &clientcmdapi.ExecConfig{
+			Command:         command,
+			Args:            args,
+			APIVersion:      apiVersion,
+			InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+		}
+		return nil
+	})
+}
+
+func fileCredentials(secret *v1.Secret) (*rest.Config, string, error) {
+	return newRestConfig(secret, CredentialSourceFile, func(cfg *rest.Config) error {
+		tokenFile, err := requiredSecretValue(secret, CredentialSourceFile, SecretKeyTokenFile)
+		if err != nil {
+			return err
+		}
+		cfg.BearerTokenFile = tokenFile
+		return nil
+	})
+}
+
+// newRestConfig creates the rest config from the API URL and the CA certificate stored in the secret,
+// and sets the credentials using the given function. It returns the config and the operator namespace.
+func newRestConfig(secret *v1.Secret, source CredentialSource, setCredentials func(*rest.Config) error) (*rest.Config, string, error) {
+	apiURL, err := requiredSecretValue(secret, source, SecretKeyAPIURL)
+	if err != nil {
+		return nil, "", err
+	}
+	operatorNamespace, err := requiredSecretValue(secret, source, SecretKeyNamespace)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg := &rest.Config{
+		Host: apiURL,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: secret.Data[SecretKeyCA],
+		},
+	}
+	if err := setCredentials(cfg); err != nil {
+		return nil, "", err
+	}
+	return cfg, operatorNamespace, nil
+}
+
+func requiredSecretValue(secret *v1.Secret, source CredentialSource, key string) (string, error) {
+	value := strings.TrimSpace(string(secret.Data[key]))
+	if value == "" {
+		return "", withFailureReason(FailureReasonCredentialsMissing,
+			fmt.Errorf("the secret %s/%s is missing the '%s' key required by the '%s' credential source", secret.Namespace, secret.Name, key, source))
+	}
+	return value, nil
+}
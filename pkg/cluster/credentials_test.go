@@ -0,0 +1,306 @@
+package cluster_test
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestCredentialSources(t *testing.T) {
+	tc := &toolchainv1alpha1.ToolchainCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tc",
+			Namespace: "ns",
+		},
+		Spec: toolchainv1alpha1.ToolchainClusterSpec{
+			SecretRef: toolchainv1alpha1.LocalSecretReference{
+				Name: "secret",
+			},
+		},
+	}
+
+	newSecret := func(secretType corev1.SecretType, source cluster.CredentialSource, data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns",
+			},
+			Type: secretType,
+			Data: map[string][]byte{
+				cluster.SecretKeyAPIURL:    []byte("https://over.the.rainbow"),
+				cluster.SecretKeyNamespace: []byte("operatorns"),
+				cluster.SecretKeyCA:        []byte("ca-data"),
+			},
+		}
+		if source != "" {
+			secret.Annotations = map[string]string{
+				cluster.CredentialSourceAnnotationKey: string(source),
+			}
+		}
+		for key, value := range data {
+			if value == "" {
+				delete(secret.Data, key)
+				continue
+			}
+			secret.Data[key] = []byte(value)
+		}
+		return secret
+	}
+
+	newClusterConfig := func(t *testing.T, secret *corev1.Secret) (*cluster.Config, error) {
+		cl := test.NewFakeClient(t, tc, secret)
+		return cluster.NewClusterConfig(cl, tc, time.Second)
+	}
+
+	assertCommonConfig := func(t *testing.T, cfg *cluster.Config) {
+		assert.Equal(t, "https://over.the.rainbow", cfg.APIEndpoint)
+		assert.Equal(t, "operatorns", cfg.OperatorNamespace)
+		assert.Equal(t, []byte("ca-data"), cfg.RestConfig.CAData)
+		assert.Equal(t, time.Second, cfg.RestConfig.Timeout)
+	}
+
+	t.Run("token", func(t *testing.T) {
+		t.Run("selected by the secret type", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeServiceAccountToken, "", map[string]string{
+				cluster.SecretKeyToken: "token\n",
+			})
+
+			// when
+			cfg, err := newClusterConfig(t, secret)
+
+			// then
+			require.NoError(t, err)
+			assertCommonConfig(t, cfg)
+			assert.Equal(t, "token", cfg.RestConfig.BearerToken)
+		})
+
+		t.Run("selected by the annotation", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeOpaque, cluster.CredentialSourceToken, map[string]string{
+				cluster.SecretKeyToken: "token",
+			})
+
+			// when
+			cfg, err := newClusterConfig(t, secret)
+
+			// then
+			require.NoError(t, err)
+			assertCommonConfig(t, cfg)
+			assert.Equal(t, "token", cfg.RestConfig.BearerToken)
+		})
+
+		t.Run("the token is missing", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeServiceAccountToken, "", nil)
+
+			// when
+			_, err := newClusterConfig(t, secret)
+
+			// then
+			require.EqualError(t, err, "the secret ns/secret is missing the 'token' key required by the 'token' credential source")
+		})
+
+		t.Run("the API URL is missing", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeServiceAccountToken, "", map[string]string{
+				cluster.SecretKeyToken:  "token",
+				cluster.SecretKeyAPIURL: "",
+			})
+
+			// when
+			_, err := newClusterConfig(t, secret)
+
+			// then
+			require.EqualError(t, err, "the secret ns/secret is missing the 'api-url' key required by the 'token' credential source")
+		})
+
+		t.Run("the namespace is missing", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeServiceAccountToken, "", map[string]string{
+				cluster.SecretKeyToken:     "token",
+				cluster.SecretKeyNamespace: "",
+			})
+
+			// when
+			_, err := newClusterConfig(t, secret)
+
+			// then
+			require.EqualError(t, err, "the secret ns/secret is missing the 'namespace' key required by the 'token' credential source")
+		})
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		t.Run("selected by the secret type", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeTLS, "", map[string]string{
+				corev1.TLSCertKey:       "cert",
+				corev1.TLSPrivateKeyKey: "key",
+			})
+
+			// when
+			cfg, err := newClusterConfig(t, secret)
+
+			// then
+			require.NoError(t, err)
+			assertCommonConfig(t, cfg)
+			assert.Equal(t, []byte("cert"), cfg.RestConfig.CertData)
+			assert.Equal(t, []byte("key"), cfg.RestConfig.KeyData)
+			assert.Empty(t, cfg.RestConfig.BearerToken)
+		})
+
+		t.Run("the key is missing", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeOpaque, cluster.CredentialSourceClientCertificate, map[string]string{
+				corev1.TLSCertKey: "cert",
+			})
+
+			// when
+			_, err := newClusterConfig(t, secret)
+
+			// then
+			require.EqualError(t, err, "the secret ns/secret is missing the 'tls.key' key required by the 'client-certificate' credential source")
+		})
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		t.Run("with arguments", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeOpaque, cluster.CredentialSourceExec, map[string]string{
+				cluster.SecretKeyExecCommand: "get-token",
+				cluster.SecretKeyExecArgs:    "--cluster\nmember\n",
+			})
+
+			// when
+			cfg, err := newClusterConfig(t, secret)
+
+			// then
+			require.NoError(t, err)
+			assertCommonConfig(t, cfg)
+			assert.Equal(t, &clientcmdapi.ExecConfig{
+				Command:         "get-token",
+				Args:            []string{"--cluster", "member"},
+				APIVersion:      "client.authentication.k8s.io/v1",
+				InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+			}, cfg.RestConfig.ExecProvider)
+		})
+
+		t.Run("with API version", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeOpaque, cluster.CredentialSourceExec, map[string]string{
+				cluster.SecretKeyExecCommand:    "get-token",
+				cluster.SecretKeyExecAPIVersion: "client.authentication.k8s.io/v1beta1",
+			})
+
+			// when
+			cfg, err := newClusterConfig(t, secret)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "client.authentication.k8s.io/v1beta1", cfg.RestConfig.ExecProvider.APIVersion)
+			assert.Empty(t, cfg.RestConfig.ExecProvider.Args)
+		})
+
+		t.Run("the command is missing", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeOpaque, cluster.CredentialSourceExec, nil)
+
+			// when
+			_, err := newClusterConfig(t, secret)
+
+			// then
+			require.EqualError(t, err, "the secret ns/secret is missing the 'exec-command' key required by the 'exec' credential source")
+		})
+	})
+
+	t.Run("file", func(t *testing.T) {
+		t.Run("with token file", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeOpaque, cluster.CredentialSourceFile, map[string]string{
+				cluster.SecretKeyTokenFile: "/var/run/secrets/tokens/member",
+			})
+
+			// when
+			cfg, err := newClusterConfig(t, secret)
+
+			// then
+			require.NoError(t, err)
+			assertCommonConfig(t, cfg)
+			assert.Equal(t, "/var/run/secrets/tokens/member", cfg.RestConfig.BearerTokenFile)
+		})
+
+		t.Run("the token file is missing", func(t *testing.T) {
+			// given
+			secret := newSecret(corev1.SecretTypeOpaque, cluster.CredentialSourceFile, nil)
+
+			// when
+			_, err := newClusterConfig(t, secret)
+
+			// then
+			require.EqualError(t, err, "the secret ns/secret is missing the 'token-file' key required by the 'file' credential source")
+		})
+	})
+
+	t.Run("the annotation takes precedence over the secret type", func(t *testing.T) {
+		// given
+		secret := newSecret(corev1.SecretTypeServiceAccountToken, cluster.CredentialSourceKubeConfig, map[string]string{
+			cluster.SecretKeyToken: "token",
+		})
+
+		// when
+		_, err := newClusterConfig(t, secret)
+
+		// then
+		require.EqualError(t, err, "the secret ns/secret is missing the 'kubeconfig' key required by the 'kubeconfig' credential source")
+	})
+
+	t.Run("kubeconfig is missing", func(t *testing.T) {
+		// given
+		secret := newSecret(corev1.SecretTypeOpaque, "", nil)
+
+		// when
+		_, err := newClusterConfig(t, secret)
+
+		// then
+		require.EqualError(t, err, "the secret ns/secret is missing the 'kubeconfig' key required by the 'kubeconfig' credential source")
+	})
+
+	t.Run("unknown credential source", func(t *testing.T) {
+		// given
+		secret := newSecret(corev1.SecretTypeOpaque, "vault", nil)
+
+		// when
+		_, err := newClusterConfig(t, secret)
+
+		// then
+		require.EqualError(t, err, "unknown credential source 'vault' of secret ns/secret")
+	})
+
+	t.Run("custom credential provider", func(t *testing.T) {
+		// given
+		cluster.RegisterCredentialProvider("custom", func(secret *corev1.Secret) (*rest.Config, string, error) {
+			return &rest.Config{Host: "https://custom.cluster", BearerToken: string(secret.Data["custom-token"])}, "customns", nil
+		})
+		secret := newSecret(corev1.SecretTypeOpaque, "custom", map[string]string{
+			"custom-token": "token",
+		})
+
+		// when
+		cfg, err := newClusterConfig(t, secret)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "https://custom.cluster", cfg.APIEndpoint)
+		assert.Equal(t, "customns", cfg.OperatorNamespace)
+		assert.Equal(t, "token", cfg.RestConfig.BearerToken)
+	})
+}
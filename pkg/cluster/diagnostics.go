@@ -0,0 +1,142 @@
+package cluster
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of the failures to connect to the clusters (see FailureReason)
+const (
+	// FailureReasonCredentialsMissing the secret of the ToolchainCluster, or a key required by its credential source, is missing
+	FailureReasonCredentialsMissing = "CredentialsMissing"
+	// FailureReasonInvalidConfig the config stored in the secret of the ToolchainCluster is invalid (eg. a malformed kubeconfig, or no operator namespace)
+	FailureReasonInvalidConfig = "InvalidConfig"
+	// FailureReasonTLSError the TLS handshake failed (eg. the certificate of the cluster is not trusted or doesn't match its host)
+	FailureReasonTLSError = "TLSError"
+	// FailureReasonDNSError the host of the API URL could not be resolved
+	FailureReasonDNSError = "DNSError"
+	// FailureReasonUnauthorized the cluster rejected the credentials (401)
+	FailureReasonUnauthorized = "Unauthorized"
+	// FailureReasonForbidden the credentials don't have the permissions for the request (403)
+	FailureReasonForbidden = "Forbidden"
+	// FailureReasonTimeout the cluster didn't respond in time
+	FailureReasonTimeout = "Timeout"
+	// FailureReasonUnknown the failure could not be classified
+	FailureReasonUnknown = "Unknown"
+)
+
+// failure keeps the reason of the error, without changing its message
+type failure struct {
+	reason string
+	err    error
+}
+
+func (f *failure) Error() string {
+	return f.err.Error()
+}
+
+func (f *failure) Unwrap() error {
+	return f.err
+}
+
+func withFailureReason(reason string, err error) error {
+	return &failure{reason: reason, err: err}
+}
+
+// FailureReason classifies the error returned when loading the config of a cluster (see CheckClusterConfig)
+// or when connecting to the cluster. It returns one of the FailureReason* constants, or an empty string if there is no error.
+func FailureReason(err error) string {
+	var reasoned *failure
+	var dnsErr *net.DNSError
+	var certVerificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var recordHeaderErr tls.RecordHeaderError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &reasoned):
+		return reasoned.reason
+	case errors.As(err, &dnsErr):
+		return FailureReasonDNSError
+	case errors.As(err, &certVerificationErr), errors.As(err, &unknownAuthorityErr), errors.As(err, &certInvalidErr),
+		errors.As(err, &hostnameErr), errors.As(err, &recordHeaderErr):
+		return FailureReasonTLSError
+	case apierrors.IsUnauthorized(err):
+		return FailureReasonUnauthorized
+	case apierrors.IsForbidden(err):
+		return FailureReasonForbidden
+	case errors.Is(err, context.DeadlineExceeded), apierrors.IsTimeout(err), apierrors.IsServerTimeout(err),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return FailureReasonTimeout
+	default:
+		return FailureReasonUnknown
+	}
+}
+
+// CheckClusterConfig loads the config of the given ToolchainCluster and validates it the same way as the ToolchainClusterService does
+// when the cluster is added in the cache. The reason of the returned error can be obtained by FailureReason.
+func CheckClusterConfig(cl client.Client, toolchainCluster *toolchainv1alpha1.ToolchainCluster) (*Config, error) {
+	clusterConfig, err := NewClusterConfig(cl, toolchainCluster, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateConfig(clusterConfig); err != nil {
+		return nil, err
+	}
+	return clusterConfig, nil
+}
+
+func validateConfig(clusterConfig *Config) error {
+	if clusterConfig.OperatorNamespace == "" {
+		return withFailureReason(FailureReasonInvalidConfig, fmt.Errorf("the operator namespace is not set for the ToolchainCluster CR"))
+	}
+	return nil
+}
+
+// CertificatesNotAfter returns the expiry of the client certificate and of the CA certificates of the given rest config.
+// If there are several certificates in a bundle, then the earliest expiry is returned. The expiry is nil when there is no such certificate.
+func CertificatesNotAfter(restCfg *rest.Config) (clientCertificate, ca *time.Time, err error) {
+	restCfg = rest.CopyConfig(restCfg)
+	if err := rest.LoadTLSFiles(restCfg); err != nil {
+		return nil, nil, err
+	}
+	if clientCertificate, err = earliestNotAfter(restCfg.CertData); err != nil {
+		return nil, nil, fmt.Errorf("invalid client certificate: %w", err)
+	}
+	if ca, err = earliestNotAfter(restCfg.CAData); err != nil {
+		return nil, nil, fmt.Errorf("invalid CA certificate: %w", err)
+	}
+	return clientCertificate, ca, nil
+}
+
+// earliestNotAfter returns the earliest NotAfter of the PEM-encoded certificates, or nil if there is no certificate
+func earliestNotAfter(data []byte) (*time.Time, error) {
+	var notAfter *time.Time
+	for block, remaining := pem.Decode(data); block != nil; block, remaining = pem.Decode(remaining) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if notAfter == nil || cert.NotAfter.Before(*notAfter) {
+			notAfter = &cert.NotAfter
+		}
+	}
+	return notAfter, nil
+}
@@ -0,0 +1,271 @@
+package cluster_test
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestFailureReason(t *testing.T) {
+	urlError := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://cluster.com/readyz", Err: err}
+	}
+	for expected, err := range map[string]error{
+		cluster.FailureReasonDNSError:     urlError(&net.DNSError{Err: "no such host", Name: "cluster.com", IsNotFound: true}),
+		cluster.FailureReasonTLSError:     urlError(x509.UnknownAuthorityError{}),
+		cluster.FailureReasonUnauthorized: apierrors.NewUnauthorized("invalid token"),
+		cluster.FailureReasonForbidden:    apierrors.NewForbidden(schema.GroupResource{}, "readyz", fmt.Errorf("not allowed")),
+		cluster.FailureReasonTimeout:      urlError(timeoutError{}),
+		cluster.FailureReasonUnknown:      fmt.Errorf("connection refused"),
+	} {
+		t.Run(expected, func(t *testing.T) {
+			assert.Equal(t, expected, cluster.FailureReason(err))
+		})
+	}
+
+	t.Run("context deadline exceeded", func(t *testing.T) {
+		assert.Equal(t, cluster.FailureReasonTimeout, cluster.FailureReason(fmt.Errorf("request failed: %w", context.DeadlineExceeded)))
+	})
+
+	t.Run("no error", func(t *testing.T) {
+		assert.Empty(t, cluster.FailureReason(nil))
+	})
+}
+
+func TestCheckClusterConfig(t *testing.T) {
+	cluster.RegisterCredentialProvider("no-namespace", func(_ *corev1.Secret) (*rest.Config, string, error) {
+		return &rest.Config{Host: "https://cluster.com"}, "", nil
+	})
+	newToolchainCluster := func(secretName string) *toolchainv1alpha1.ToolchainCluster {
+		return &toolchainv1alpha1.ToolchainCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "tc",
+				Namespace: "ns",
+			},
+			Spec: toolchainv1alpha1.ToolchainClusterSpec{
+				SecretRef: toolchainv1alpha1.LocalSecretReference{
+					Name: secretName,
+				},
+			},
+		}
+	}
+	newSecret := func(data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns",
+			},
+			Type: corev1.SecretTypeServiceAccountToken,
+			Data: map[string][]byte{},
+		}
+		for key, value := range data {
+			secret.Data[key] = []byte(value)
+		}
+		return secret
+	}
+
+	t.Run("valid config", func(t *testing.T) {
+		// given
+		tc := newToolchainCluster("secret")
+		cl := test.NewFakeClient(t, tc, newSecret(map[string]string{
+			cluster.SecretKeyAPIURL:    "https://cluster.com",
+			cluster.SecretKeyNamespace: "operatorns",
+			cluster.SecretKeyToken:     "token",
+		}))
+
+		// when
+		cfg, err := cluster.CheckClusterConfig(cl, tc)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "https://cluster.com", cfg.APIEndpoint)
+		assert.Nil(t, cfg.ClientCertificateNotAfter)
+		assert.Nil(t, cfg.CANotAfter)
+	})
+
+	t.Run("valid config with CA certificate", func(t *testing.T) {
+		// given
+		notAfter := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+		ca, _ := test.NewCertificate(t, "ca", notAfter)
+		tc := newToolchainCluster("secret")
+		cl := test.NewFakeClient(t, tc, newSecret(map[string]string{
+			cluster.SecretKeyAPIURL:    "https://cluster.com",
+			cluster.SecretKeyNamespace: "operatorns",
+			cluster.SecretKeyToken:     "token",
+			cluster.SecretKeyCA:        string(ca),
+		}))
+
+		// when
+		cfg, err := cluster.CheckClusterConfig(cl, tc)
+
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, cfg.ClientCertificateNotAfter)
+		require.NotNil(t, cfg.CANotAfter)
+		assert.True(t, notAfter.Equal(*cfg.CANotAfter))
+		assert.NoError(t, cfg.CertificatesError)
+	})
+
+	t.Run("valid config with invalid CA certificate", func(t *testing.T) {
+		// given
+		tc := newToolchainCluster("secret")
+		cl := test.NewFakeClient(t, tc, newSecret(map[string]string{
+			cluster.SecretKeyAPIURL:    "https://cluster.com",
+			cluster.SecretKeyNamespace: "operatorns",
+			cluster.SecretKeyToken:     "token",
+			cluster.SecretKeyCA:        "-----BEGIN CERTIFICATE-----\naW52YWxpZA==\n-----END CERTIFICATE-----\n",
+		}))
+
+		// when
+		cfg, err := cluster.CheckClusterConfig(cl, tc)
+
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, cfg.CANotAfter)
+		require.ErrorContains(t, cfg.CertificatesError, "invalid CA certificate")
+	})
+
+	for name, data := range map[string]struct {
+		tc             *toolchainv1alpha1.ToolchainCluster
+		secret         *corev1.Secret
+		expectedReason string
+		expectedErr    string
+	}{
+		"no secret name": {
+			tc:             newToolchainCluster(""),
+			expectedReason: cluster.FailureReasonCredentialsMissing,
+			expectedErr:    "cluster tc does not have a secret name",
+		},
+		"secret is missing": {
+			tc:             newToolchainCluster("secret"),
+			expectedReason: cluster.FailureReasonCredentialsMissing,
+			expectedErr:    `unable to get secret ns/secret for cluster tc: secrets "secret" not found`,
+		},
+		"token is missing": {
+			tc: newToolchainCluster("secret"),
+			secret: newSecret(map[string]string{
+				cluster.SecretKeyAPIURL:    "https://cluster.com",
+				cluster.SecretKeyNamespace: "operatorns",
+			}),
+			expectedReason: cluster.FailureReasonCredentialsMissing,
+			expectedErr:    "the secret ns/secret is missing the 'token' key required by the 'token' credential source",
+		},
+		"malformed kubeconfig": {
+			tc: newToolchainCluster("secret"),
+			secret: func() *corev1.Secret {
+				secret := newSecret(map[string]string{cluster.SecretKeyKubeConfig: "not a kubeconfig"})
+				secret.Type = corev1.SecretTypeOpaque
+				return secret
+			}(),
+			expectedReason: cluster.FailureReasonInvalidConfig,
+		},
+		"namespace is missing": {
+			tc: newToolchainCluster("secret"),
+			secret: newSecret(map[string]string{
+				cluster.SecretKeyAPIURL:    "https://cluster.com",
+				cluster.SecretKeyNamespace: " ",
+				cluster.SecretKeyToken:     "token",
+			}),
+			expectedReason: cluster.FailureReasonCredentialsMissing,
+			expectedErr:    "the secret ns/secret is missing the 'namespace' key required by the 'token' credential source",
+		},
+		"operator namespace is not set": {
+			tc: newToolchainCluster("secret"),
+			secret: func() *corev1.Secret {
+				secret := newSecret(map[string]string{})
+				secret.Annotations = map[string]string{cluster.CredentialSourceAnnotationKey: "no-namespace"}
+				return secret
+			}(),
+			expectedReason: cluster.FailureReasonInvalidConfig,
+			expectedErr:    "the operator namespace is not set for the ToolchainCluster CR",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			cl := test.NewFakeClient(t, data.tc)
+			if data.secret != nil {
+				cl = test.NewFakeClient(t, data.tc, data.secret)
+			}
+
+			// when
+			_, err := cluster.CheckClusterConfig(cl, data.tc)
+
+			// then
+			require.Error(t, err)
+			if data.expectedErr != "" {
+				require.EqualError(t, err, data.expectedErr)
+			}
+			assert.Equal(t, data.expectedReason, cluster.FailureReason(err))
+		})
+	}
+}
+
+func TestCertificatesNotAfter(t *testing.T) {
+	// given
+	now := time.Now().Truncate(time.Second)
+	clientCert, clientKey := test.NewCertificate(t, "client", now.Add(24*time.Hour))
+	ca1, _ := test.NewCertificate(t, "ca-1", now.Add(48*time.Hour))
+	ca2, _ := test.NewCertificate(t, "ca-2", now.Add(12*time.Hour))
+
+	t.Run("client certificate and CA bundle", func(t *testing.T) {
+		// when
+		clientNotAfter, caNotAfter, err := cluster.CertificatesNotAfter(&rest.Config{
+			TLSClientConfig: rest.TLSClientConfig{
+				CertData: clientCert,
+				KeyData:  clientKey,
+				CAData:   append(append([]byte{}, ca1...), ca2...),
+			},
+		})
+
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, clientNotAfter)
+		require.NotNil(t, caNotAfter)
+		assert.True(t, now.Add(24*time.Hour).Equal(*clientNotAfter))
+		// the earliest expiry of the bundle
+		assert.True(t, now.Add(12*time.Hour).Equal(*caNotAfter))
+	})
+
+	t.Run("no certificate", func(t *testing.T) {
+		// when
+		clientNotAfter, caNotAfter, err := cluster.CertificatesNotAfter(&rest.Config{BearerToken: "token"})
+
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, clientNotAfter)
+		assert.Nil(t, caNotAfter)
+	})
+
+	t.Run("invalid certificate", func(t *testing.T) {
+		// when
+		_, _, err := cluster.CertificatesNotAfter(&rest.Config{
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData: []byte("-----BEGIN CERTIFICATE-----\naW52YWxpZA==\n-----END CERTIFICATE-----\n"),
+			},
+		})
+
+		// then
+		require.ErrorContains(t, err, "invalid CA certificate")
+	})
+}
@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"context"
+)
+
+// ClusterRemovedCallback is called when the cluster is removed from the cache, ie, when its ToolchainCluster is deleted
+type ClusterRemovedCallback func(cluster *CachedToolchainCluster)
+
+// OnClusterRemoved registers the callback which is called for every cluster removed from the cache.
+// The callback is called after the context of the removed cluster is cancelled, and it must not block.
+func (c *ClusterCache) OnClusterRemoved(callback ClusterRemovedCallback) {
+	c.Lock()
+	defer c.Unlock()
+	c.removedCallbacks = append(c.removedCallbacks, callback)
+}
+
+// OnClusterRemoved registers the callback which is called for every cluster removed from the default cache
+// (see ClusterCache.OnClusterRemoved)
+func OnClusterRemoved(callback ClusterRemovedCallback) {
+	clusterCache.OnClusterRemoved(callback)
+}
+
+// Context returns the context of the cluster, which is cancelled when the cluster is removed from the cache.
+// It lives as long as the cluster is in the cache, even when the cluster is updated (and its client is replaced),
+// so it can be used to stop the background work (such as watches or informers) scoped to the cluster.
+// The context of a cluster which was never added in the cache is never cancelled.
+func (c *CachedToolchainCluster) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// inheritContext sets the context of the previous cluster with the same name, or a new context if there is no previous cluster
+// (or if the cluster was already removed from the cache before). The lock must be held.
+func (c *CachedToolchainCluster) inheritContext(previous *CachedToolchainCluster) {
+	if c.ctx != nil && c.ctx.Err() == nil {
+		return
+	}
+	if previous != nil && previous.ctx != nil {
+		c.ctx, c.cancel = previous.ctx, previous.cancel
+		return
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+}
+
+// removed cancels the context of the removed cluster and calls the callbacks. The lock must not be held.
+func (c *ClusterCache) removed(cluster *CachedToolchainCluster, callbacks []ClusterRemovedCallback) {
+	if cluster.cancel != nil {
+		cluster.cancel()
+	}
+	for _, callback := range callbacks {
+		callback(cluster)
+	}
+}
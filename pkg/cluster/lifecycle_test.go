@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterContext(t *testing.T) {
+	// given
+	cache := NewClusterCache()
+	member := newTestCachedToolchainCluster(t, "member", ready)
+
+	t.Run("context of a cluster not in the cache is never cancelled", func(t *testing.T) {
+		assert.Equal(t, context.Background(), member.Context())
+	})
+
+	// when
+	cache.Add(member)
+	ctx := member.Context()
+
+	// then
+	require.NoError(t, ctx.Err())
+
+	t.Run("context is kept when the cluster is updated", func(t *testing.T) {
+		// given
+		updated := newTestCachedToolchainCluster(t, "member", notReady)
+
+		// when
+		cache.Add(updated)
+
+		// then
+		assert.Equal(t, ctx, updated.Context())
+		assert.NoError(t, ctx.Err())
+	})
+
+	t.Run("context is cancelled when the cluster is removed", func(t *testing.T) {
+		// when
+		cache.Delete("member")
+
+		// then
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+
+	t.Run("new context is created when the removed cluster is added again", func(t *testing.T) {
+		// when
+		cache.Add(member)
+
+		// then
+		assert.NoError(t, member.Context().Err())
+		assert.NotEqual(t, ctx, member.Context())
+	})
+}
+
+func TestOnClusterRemoved(t *testing.T) {
+	// given
+	cache := NewClusterCache()
+	member1 := newTestCachedToolchainCluster(t, "member-1", ready)
+	member2 := newTestCachedToolchainCluster(t, "member-2", ready)
+	cache.Add(member1)
+	cache.Add(member2)
+	var removed []string
+	cache.OnClusterRemoved(func(cluster *CachedToolchainCluster) {
+		// the context is already cancelled and the cache can be used in the callback
+		assert.Error(t, cluster.Context().Err())
+		_, found := cache.Get(cluster.Name)
+		assert.False(t, found)
+		removed = append(removed, "first:"+cluster.Name)
+	})
+	cache.OnClusterRemoved(func(cluster *CachedToolchainCluster) {
+		removed = append(removed, "second:"+cluster.Name)
+	})
+
+	// when
+	cache.Delete("member-1")
+	cache.Delete("unknown")
+
+	// then
+	assert.Equal(t, []string{"first:member-1", "second:member-1"}, removed)
+	assert.NoError(t, member2.Context().Err())
+}
+
+func TestOnClusterRemovedFromDefaultCache(t *testing.T) {
+	// given
+	defer resetClusterCache()
+	member := newTestCachedToolchainCluster(t, "member", ready)
+	clusterCache.addCachedToolchainCluster(member)
+	var removed *CachedToolchainCluster
+	OnClusterRemoved(func(cluster *CachedToolchainCluster) {
+		removed = cluster
+	})
+
+	// when
+	clusterCache.deleteCachedToolchainCluster("member")
+
+	// then
+	assert.Equal(t, member, removed)
+}
@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	ctrlcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// WithRuntimeClusterOptions sets the options of the controller-runtime clusters created for the cached clusters (see CachedToolchainCluster.RuntimeCluster).
+// The scheme of the clusters is the same as the one of the clients, unless it's overridden by the options.
+func WithRuntimeClusterOptions(options ...ctrlcluster.Option) ToolchainClusterServiceOption {
+	return func(s *ToolchainClusterService) {
+		s.runtimeClusterOptions = append(s.runtimeClusterOptions, options...)
+	}
+}
+
+// RuntimeCluster returns the controller-runtime cluster (with the cache and the informers) of the cluster. The cluster is created
+// and started when it's requested for the first time, and it's stopped when the cluster is removed from the cache, or when
+// the client of the cluster is replaced because of the changed rest config. Then, the new cached cluster needs to be retrieved
+// from the cache to get the new controller-runtime cluster.
+//
+// It can be used to watch the resources of the cluster, eg:
+//
+//	runtimeCluster, err := memberCluster.RuntimeCluster()
+//	...
+//	err = ctrl.NewControllerManagedBy(mgr).
+//		WatchesRawSource(source.Kind(runtimeCluster.GetCache(), &toolchainv1alpha1.NSTemplateSet{}, handler)).
+//		...
+func (c *CachedToolchainCluster) RuntimeCluster() (ctrlcluster.Cluster, error) {
+	if c.runtime == nil {
+		return nil, fmt.Errorf("the controller-runtime cluster is not available for the cluster %s", c.Name)
+	}
+	return c.runtime.get(c.Context())
+}
+
+// runtimeCluster lazily creates and starts the controller-runtime cluster of the cached cluster.
+// It's shared by the cached clusters which reuse the same client.
+type runtimeCluster struct {
+	sync.Mutex
+	name       string
+	log        logr.Logger
+	newCluster func() (ctrlcluster.Cluster, error)
+	cluster    ctrlcluster.Cluster
+	// ctx is the context of the started cluster, it's cancelled when the cluster is stopped
+	ctx     context.Context
+	stop    context.CancelFunc
+	stopped bool
+}
+
+func newRuntimeCluster(name string, log logr.Logger, restConfig *rest.Config, scheme *runtime.Scheme, options ...ctrlcluster.Option) *runtimeCluster {
+	return &runtimeCluster{
+		name: name,
+		log:  log,
+		newCluster: func() (ctrlcluster.Cluster, error) {
+			return ctrlcluster.New(restConfig, append([]ctrlcluster.Option{func(o *ctrlcluster.Options) {
+				o.Scheme = scheme
+			}}, options...)...)
+		},
+	}
+}
+
+// get returns the controller-runtime cluster. If it's not created yet, then it's created and started with the given context.
+func (r *runtimeCluster) get(ctx context.Context) (ctrlcluster.Cluster, error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.stopped || ctx.Err() != nil {
+		return nil, fmt.Errorf("the controller-runtime cluster of the cluster %s was stopped", r.name)
+	}
+	if r.cluster != nil {
+		return r.cluster, nil
+	}
+	cl, err := r.newCluster()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the controller-runtime cluster of the cluster %s: %w", r.name, err)
+	}
+	r.ctx, r.stop = context.WithCancel(ctx)
+	go func(ctx context.Context) {
+		r.log.Info("starting the controller-runtime cluster")
+		if err := cl.Start(ctx); err != nil {
+			r.log.Error(err, "the controller-runtime cluster failed")
+		}
+		r.log.Info("the controller-runtime cluster was stopped")
+	}(r.ctx)
+	r.cluster = cl
+	return cl, nil
+}
+
+// shutdown stops the controller-runtime cluster (if it was started), and prevents it from being started again
+func (r *runtimeCluster) shutdown() {
+	r.Lock()
+	defer r.Unlock()
+	r.stopped = true
+	if r.stop != nil {
+		r.stop()
+	}
+}
@@ -0,0 +1,136 @@
+package cluster
+
+import (
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestRuntimeCluster(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	cl := test.NewFakeClient(t, toolchainCluster, sec)
+	cache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCache(cl, logf.Log, test.HostOperatorNs, 0, func(_ *rest.Config, _ client.Options) (client.Client, error) {
+		return test.NewFakeClient(t), nil
+	}, cache)
+	require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+	member, found := cache.Get("member")
+	require.True(t, found)
+
+	// when
+	runtimeCluster, err := member.RuntimeCluster()
+
+	// then
+	require.NoError(t, err)
+	require.NotNil(t, runtimeCluster)
+	assert.Equal(t, "https://cluster.com", runtimeCluster.GetConfig().Host)
+	assert.True(t, runtimeCluster.GetScheme().Recognizes(toolchainv1alpha1.GroupVersion.WithKind("ToolchainCluster")))
+	startedCtx := member.runtime.ctx
+	require.NoError(t, startedCtx.Err())
+
+	t.Run("the same cluster is returned when requested again", func(t *testing.T) {
+		// when
+		again, err := member.RuntimeCluster()
+
+		// then
+		require.NoError(t, err)
+		assert.Same(t, runtimeCluster, again)
+	})
+
+	t.Run("the cluster is kept when the client is reused", func(t *testing.T) {
+		// when
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+
+		// then
+		updated, found := cache.Get("member")
+		require.True(t, found)
+		assert.NotSame(t, member, updated)
+		again, err := updated.RuntimeCluster()
+		require.NoError(t, err)
+		assert.Same(t, runtimeCluster, again)
+		assert.NoError(t, startedCtx.Err())
+	})
+
+	t.Run("the cluster is stopped when the client is replaced", func(t *testing.T) {
+		// given
+		changed := toolchainCluster.DeepCopy()
+		changed.Annotations = map[string]string{UserAgentAnnotationKey: "changed"}
+
+		// when
+		require.NoError(t, service.AddOrUpdateToolchainCluster(changed))
+
+		// then
+		assert.Error(t, startedCtx.Err())
+		_, err := member.RuntimeCluster()
+		require.EqualError(t, err, "the controller-runtime cluster of the cluster member was stopped")
+
+		updated, found := cache.Get("member")
+		require.True(t, found)
+		replaced, err := updated.RuntimeCluster()
+		require.NoError(t, err)
+		assert.NotSame(t, runtimeCluster, replaced)
+		assert.Equal(t, "changed", replaced.GetConfig().UserAgent)
+		member = updated
+		startedCtx = updated.runtime.ctx
+	})
+
+	t.Run("the cluster is stopped when the ToolchainCluster is deleted", func(t *testing.T) {
+		// when
+		service.DeleteToolchainCluster("member")
+
+		// then
+		assert.Error(t, startedCtx.Err())
+		_, err := member.RuntimeCluster()
+		require.EqualError(t, err, "the controller-runtime cluster of the cluster member was stopped")
+	})
+}
+
+func TestRuntimeClusterOptions(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	cl := test.NewFakeClient(t, toolchainCluster, sec)
+	cache := NewClusterCache()
+	scheme := runtime.NewScheme()
+	service := NewToolchainClusterServiceWithCache(cl, logf.Log, test.HostOperatorNs, 0, func(_ *rest.Config, _ client.Options) (client.Client, error) {
+		return test.NewFakeClient(t), nil
+	}, cache, WithRuntimeClusterOptions(func(options *ctrlcluster.Options) {
+		options.Scheme = scheme
+	}))
+	require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+	defer service.DeleteToolchainCluster("member")
+	member, found := cache.Get("member")
+	require.True(t, found)
+
+	// when
+	runtimeCluster, err := member.RuntimeCluster()
+
+	// then
+	require.NoError(t, err)
+	assert.Same(t, scheme, runtimeCluster.GetScheme())
+}
+
+func TestRuntimeClusterNotAvailable(t *testing.T) {
+	// given
+	member := newTestCachedToolchainCluster(t, "member", ready)
+
+	// when
+	_, err := member.RuntimeCluster()
+
+	// then
+	require.EqualError(t, err, "the controller-runtime cluster is not available for the cluster member")
+}
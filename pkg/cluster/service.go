@@ -3,7 +3,6 @@ package cluster
 import (
 	"context"
 	"fmt"
-	"reflect"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -11,11 +10,15 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
 )
 
 const (
@@ -33,30 +36,80 @@ type ToolchainClusterService struct {
 	namespace string
 	timeout   time.Duration
 	newClient NewClient
+	cache     *ClusterCache
+	// clientOptions are used for all the clusters, unless overridden by the clusterClientOptions or by the annotations of the ToolchainCluster
+	clientOptions        ClientOptions
+	clusterClientOptions map[string]ClientOptions
+	// runtimeClusterOptions configure the controller-runtime clusters of the cached clusters
+	runtimeClusterOptions []ctrlcluster.Option
 }
 
 type NewClient func(config *rest.Config, options client.Options) (client.Client, error)
 
 // NewToolchainClusterServiceWithClient creates a new instance of ToolchainClusterService object and assigns the given newClient function to be used for creating a client
-func NewToolchainClusterServiceWithClient(client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient) ToolchainClusterService {
-	service := NewToolchainClusterService(client, log, namespace, timeout)
-	service.newClient = newClient
-	clusterCache.refreshCache = service.refreshCache
-	return service
+func NewToolchainClusterServiceWithClient(client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient, options ...ToolchainClusterServiceOption) ToolchainClusterService {
+	return NewToolchainClusterServiceWithCache(client, log, namespace, timeout, newClient, clusterCache, options...)
 }
 
 // NewToolchainClusterService creates a new instance of ToolchainClusterService object and assigns the refreshCache function to the cache instance
-func NewToolchainClusterService(client client.Client, log logr.Logger, namespace string, timeout time.Duration) ToolchainClusterService {
+func NewToolchainClusterService(client client.Client, log logr.Logger, namespace string, timeout time.Duration, options ...ToolchainClusterServiceOption) ToolchainClusterService {
+	return NewToolchainClusterServiceWithCache(client, log, namespace, timeout, nil, clusterCache, options...)
+}
+
+// NewToolchainClusterServiceWithCache creates a new instance of ToolchainClusterService object which manages the clusters in the given cache
+// (instead of the default one used by the package-level functions) and assigns its loaders to the cache instance.
+// The newClient function is optional.
+func NewToolchainClusterServiceWithCache(client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient, cache *ClusterCache, options ...ToolchainClusterServiceOption) ToolchainClusterService {
 	service := ToolchainClusterService{
 		client:    client,
 		log:       log,
 		namespace: namespace,
 		timeout:   timeout,
+		newClient: newClient,
+		cache:     cache,
+	}
+	for _, configure := range options {
+		configure(&service)
 	}
-	clusterCache.refreshCache = service.refreshCache
+	cache.setLoaders(service.loadToolchainCluster, service.refreshCache)
 	return service
 }
 
+// Cache returns the cache of the clusters managed by the service
+func (s *ToolchainClusterService) Cache() *ClusterCache {
+	return s.cache
+}
+
+// SubscribeTo keeps the cache of the service up-to-date with the events of the given ToolchainCluster informer:
+// the clusters are added or updated when the ToolchainClusters are created or updated, and removed when the ToolchainClusters are deleted.
+// The ToolchainClusters in the other namespaces than the one of the service are ignored.
+func (s *ToolchainClusterService) SubscribeTo(informer ctrlcache.Informer) (toolscache.ResourceEventHandlerRegistration, error) {
+	return informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if toolchainCluster, ok := obj.(*toolchainv1alpha1.ToolchainCluster); ok && toolchainCluster.Namespace == s.namespace {
+				if err := s.AddOrUpdateToolchainCluster(toolchainCluster); err != nil {
+					s.enrichLogger(toolchainCluster).Error(err, "the cluster was not added")
+				}
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if toolchainCluster, ok := obj.(*toolchainv1alpha1.ToolchainCluster); ok && toolchainCluster.Namespace == s.namespace {
+				if err := s.AddOrUpdateToolchainCluster(toolchainCluster); err != nil {
+					s.enrichLogger(toolchainCluster).Error(err, "the cluster was not updated")
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if toolchainCluster, ok := obj.(*toolchainv1alpha1.ToolchainCluster); ok && toolchainCluster.Namespace == s.namespace {
+				s.DeleteToolchainCluster(toolchainCluster.Name)
+			}
+		},
+	})
+}
+
 // AddOrUpdateToolchainCluster takes the ToolchainCluster CR object,
 // creates CachedToolchainCluster with a kube client and stores it in a cache
 func (s *ToolchainClusterService) AddOrUpdateToolchainCluster(cluster *toolchainv1alpha1.ToolchainCluster) error {
@@ -81,26 +134,40 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 	if err != nil {
 		return errors.Wrap(err, "cannot create ToolchainCluster Config")
 	}
+	// the annotations of the ToolchainCluster take precedence over the client options of the service
+	clusterConfig.ClientOptions = clusterConfig.ClientOptions.
+		withDefaults(s.clusterClientOptions[toolchainCluster.Name]).
+		withDefaults(s.clientOptions)
+	if err := clusterConfig.ClientOptions.applyTo(clusterConfig.RestConfig); err != nil {
+		return errors.Wrap(err, "cannot apply the client options")
+	}
 
 	var cl client.Client
+	var runtimeCl *runtimeCluster
+	var token *bearerToken
+	// the clients use a copy of the rest config whose bearer token can be replaced (see updateBearerToken)
+	clientConfig := clusterConfig.RestConfig
 	// check if there is already a cached ToolchainCluster so we could reuse the client
 	// we cannot allow to refresh the cache, because the refresh function calls this addToolchainCluster method which results in a recursive loop
-	cachedToolchainCluster, exists := clusterCache.getCachedToolchainCluster(toolchainCluster.Name, false)
+	cachedToolchainCluster, exists := s.cache.getCachedToolchainCluster(toolchainCluster.Name, false)
 	if !exists ||
 		cachedToolchainCluster.Client == nil ||
-		!reflect.DeepEqual(clusterConfig.RestConfig, cachedToolchainCluster.RestConfig) {
+		!sameRestConfig(clusterConfig, cachedToolchainCluster.Config) {
 
 		log.Info("creating new client for the cached ToolchainCluster")
 		scheme := runtime.NewScheme()
 		if err := apis.AddToScheme(scheme); err != nil {
 			return err
 		}
+		if token = newBearerToken(clusterConfig.RestConfig); token != nil {
+			clientConfig = token.wrap(clusterConfig.RestConfig)
+		}
 		if s.newClient == nil {
-			cl, err = client.New(clusterConfig.RestConfig, client.Options{
+			cl, err = client.New(clientConfig, client.Options{
 				Scheme: scheme,
 			})
 		} else {
-			cl, err = s.newClient(clusterConfig.RestConfig, client.Options{
+			cl, err = s.newClient(clientConfig, client.Options{
 				Scheme: scheme,
 			})
 		}
@@ -110,27 +177,81 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 	} else {
 		// log.Info("reusing the client for the cached ToolchainCluster")
 		cl = cachedToolchainCluster.Client
+		runtimeCl = cachedToolchainCluster.runtime
+		token = cachedToolchainCluster.token
+	}
+	if runtimeCl == nil {
+		// the controller-runtime cluster shares the scheme of the client
+		runtimeCl = newRuntimeCluster(toolchainCluster.Name, log, clientConfig, cl.Scheme(), s.runtimeClusterOptions...)
 	}
 
 	cluster := &CachedToolchainCluster{
 		Config:        clusterConfig,
 		Client:        cl,
 		ClusterStatus: &toolchainCluster.Status,
+		runtime:       runtimeCl,
+		token:         token,
 	}
 
-	if cluster.OperatorNamespace == "" {
-		return fmt.Errorf("the operator namespace is not set for the ToolchainCluster CR")
+	if err := validateConfig(cluster.Config); err != nil {
+		return err
 	}
 
-	clusterCache.addCachedToolchainCluster(cluster)
+	s.cache.addCachedToolchainCluster(cluster)
+	// the controller-runtime cluster of the replaced client is not used anymore
+	if exists && cachedToolchainCluster.runtime != nil && cachedToolchainCluster.runtime != runtimeCl {
+		cachedToolchainCluster.runtime.shutdown()
+	}
 	return nil
 }
 
+// updateBearerToken replaces the bearer token used by the client of the cached ToolchainCluster with the given name,
+// without creating a new client. Nothing is done if the cluster is not cached, or if its client doesn't use a static bearer token.
+func (s *ToolchainClusterService) updateBearerToken(name, token string) {
+	for {
+		cachedToolchainCluster, exists := s.cache.getCachedToolchainCluster(name, false)
+		if !exists || cachedToolchainCluster.token == nil {
+			return
+		}
+		cachedToolchainCluster.token.set(token)
+		// the cached config is updated too, so that the client is reused when the ToolchainCluster is updated with the new secret
+		clusterConfig := *cachedToolchainCluster.Config
+		clusterConfig.RestConfig = rest.CopyConfig(cachedToolchainCluster.RestConfig)
+		clusterConfig.RestConfig.BearerToken = token
+		updated := &CachedToolchainCluster{
+			Config:        &clusterConfig,
+			Client:        cachedToolchainCluster.Client,
+			ClusterStatus: cachedToolchainCluster.ClusterStatus,
+			runtime:       cachedToolchainCluster.runtime,
+			token:         cachedToolchainCluster.token,
+		}
+		if s.cache.replaceCachedToolchainCluster(cachedToolchainCluster, updated) {
+			return
+		}
+		// the cluster was replaced in the meantime (eg. with a new status), so the newer one is updated instead
+	}
+}
+
 // DeleteToolchainCluster takes the ToolchainCluster CR object
-// and deletes CachedToolchainCluster instance that has same name from a cache (if exists)
+// and deletes CachedToolchainCluster instance that has same name from a cache (if exists).
+// The context of the deleted CachedToolchainCluster is cancelled and the OnClusterRemoved callbacks are called.
 func (s *ToolchainClusterService) DeleteToolchainCluster(name string) {
 	s.log.WithValues("Request.Name", name).Info("observed a deleted cluster")
-	clusterCache.deleteCachedToolchainCluster(name)
+	s.cache.deleteCachedToolchainCluster(name)
+}
+
+// loadToolchainCluster adds the ToolchainCluster with the given name to the cache, if it exists
+func (s *ToolchainClusterService) loadToolchainCluster(name string) {
+	toolchainCluster := &toolchainv1alpha1.ToolchainCluster{}
+	if err := s.client.Get(context.TODO(), types.NamespacedName{Namespace: s.namespace, Name: name}, toolchainCluster); err != nil {
+		if !apierrors.IsNotFound(err) {
+			s.log.Error(err, "the cluster was not loaded in the cache", "Request.Name", name)
+		}
+		return
+	}
+	if err := s.addToolchainCluster(s.enrichLogger(toolchainCluster), toolchainCluster); err != nil {
+		s.log.Error(err, "the cluster was not added", "Request.Name", name)
+	}
 }
 
 func (s *ToolchainClusterService) refreshCache() {
@@ -157,7 +278,7 @@ func (s *ToolchainClusterService) enrichLogger(cluster *toolchainv1alpha1.Toolch
 func NewClusterConfig(cl client.Client, toolchainCluster *toolchainv1alpha1.ToolchainCluster, timeout time.Duration) (*Config, error) {
 	secretName := toolchainCluster.Spec.SecretRef.Name
 	if secretName == "" {
-		return nil, errors.Errorf("cluster %s does not have a secret name", toolchainCluster.Name)
+		return nil, withFailureReason(FailureReasonCredentialsMissing, errors.Errorf("cluster %s does not have a secret name", toolchainCluster.Name))
 	}
 	secret := &v1.Secret{}
 	name := types.NamespacedName{
@@ -166,30 +287,39 @@ func NewClusterConfig(cl client.Client, toolchainCluster *toolchainv1alpha1.Tool
 	}
 	err := cl.Get(context.TODO(), name, secret)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get secret %s for cluster %s: %w", name, toolchainCluster.Name, err)
+		err = fmt.Errorf("unable to get secret %s for cluster %s: %w", name, toolchainCluster.Name, err)
+		if apierrors.IsNotFound(err) {
+			return nil, withFailureReason(FailureReasonCredentialsMissing, err)
+		}
+		return nil, err
 	}
 
-	return loadConfigFromKubeConfig(toolchainCluster, secret, timeout)
+	return loadConfig(toolchainCluster, secret, timeout)
 }
 
-func loadConfigFromKubeConfig(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, timeout time.Duration) (*Config, error) {
-	cfg, err := clientcmd.Load(secret.Data["kubeconfig"])
-	if err != nil {
-		return nil, err
-	}
-	clientCfg := clientcmd.NewDefaultClientConfig(*cfg, &clientcmd.ConfigOverrides{})
-	restCfg, err := clientCfg.ClientConfig()
+// loadConfig creates the config using the credentials stored in the secret (see CredentialSourceOf)
+func loadConfig(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, timeout time.Duration) (*Config, error) {
+	restCfg, operatorNamespace, err := credentialsFromSecret(secret)
 	if err != nil {
+		if FailureReason(err) == FailureReasonUnknown {
+			return nil, withFailureReason(FailureReasonInvalidConfig, err)
+		}
 		return nil, err
 	}
 
 	// This is questionable, but the timeout is currently configurable in the member configuration so let's keep it here...
 	restCfg.Timeout = timeout
 
-	operatorNamespace, _, err := clientCfg.Namespace()
+	clientOptions, err := clientOptionsFromAnnotations(toolchainCluster)
 	if err != nil {
-		return nil, fmt.Errorf("could not determine the operator namespace from the current context in the provided kubeconfig because of: %w", err)
+		return nil, err
+	}
+	if err := clientOptions.applyTo(restCfg); err != nil {
+		return nil, err
 	}
+	// the expiry of the certificates is informative only, so the invalid certificates don't prevent the cluster from being added
+	// (the TLS handshake reports them anyway)
+	clientCertificateNotAfter, caNotAfter, certificatesErr := CertificatesNotAfter(restCfg)
 
 	return &Config{
 		Name:              toolchainCluster.Name,
@@ -198,9 +328,34 @@ func loadConfigFromKubeConfig(toolchainCluster *toolchainv1alpha1.ToolchainClust
 		OperatorNamespace: operatorNamespace,
 		OwnerClusterName:  toolchainCluster.Labels[labelOwnerClusterName],
 		Labels:            toolchainCluster.Labels,
+		ClientOptions:     clientOptions,
+
+		ClientCertificateNotAfter: clientCertificateNotAfter,
+		CANotAfter:                caNotAfter,
+		CertificatesError:         certificatesErr,
 	}, nil
 }
 
+// restConfigFromKubeConfig creates the rest config from the current context of the given kubeconfig. It also returns
+// the namespace of the current context, which is the operator namespace.
+func restConfigFromKubeConfig(kubeConfig []byte) (*rest.Config, string, error) {
+	cfg, err := clientcmd.Load(kubeConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	clientCfg := clientcmd.NewDefaultClientConfig(*cfg, &clientcmd.ConfigOverrides{})
+	restCfg, err := clientCfg.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	operatorNamespace, _, err := clientCfg.Namespace()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not determine the operator namespace from the current context in the provided kubeconfig because of: %w", err)
+	}
+	return restCfg, operatorNamespace, nil
+}
+
 func IsReady(clusterStatus *toolchainv1alpha1.ToolchainClusterStatus) bool {
 	for _, condition := range clusterStatus.Conditions {
 		if condition.Type == toolchainv1alpha1.ConditionReady {
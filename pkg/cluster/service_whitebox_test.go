@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -137,3 +139,95 @@ func assertMemberCluster(t *testing.T, cachedCluster *CachedToolchainCluster, st
 	assert.Equal(t, status, *cachedCluster.ClusterStatus)
 	assert.Equal(t, "https://cluster.com", cachedCluster.APIEndpoint)
 }
+
+func TestServiceWithOwnCache(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster(t, "own", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	cl := test.NewFakeClient(t, toolchainCluster, sec)
+	cache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCache(cl, logf.Log, test.HostOperatorNs, 0, nil, cache)
+
+	t.Run("missing cluster is loaded by name", func(t *testing.T) {
+		// given
+		listed := false
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			listed = true
+			return cl.Client.List(ctx, list, opts...)
+		}
+		defer func() {
+			cl.MockList = nil
+		}()
+
+		// when
+		cachedCluster, ok := service.Cache().Get("own")
+
+		// then
+		require.True(t, ok)
+		assert.Equal(t, "https://cluster.com", cachedCluster.APIEndpoint)
+		assert.False(t, listed)
+		// the default cache is not affected
+		_, ok = clusterCache.getCachedToolchainCluster("own", false)
+		assert.False(t, ok)
+	})
+
+	t.Run("unknown cluster is not found", func(t *testing.T) {
+		// when
+		cachedCluster, ok := service.Cache().Get("unknown")
+
+		// then
+		require.False(t, ok)
+		assert.Nil(t, cachedCluster)
+	})
+}
+
+func TestSubscribeToInformer(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	otherNamespace, otherSec := test.NewToolchainCluster(t, "other", "other-namespace", test.MemberOperatorNs, "secret", status, false)
+	cl := test.NewFakeClient(t, toolchainCluster, sec, otherNamespace, otherSec)
+	cache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCache(cl, logf.Log, test.HostOperatorNs, 0, nil, cache)
+	informer := &controllertest.FakeInformer{}
+	_, err := service.SubscribeTo(informer)
+	require.NoError(t, err)
+
+	t.Run("cluster is added", func(t *testing.T) {
+		// when
+		informer.Add(toolchainCluster)
+		informer.Add(otherNamespace)
+
+		// then
+		cachedCluster, ok := cache.getCachedToolchainCluster("member", false)
+		require.True(t, ok)
+		assert.Equal(t, status, *cachedCluster.ClusterStatus)
+		_, ok = cache.getCachedToolchainCluster("other", false)
+		assert.False(t, ok)
+	})
+
+	t.Run("cluster is updated", func(t *testing.T) {
+		// given
+		updated := toolchainCluster.DeepCopy()
+		updated.Status = test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionFalse)
+
+		// when
+		informer.Update(toolchainCluster, updated)
+
+		// then
+		cachedCluster, ok := cache.getCachedToolchainCluster("member", false)
+		require.True(t, ok)
+		assert.Equal(t, updated.Status, *cachedCluster.ClusterStatus)
+	})
+
+	t.Run("cluster is deleted", func(t *testing.T) {
+		// when
+		informer.Delete(toolchainCluster)
+
+		// then
+		_, ok := cache.getCachedToolchainCluster("member", false)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,277 @@
+package cluster
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	commonclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/pkg/errors"
+	authv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// DefaultTokenExpiration is the default lifetime of the tokens minted by the TokenRotator
+	DefaultTokenExpiration = 24 * time.Hour
+	// DefaultTokenRotationInterval is the default time between two checks of the tokens by the TokenRotator
+	DefaultTokenRotationInterval = 10 * time.Minute
+	// DefaultTokenRotationThreshold is the default fraction of the token lifetime after which the token is rotated
+	DefaultTokenRotationThreshold = 0.8
+
+	serviceAccountSubjectPrefix = "system:serviceaccount:"
+)
+
+var _ manager.Runnable = &TokenRotator{}
+
+// TokenRotator periodically replaces the ServiceAccount tokens used to connect to the ToolchainClusters with fresh ones
+// minted via the TokenRequest API of the remote cluster. The token is rotated before it expires, the secret of the ToolchainCluster
+// is rewritten and the token used by the client of the cached ToolchainCluster is replaced, so that long-lived static tokens are no longer needed.
+// Only the secrets using the CredentialSourceKubeConfig or the CredentialSourceToken are rotated.
+type TokenRotator struct {
+	service    *ToolchainClusterService
+	expiration time.Duration
+	interval   time.Duration
+	threshold  float64
+	now        func() time.Time
+}
+
+// TokenRotatorOption an option to configure the TokenRotator
+type TokenRotatorOption func(*TokenRotator)
+
+// TokenExpiration sets the lifetime of the minted tokens (default: `DefaultTokenExpiration`)
+func TokenExpiration(expiration time.Duration) TokenRotatorOption {
+	return func(r *TokenRotator) {
+		r.expiration = expiration
+	}
+}
+
+// TokenRotationInterval sets the time between two checks of the tokens (default: `DefaultTokenRotationInterval`).
+// It should be significantly shorter than the token lifetime, otherwise the tokens may expire before they are rotated.
+func TokenRotationInterval(interval time.Duration) TokenRotatorOption {
+	return func(r *TokenRotator) {
+		r.interval = interval
+	}
+}
+
+// TokenRotationThreshold sets the fraction of the token lifetime (between 0 and 1) after which the token is rotated
+// (default: `DefaultTokenRotationThreshold`)
+func TokenRotationThreshold(threshold float64) TokenRotatorOption {
+	return func(r *TokenRotator) {
+		r.threshold = threshold
+	}
+}
+
+// NewTokenRotator creates a new TokenRotator which rotates the tokens of the ToolchainClusters managed by the given service.
+// It returns an error if the options are not valid.
+func NewTokenRotator(service *ToolchainClusterService, options ...TokenRotatorOption) (*TokenRotator, error) {
+	r := &TokenRotator{
+		service:    service,
+		expiration: DefaultTokenExpiration,
+		interval:   DefaultTokenRotationInterval,
+		threshold:  DefaultTokenRotationThreshold,
+		now:        time.Now,
+	}
+	for _, apply := range options {
+		apply(r)
+	}
+	if r.interval <= 0 {
+		return nil, fmt.Errorf("the token rotation interval must be positive: %s", r.interval)
+	}
+	if r.expiration <= 0 {
+		return nil, fmt.Errorf("the token expiration must be positive: %s", r.expiration)
+	}
+	if r.threshold <= 0 || r.threshold > 1 {
+		return nil, fmt.Errorf("the token rotation threshold must be between 0 and 1: %v", r.threshold)
+	}
+	return r, nil
+}
+
+// Start rotates the tokens of all the ToolchainClusters, and then it checks them again on every interval until the context is done.
+// It implements the manager.Runnable interface, so the TokenRotator can be added to the controller manager.
+func (r *TokenRotator) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.RotateAll(ctx); err != nil {
+			r.service.log.Error(err, "unable to rotate the tokens of some ToolchainClusters")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RotateAll rotates the tokens of all the ToolchainClusters in the namespace of the service when they are about to expire.
+// The failure of one ToolchainCluster doesn't prevent the tokens of the other ones from being rotated.
+func (r *TokenRotator) RotateAll(ctx context.Context) error {
+	toolchainClusters := &toolchainv1alpha1.ToolchainClusterList{}
+	if err := r.service.client.List(ctx, toolchainClusters, client.InNamespace(r.service.namespace)); err != nil {
+		return errors.Wrap(err, "unable to list the ToolchainClusters")
+	}
+	var errs []error
+	for i := range toolchainClusters.Items {
+		toolchainCluster := &toolchainClusters.Items[i]
+		if _, err := r.RotateIfNeeded(ctx, toolchainCluster); err != nil {
+			errs = append(errs, fmt.Errorf("unable to rotate the token of the ToolchainCluster %s: %w", toolchainCluster.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// RotateIfNeeded rotates the token used to connect to the given ToolchainCluster if it reached the rotation threshold
+// of its lifetime or if it doesn't expire at all. It returns `true` if the token was rotated.
+// The ToolchainClusters which don't connect with a ServiceAccount token are left untouched.
+func (r *TokenRotator) RotateIfNeeded(ctx context.Context, toolchainCluster *toolchainv1alpha1.ToolchainCluster) (bool, error) {
+	log := r.service.enrichLogger(toolchainCluster)
+	secret := &v1.Secret{}
+	name := types.NamespacedName{
+		Namespace: toolchainCluster.Namespace,
+		Name:      toolchainCluster.Spec.SecretRef.Name,
+	}
+	if err := r.service.client.Get(ctx, name, secret); err != nil {
+		return false, fmt.Errorf("unable to get secret %s for cluster %s: %w", name, toolchainCluster.Name, err)
+	}
+	currentToken, setToken, err := rotatableToken(secret)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to read the token from secret %s", name)
+	}
+	if currentToken == "" {
+		// the cluster is not accessed using a token stored in the secret, so there's nothing to rotate
+		return false, nil
+	}
+	claims, err := parseServiceAccountToken(currentToken)
+	if err != nil {
+		log.Info("the token of the ToolchainCluster is not a ServiceAccount token, it won't be rotated", "reason", err.Error())
+		return false, nil
+	}
+	if !r.needsRotation(claims) {
+		return false, nil
+	}
+
+	restConfig, _, err := credentialsFromSecret(secret)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to create the rest config from secret %s", name)
+	}
+	restConfig.ContentConfig = rest.ContentConfig{
+		GroupVersion:         &authv1.SchemeGroupVersion,
+		NegotiatedSerializer: scheme.Codecs,
+	}
+	restClient, err := rest.RESTClientFor(restConfig)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to create the rest client for cluster %s", toolchainCluster.Name)
+	}
+	token, err := commonclient.CreateTokenRequest(ctx, restClient, claims.serviceAccount, int(r.expiration.Seconds()))
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to create a token for the ServiceAccount %s in cluster %s", claims.serviceAccount, toolchainCluster.Name)
+	}
+
+	if err := setToken(token); err != nil {
+		return false, errors.Wrapf(err, "unable to set the token in secret %s", name)
+	}
+	if err := r.service.client.Update(ctx, secret); err != nil {
+		return false, fmt.Errorf("unable to update secret %s for cluster %s: %w", name, toolchainCluster.Name, err)
+	}
+	log.Info("rotated the token of the ToolchainCluster", "ServiceAccount", claims.serviceAccount.String())
+
+	// the client of the cached ToolchainCluster (if any) is kept, only its token is replaced
+	r.service.updateBearerToken(toolchainCluster.Name, token)
+	return true, nil
+}
+
+// rotatableToken returns the token stored in the secret (if the credential source of the secret supports the rotation)
+// and a function which replaces the token in the secret
+func rotatableToken(secret *v1.Secret) (string, func(string) error, error) {
+	switch CredentialSourceOf(secret) {
+	case CredentialSourceKubeConfig:
+		kubeConfig, err := clientcmd.Load(secret.Data[SecretKeyKubeConfig])
+		if err != nil {
+			return "", nil, err
+		}
+		kubeContext, found := kubeConfig.Contexts[kubeConfig.CurrentContext]
+		if !found {
+			return "", nil, fmt.Errorf("the current context '%s' is not defined in the kubeconfig", kubeConfig.CurrentContext)
+		}
+		authInfo, found := kubeConfig.AuthInfos[kubeContext.AuthInfo]
+		if !found {
+			return "", nil, nil
+		}
+		return authInfo.Token, func(token string) error {
+			authInfo.Token = token
+			data, err := clientcmd.Write(*kubeConfig)
+			if err != nil {
+				return err
+			}
+			secret.Data[SecretKeyKubeConfig] = data
+			if _, found := secret.Data[SecretKeyToken]; found {
+				secret.Data[SecretKeyToken] = []byte(token)
+			}
+			return nil
+		}, nil
+	case CredentialSourceToken:
+		return strings.TrimSpace(string(secret.Data[SecretKeyToken])), func(token string) error {
+			secret.Data[SecretKeyToken] = []byte(token)
+			return nil
+		}, nil
+	default:
+		return "", nil, nil
+	}
+}
+
+// needsRotation checks if the token reached the rotation threshold of its lifetime. The tokens which don't expire are always rotated.
+func (r *TokenRotator) needsRotation(claims *serviceAccountTokenClaims) bool {
+	if claims.ExpiresAt == 0 {
+		return true
+	}
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	lifetime := r.expiration
+	if claims.IssuedAt != 0 {
+		lifetime = expiresAt.Sub(time.Unix(claims.IssuedAt, 0))
+	}
+	rotateAt := expiresAt.Add(-time.Duration(float64(lifetime) * (1 - r.threshold)))
+	return !r.now().Before(rotateAt)
+}
+
+type serviceAccountTokenClaims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+
+	serviceAccount types.NamespacedName
+}
+
+// parseServiceAccountToken reads the claims of the given ServiceAccount token. The signature is not verified,
+// as the token is only inspected to find out which ServiceAccount it belongs to and when it expires.
+func parseServiceAccountToken(token string) (*serviceAccountTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("the token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode the payload of the token")
+	}
+	claims := &serviceAccountTokenClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the claims of the token")
+	}
+	segments := strings.Split(strings.TrimPrefix(claims.Subject, serviceAccountSubjectPrefix), ":")
+	if !strings.HasPrefix(claims.Subject, serviceAccountSubjectPrefix) || len(segments) != 2 {
+		return nil, fmt.Errorf("the subject of the token is not a ServiceAccount: '%s'", claims.Subject)
+	}
+	claims.serviceAccount = types.NamespacedName{Namespace: segments[0], Name: segments[1]}
+	return claims, nil
+}
@@ -0,0 +1,308 @@
+package cluster
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRotateIfNeeded(t *testing.T) {
+	// given
+	now := time.Now()
+	sa := types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "toolchaincluster-member"}
+	subject := "system:serviceaccount:" + sa.Namespace + ":" + sa.Name
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+
+	setup := func(t *testing.T, token string) (*TokenRotator, *toolchainv1alpha1.ToolchainCluster, client.Client) {
+		t.Cleanup(gock.Off)
+		toolchainCluster, secret := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+		setToken(t, secret, token)
+		cl := test.NewFakeClient(t, toolchainCluster, secret)
+		service := newToolchainClusterService(cl, 3*time.Second, test.HostOperatorNs)
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+		t.Cleanup(func() {
+			service.DeleteToolchainCluster("east")
+		})
+		rotator, err := NewTokenRotator(&service, TokenExpiration(time.Hour))
+		require.NoError(t, err)
+		rotator.now = func() time.Time {
+			return now
+		}
+		return rotator, toolchainCluster, cl
+	}
+
+	t.Run("rotates the token that is about to expire", func(t *testing.T) {
+		// given
+		rotator, toolchainCluster, cl := setup(t, newServiceAccountToken(t, subject, now.Add(-55*time.Minute), now.Add(5*time.Minute)))
+		test.SetupGockForServiceAccounts(t, "https://cluster.com", sa)
+		originalCluster, ok := GetCachedToolchainCluster("east")
+		require.True(t, ok)
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		assertToken(t, cl, "token-secret-for-toolchaincluster-member")
+		cachedCluster, ok := GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.Equal(t, "token-secret-for-toolchaincluster-member", cachedCluster.RestConfig.BearerToken)
+		assert.Equal(t, test.MemberOperatorNs, cachedCluster.OperatorNamespace)
+		// the token is replaced in the existing client
+		assert.Same(t, originalCluster.Client, cachedCluster.Client)
+		require.NotNil(t, cachedCluster.token)
+		assert.Equal(t, "token-secret-for-toolchaincluster-member", cachedCluster.token.get())
+
+		// and the client is still reused when the ToolchainCluster is updated with the new secret
+		require.NoError(t, rotator.service.AddOrUpdateToolchainCluster(toolchainCluster))
+		updatedCluster, ok := GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.Same(t, originalCluster.Client, updatedCluster.Client)
+	})
+
+	t.Run("rotates the token that doesn't expire", func(t *testing.T) {
+		// given
+		rotator, toolchainCluster, cl := setup(t, newServiceAccountToken(t, subject, time.Time{}, time.Time{}))
+		test.SetupGockForServiceAccounts(t, "https://cluster.com", sa)
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		assertToken(t, cl, "token-secret-for-toolchaincluster-member")
+	})
+
+	t.Run("rotates the token stored in the token key", func(t *testing.T) {
+		// given
+		rotator, toolchainCluster, cl := setup(t, "mycooltoken")
+		secret := &corev1.Secret{}
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "secret"}, secret))
+		secret.Type = corev1.SecretTypeServiceAccountToken
+		secret.Data = map[string][]byte{
+			SecretKeyAPIURL:    []byte("https://cluster.com"),
+			SecretKeyNamespace: []byte(test.MemberOperatorNs),
+			SecretKeyToken:     []byte(newServiceAccountToken(t, subject, now.Add(-2*time.Hour), now.Add(-time.Hour))),
+		}
+		require.NoError(t, cl.Update(context.TODO(), secret))
+		test.SetupGockForServiceAccounts(t, "https://cluster.com", sa)
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, rotated)
+		require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: "secret"}, secret))
+		assert.Equal(t, "token-secret-for-toolchaincluster-member", string(secret.Data[SecretKeyToken]))
+		cachedCluster, ok := GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.Equal(t, "token-secret-for-toolchaincluster-member", cachedCluster.RestConfig.BearerToken)
+	})
+
+	t.Run("doesn't rotate the token that is still fresh", func(t *testing.T) {
+		// given
+		token := newServiceAccountToken(t, subject, now.Add(-5*time.Minute), now.Add(55*time.Minute))
+		rotator, toolchainCluster, cl := setup(t, token)
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, rotated)
+		assertToken(t, cl, token)
+	})
+
+	t.Run("doesn't rotate the token that is not a ServiceAccount token", func(t *testing.T) {
+		// given
+		rotator, toolchainCluster, cl := setup(t, "mycooltoken")
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, rotated)
+		assertToken(t, cl, "mycooltoken")
+	})
+
+	t.Run("fails when the token can't be created", func(t *testing.T) {
+		// given
+		token := newServiceAccountToken(t, subject, now.Add(-2*time.Hour), now.Add(-time.Hour))
+		rotator, toolchainCluster, cl := setup(t, token)
+		test.SetupGockWithCleanup(t, "https://cluster.com", "api/v1/namespaces/toolchain-member-operator/serviceaccounts/toolchaincluster-member/token", "{}", http.StatusForbidden)
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.ErrorContains(t, err, "unable to create a token for the ServiceAccount toolchain-member-operator/toolchaincluster-member in cluster east")
+		assert.False(t, rotated)
+		assertToken(t, cl, token)
+	})
+
+	t.Run("fails when the secret is missing", func(t *testing.T) {
+		// given
+		rotator, toolchainCluster, _ := setup(t, "mycooltoken")
+		toolchainCluster = toolchainCluster.DeepCopy()
+		toolchainCluster.Spec.SecretRef.Name = "unknown"
+
+		// when
+		rotated, err := rotator.RotateIfNeeded(context.TODO(), toolchainCluster)
+
+		// then
+		require.ErrorContains(t, err, "unable to get secret toolchain-host-operator/unknown for cluster east")
+		assert.False(t, rotated)
+	})
+}
+
+func TestRotateAll(t *testing.T) {
+	// given
+	defer gock.Off()
+	now := time.Now()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	toolchainCluster1, secret1 := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret1", status, false)
+	setToken(t, secret1, newServiceAccountToken(t, "system:serviceaccount:"+test.MemberOperatorNs+":toolchaincluster-member", now.Add(-time.Hour), now))
+	toolchainCluster2, secret2 := test.NewToolchainCluster(t, "west", test.HostOperatorNs, test.MemberOperatorNs, "secret2", status, false)
+	cl := test.NewFakeClient(t, toolchainCluster1, secret1, toolchainCluster2, secret2)
+	service := newToolchainClusterService(cl, 3*time.Second, test.HostOperatorNs)
+	defer service.DeleteToolchainCluster("east")
+	test.SetupGockForServiceAccounts(t, "https://cluster.com", types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "toolchaincluster-member"})
+	rotator, err := NewTokenRotator(&service)
+	require.NoError(t, err)
+
+	// when
+	err = rotator.RotateAll(context.TODO())
+
+	// then
+	require.NoError(t, err)
+	assertTokenInSecret(t, cl, "secret1", "token-secret-for-toolchaincluster-member")
+	assertTokenInSecret(t, cl, "secret2", "mycooltoken")
+	cachedCluster, ok := GetCachedToolchainCluster("east")
+	require.True(t, ok)
+	assert.Equal(t, "token-secret-for-toolchaincluster-member", cachedCluster.RestConfig.BearerToken)
+}
+
+func TestNewTokenRotator(t *testing.T) {
+	service := newToolchainClusterService(test.NewFakeClient(t), 3*time.Second, test.HostOperatorNs)
+
+	for name, data := range map[string]struct {
+		option      TokenRotatorOption
+		expectedErr string
+	}{
+		"zero interval": {
+			option:      TokenRotationInterval(0),
+			expectedErr: "the token rotation interval must be positive: 0s",
+		},
+		"negative interval": {
+			option:      TokenRotationInterval(-time.Minute),
+			expectedErr: "the token rotation interval must be positive: -1m0s",
+		},
+		"zero expiration": {
+			option:      TokenExpiration(0),
+			expectedErr: "the token expiration must be positive: 0s",
+		},
+		"threshold greater than 1": {
+			option:      TokenRotationThreshold(1.5),
+			expectedErr: "the token rotation threshold must be between 0 and 1: 1.5",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			_, err := NewTokenRotator(&service, data.option)
+
+			// then
+			require.EqualError(t, err, data.expectedErr)
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	// given
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+	restConfig := &rest.Config{Host: server.URL, BearerToken: "first"}
+	token := newBearerToken(restConfig)
+	require.NotNil(t, token)
+	httpClient, err := rest.HTTPClientFor(token.wrap(restConfig))
+	require.NoError(t, err)
+
+	// when
+	for _, value := range []string{"", "second"} {
+		if value != "" {
+			token.set(value)
+		}
+		resp, err := httpClient.Get(server.URL)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	// then
+	assert.Equal(t, []string{"Bearer first", "Bearer second"}, received)
+	// the original config is not modified
+	assert.Nil(t, restConfig.WrapTransport)
+
+	t.Run("no static bearer token", func(t *testing.T) {
+		assert.Nil(t, newBearerToken(&rest.Config{}))
+		assert.Nil(t, newBearerToken(&rest.Config{BearerToken: "token", BearerTokenFile: "/var/run/token"}))
+	})
+}
+
+func newServiceAccountToken(t *testing.T, subject string, issuedAt, expiresAt time.Time) string {
+	claims := map[string]interface{}{
+		"sub": subject,
+	}
+	if !issuedAt.IsZero() {
+		claims["iat"] = issuedAt.Unix()
+	}
+	if !expiresAt.IsZero() {
+		claims["exp"] = expiresAt.Unix()
+	}
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func setToken(t *testing.T, secret *corev1.Secret, token string) {
+	kubeConfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+	require.NoError(t, err)
+	for _, authInfo := range kubeConfig.AuthInfos {
+		authInfo.Token = token
+	}
+	secret.Data["kubeconfig"], err = clientcmd.Write(*kubeConfig)
+	require.NoError(t, err)
+}
+
+func assertToken(t *testing.T, cl client.Client, expected string) {
+	assertTokenInSecret(t, cl, "secret", expected)
+}
+
+func assertTokenInSecret(t *testing.T, cl client.Client, secretName, expected string) {
+	secret := &corev1.Secret{}
+	require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: test.HostOperatorNs, Name: secretName}, secret))
+	kubeConfig, err := clientcmd.Load(secret.Data["kubeconfig"])
+	require.NoError(t, err)
+	assert.Equal(t, expected, kubeConfig.AuthInfos[kubeConfig.Contexts[kubeConfig.CurrentContext].AuthInfo].Token)
+}
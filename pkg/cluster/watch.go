@@ -0,0 +1,210 @@
+package cluster
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ClusterEventType is the type of change of a cluster in the cache
+type ClusterEventType string
+
+const (
+	// ClusterAdded the cluster was added in the cache
+	ClusterAdded ClusterEventType = "Added"
+	// ClusterRemoved the cluster was removed from the cache
+	ClusterRemoved ClusterEventType = "Removed"
+	// ClusterReadyChanged the cluster became Ready, or it's not Ready anymore
+	ClusterReadyChanged ClusterEventType = "ReadyChanged"
+	// ClusterRolesChanged the cluster-role labels of the cluster were changed
+	ClusterRolesChanged ClusterEventType = "RolesChanged"
+)
+
+// ClusterEvent is a change of a cluster in the cache
+type ClusterEvent struct {
+	Type ClusterEventType
+	// Name is the name of the cluster
+	Name string
+	// Cluster is the cluster after the change. In case of the ClusterRemoved event, it's the removed cluster.
+	Cluster *CachedToolchainCluster
+	// Previous is the cluster before the change. It's nil in case of the ClusterAdded and ClusterRemoved events.
+	Previous *CachedToolchainCluster
+}
+
+// Watch returns a channel which receives the events of the changes of the clusters in the cache until the given context is done.
+// The channel is closed afterwards. The events are queued for every watcher, so a slow consumer doesn't block the cache nor the
+// other watchers.
+func (c *ClusterCache) Watch(ctx context.Context) <-chan ClusterEvent {
+	w := &clusterWatcher{
+		events: make(chan ClusterEvent),
+		notify: make(chan struct{}, 1),
+	}
+	c.Lock()
+	if c.watchers == nil {
+		c.watchers = map[*clusterWatcher]struct{}{}
+	}
+	c.watchers[w] = struct{}{}
+	c.Unlock()
+
+	go func() {
+		defer close(w.events)
+		w.run(ctx)
+		c.Lock()
+		delete(c.watchers, w)
+		c.Unlock()
+	}()
+	return w.events
+}
+
+// publish queues the given events for all the watchers. The lock must be held so that all the watchers receive
+// the events in the same order as the changes were done in the cache.
+func (c *ClusterCache) publish(events ...ClusterEvent) {
+	if len(events) == 0 {
+		return
+	}
+	for w := range c.watchers {
+		w.queue(events...)
+	}
+}
+
+// clusterEvents returns the events describing the change of the cluster from the previous to the current state.
+// The previous cluster is nil if the cluster was added, and the current cluster is nil if the cluster was removed.
+func clusterEvents(previous, current *CachedToolchainCluster) []ClusterEvent {
+	switch {
+	case previous == nil && current == nil:
+		return nil
+	case previous == nil:
+		return []ClusterEvent{{Type: ClusterAdded, Name: current.Name, Cluster: current}}
+	case current == nil:
+		return []ClusterEvent{{Type: ClusterRemoved, Name: previous.Name, Cluster: previous}}
+	}
+	var events []ClusterEvent
+	if isReady(previous) != isReady(current) {
+		events = append(events, ClusterEvent{Type: ClusterReadyChanged, Name: current.Name, Cluster: current, Previous: previous})
+	}
+	if !reflect.DeepEqual(roleLabels(previous), roleLabels(current)) {
+		events = append(events, ClusterEvent{Type: ClusterRolesChanged, Name: current.Name, Cluster: current, Previous: previous})
+	}
+	return events
+}
+
+func isReady(cluster *CachedToolchainCluster) bool {
+	return cluster.ClusterStatus != nil && IsReady(cluster.ClusterStatus)
+}
+
+// roleLabels returns the cluster-role labels of the given cluster (see RoleLabel)
+func roleLabels(cluster *CachedToolchainCluster) map[string]string {
+	roles := map[string]string{}
+	if cluster.Config == nil {
+		return roles
+	}
+	for key, value := range cluster.Labels {
+		if strings.HasPrefix(key, RoleLabel("")) {
+			roles[key] = value
+		}
+	}
+	return roles
+}
+
+// clusterWatcher queues the events for a single watcher and passes them to its channel
+type clusterWatcher struct {
+	sync.Mutex
+	pending []ClusterEvent
+	events  chan ClusterEvent
+	notify  chan struct{}
+}
+
+func (w *clusterWatcher) queue(events ...ClusterEvent) {
+	w.Lock()
+	w.pending = append(w.pending, events...)
+	w.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run passes the queued events to the channel until the given context is done
+func (w *clusterWatcher) run(ctx context.Context) {
+	for {
+		w.Lock()
+		pending := w.pending
+		w.pending = nil
+		w.Unlock()
+		for _, event := range pending {
+			select {
+			case w.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ClusterEventMapper returns the reconcile requests which should be enqueued for the given cluster event
+type ClusterEventMapper func(ctx context.Context, event ClusterEvent) []reconcile.Request
+
+// NewClusterEventSource returns a controller-runtime source which enqueues the requests returned by the given mapper
+// for the events of the clusters in the given cache. If no event types are given, then all the events are mapped.
+//
+// For example, the Spaces targeted at a cluster can be enqueued when the cluster becomes Ready:
+//
+//	src := cluster.NewClusterEventSource(cache, mapReadyClusterToSpaces, cluster.ClusterReadyChanged)
+//	err := ctrl.NewControllerManagedBy(mgr).For(&toolchainv1alpha1.Space{}).WatchesRawSource(src).Complete(r)
+func NewClusterEventSource(cache *ClusterCache, mapper ClusterEventMapper, types ...ClusterEventType) source.Source {
+	return &clusterEventSource{
+		cache:  cache,
+		mapper: mapper,
+		types:  types,
+	}
+}
+
+type clusterEventSource struct {
+	cache  *ClusterCache
+	mapper ClusterEventMapper
+	types  []ClusterEventType
+}
+
+var _ source.Source = &clusterEventSource{}
+
+// Start starts watching the cache and enqueueing the requests until the given context is done. It doesn't block.
+func (s *clusterEventSource) Start(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+	events := s.cache.Watch(ctx)
+	go func() {
+		for event := range events {
+			if !s.accepts(event) {
+				continue
+			}
+			for _, req := range s.mapper(ctx, event) {
+				queue.Add(req)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *clusterEventSource) accepts(event ClusterEvent) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *clusterEventSource) String() string {
+	return "cluster cache events"
+}
@@ -0,0 +1,184 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestWatch(t *testing.T) {
+	// given
+	cache := NewClusterCache()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := cache.Watch(ctx)
+
+	t.Run("cluster is added", func(t *testing.T) {
+		// given
+		member := newTestCachedToolchainCluster(t, "member", notReady)
+
+		// when
+		cache.Add(member)
+
+		// then
+		assertClusterEvent(t, events, ClusterEvent{Type: ClusterAdded, Name: "member", Cluster: member})
+	})
+
+	t.Run("cluster becomes ready", func(t *testing.T) {
+		// given
+		previous, _ := cache.Get("member")
+		member := newTestCachedToolchainCluster(t, "member", ready)
+
+		// when
+		cache.Add(member)
+
+		// then
+		assertClusterEvent(t, events, ClusterEvent{Type: ClusterReadyChanged, Name: "member", Cluster: member, Previous: previous})
+	})
+
+	t.Run("no event when neither ready state nor roles change", func(t *testing.T) {
+		// given
+		member := newTestCachedToolchainCluster(t, "member", ready)
+		member.Labels = map[string]string{"other": "label"}
+
+		// when
+		cache.Add(member)
+
+		// then
+		assertNoClusterEvent(t, events)
+	})
+
+	t.Run("cluster role is added", func(t *testing.T) {
+		// given
+		previous, _ := cache.Get("member")
+		member := newTestCachedToolchainCluster(t, "member", ready)
+		member.Labels = map[string]string{RoleLabel(Tenant): ""}
+
+		// when
+		cache.Add(member)
+
+		// then
+		assertClusterEvent(t, events, ClusterEvent{Type: ClusterRolesChanged, Name: "member", Cluster: member, Previous: previous})
+	})
+
+	t.Run("cluster becomes not ready and loses the role", func(t *testing.T) {
+		// given
+		previous, _ := cache.Get("member")
+		member := newTestCachedToolchainCluster(t, "member", notReady)
+
+		// when
+		cache.Add(member)
+
+		// then
+		assertClusterEvent(t, events, ClusterEvent{Type: ClusterReadyChanged, Name: "member", Cluster: member, Previous: previous})
+		assertClusterEvent(t, events, ClusterEvent{Type: ClusterRolesChanged, Name: "member", Cluster: member, Previous: previous})
+	})
+
+	t.Run("cluster is removed", func(t *testing.T) {
+		// given
+		member, _ := cache.Get("member")
+
+		// when
+		cache.Delete("member")
+		cache.Delete("unknown")
+
+		// then
+		assertClusterEvent(t, events, ClusterEvent{Type: ClusterRemoved, Name: "member", Cluster: member})
+		assertNoClusterEvent(t, events)
+	})
+
+	t.Run("channel is closed when the context is done", func(t *testing.T) {
+		// when
+		cancel()
+
+		// then
+		require.Eventually(t, func() bool {
+			_, open := <-events
+			return !open
+		}, time.Second, time.Millisecond)
+		require.Eventually(t, func() bool {
+			cache.RLock()
+			defer cache.RUnlock()
+			return len(cache.watchers) == 0
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestWatchDoesNotBlockCache(t *testing.T) {
+	// given
+	cache := NewClusterCache()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slow := cache.Watch(ctx)
+	events := cache.Watch(ctx)
+
+	// when
+	for _, name := range []string{"member-1", "member-2", "member-3"} {
+		cache.Add(newTestCachedToolchainCluster(t, name))
+	}
+
+	// then
+	for _, name := range []string{"member-1", "member-2", "member-3"} {
+		event := receiveClusterEvent(t, events)
+		assert.Equal(t, ClusterAdded, event.Type)
+		assert.Equal(t, name, event.Name)
+	}
+	// the events are kept for the slow watcher
+	for _, name := range []string{"member-1", "member-2", "member-3"} {
+		assert.Equal(t, name, receiveClusterEvent(t, slow).Name)
+	}
+}
+
+func TestClusterEventSource(t *testing.T) {
+	// given
+	cache := NewClusterCache()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	src := NewClusterEventSource(cache, func(_ context.Context, event ClusterEvent) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "spaces", Name: "space-in-" + event.Name}}}
+	}, ClusterReadyChanged)
+
+	// when
+	err := src.Start(ctx, queue)
+	require.NoError(t, err)
+	cache.Add(newTestCachedToolchainCluster(t, "member-1", notReady))
+	cache.Add(newTestCachedToolchainCluster(t, "member-2", notReady))
+	cache.Add(newTestCachedToolchainCluster(t, "member-2", ready))
+
+	// then
+	require.Eventually(t, func() bool {
+		return queue.Len() == 1
+	}, time.Second, time.Millisecond)
+	req, _ := queue.Get()
+	assert.Equal(t, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "spaces", Name: "space-in-member-2"}}, req)
+}
+
+func receiveClusterEvent(t *testing.T, events <-chan ClusterEvent) ClusterEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		require.Fail(t, "no cluster event received")
+		return ClusterEvent{}
+	}
+}
+
+func assertClusterEvent(t *testing.T, events <-chan ClusterEvent, expected ClusterEvent) {
+	assert.Equal(t, expected, receiveClusterEvent(t, events))
+}
+
+func assertNoClusterEvent(t *testing.T, events <-chan ClusterEvent) {
+	select {
+	case event := <-events:
+		assert.Fail(t, "unexpected cluster event", "%+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
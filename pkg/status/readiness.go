@@ -0,0 +1,332 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ReadinessStatus is the computed readiness of an object
+type ReadinessStatus string
+
+const (
+	// InProgress the object is not ready yet, but it is expected to become ready eventually
+	InProgress ReadinessStatus = "InProgress"
+	// Current the object is ready, ie. the actual state matches the desired state
+	Current ReadinessStatus = "Current"
+	// Failed the object failed to become ready and is not expected to recover without an intervention
+	Failed ReadinessStatus = "Failed"
+)
+
+// Readiness is the result of the readiness evaluation of an object
+type Readiness struct {
+	Status  ReadinessStatus
+	Message string
+}
+
+func current() Readiness {
+	return Readiness{Status: Current}
+}
+
+func inProgress(format string, args ...interface{}) Readiness {
+	return Readiness{Status: InProgress, Message: fmt.Sprintf(format, args...)}
+}
+
+func failed(format string, args ...interface{}) Readiness {
+	return Readiness{Status: Failed, Message: fmt.Sprintf(format, args...)}
+}
+
+// EvaluateReadiness computes the readiness of the given object from its content. It understands the status of Deployments,
+// StatefulSets, DaemonSets, ReplicaSets, Pods, Jobs, Namespaces, PersistentVolumeClaims and Services of the LoadBalancer type,
+// as well as of the toolchain resources using the Ready condition. Any other object without a Ready condition is considered
+// to be Current as soon as its status reflects the latest generation. The given scheme is used to determine the kind of typed objects.
+func EvaluateReadiness(obj client.Object, scheme *runtime.Scheme) (Readiness, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return Readiness{}, fmt.Errorf("unable to determine the kind of the object '%s': %w", obj.GetName(), err)
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return Readiness{}, fmt.Errorf("unable to convert the object '%s' to unstructured content: %w", obj.GetName(), err)
+	}
+	u := &unstructured.Unstructured{Object: content}
+
+	if u.GetDeletionTimestamp() != nil {
+		return inProgress("the object is being deleted"), nil
+	}
+	observedGeneration, found, err := unstructured.NestedInt64(u.Object, "status", "observedGeneration")
+	if err != nil {
+		return Readiness{}, err
+	}
+	if found && observedGeneration < u.GetGeneration() {
+		return inProgress("the latest generation %d has not been observed yet, observed generation is %d", u.GetGeneration(), observedGeneration), nil
+	}
+
+	gk := gvk.GroupKind()
+	switch gk.Group {
+	case "apps":
+		switch gk.Kind {
+		case "Deployment":
+			return deploymentReadiness(u)
+		case "StatefulSet":
+			return statefulSetReadiness(u)
+		case "DaemonSet":
+			return daemonSetReadiness(u)
+		case "ReplicaSet":
+			return replicaSetReadiness(u)
+		}
+	case "batch":
+		if gk.Kind == "Job" {
+			return jobReadiness(u)
+		}
+	case "":
+		switch gk.Kind {
+		case "Namespace":
+			return namespaceReadiness(u)
+		case "PersistentVolumeClaim":
+			return pvcReadiness(u)
+		case "Pod":
+			return podReadiness(u)
+		case "Service":
+			return serviceReadiness(u)
+		}
+	}
+	return conditionReadiness(u, gk.Group == toolchainv1alpha1.GroupVersion.Group)
+}
+
+func deploymentReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	conditions, err := getConditions(u)
+	if err != nil {
+		return Readiness{}, err
+	}
+	if progressing, found := condition.FindConditionByType(conditions, "Progressing"); found && progressing.Reason == "ProgressDeadlineExceeded" {
+		return failed("the deployment exceeded its progress deadline: %s", progressing.Message), nil
+	}
+	replicas := desiredReplicas(u)
+	for _, field := range []string{"updatedReplicas", "readyReplicas", "availableReplicas"} {
+		if actual := statusInt(u, field); actual < replicas {
+			return inProgress("%s: %d of %d", field, actual, replicas), nil
+		}
+	}
+	if actual := statusInt(u, "replicas"); actual > replicas {
+		return inProgress("old replicas are pending termination: %d of %d", actual, replicas), nil
+	}
+	return current(), nil
+}
+
+func statefulSetReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	replicas := desiredReplicas(u)
+	for _, field := range []string{"readyReplicas", "currentReplicas"} {
+		if actual := statusInt(u, field); actual < replicas {
+			return inProgress("%s: %d of %d", field, actual, replicas), nil
+		}
+	}
+	currentRevision, _, _ := unstructured.NestedString(u.Object, "status", "currentRevision")
+	updateRevision, _, _ := unstructured.NestedString(u.Object, "status", "updateRevision")
+	if currentRevision != updateRevision {
+		return inProgress("the rollout of the revision %s is in progress", updateRevision), nil
+	}
+	return current(), nil
+}
+
+func daemonSetReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	desired := statusInt(u, "desiredNumberScheduled")
+	for _, field := range []string{"updatedNumberScheduled", "numberAvailable", "numberReady"} {
+		if actual := statusInt(u, field); actual < desired {
+			return inProgress("%s: %d of %d", field, actual, desired), nil
+		}
+	}
+	return current(), nil
+}
+
+func replicaSetReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	replicas := desiredReplicas(u)
+	for _, field := range []string{"readyReplicas", "availableReplicas"} {
+		if actual := statusInt(u, field); actual < replicas {
+			return inProgress("%s: %d of %d", field, actual, replicas), nil
+		}
+	}
+	return current(), nil
+}
+
+func jobReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	conditions, err := getConditions(u)
+	if err != nil {
+		return Readiness{}, err
+	}
+	if c, found := condition.FindConditionByType(conditions, "Failed"); found && c.Status == corev1.ConditionTrue {
+		return failed("the job failed: %s", c.Message), nil
+	}
+	if condition.IsTrue(conditions, "Complete") {
+		return current(), nil
+	}
+	return inProgress("the job is not complete yet"), nil
+}
+
+func namespaceReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+	if phase == string(corev1.NamespaceTerminating) {
+		return inProgress("the namespace is terminating"), nil
+	}
+	return current(), nil
+}
+
+func pvcReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+	switch corev1.PersistentVolumeClaimPhase(phase) {
+	case corev1.ClaimBound:
+		return current(), nil
+	case corev1.ClaimLost:
+		return failed("the persistent volume claim lost its volume"), nil
+	default:
+		return inProgress("the persistent volume claim is not bound yet"), nil
+	}
+}
+
+func podReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+	switch corev1.PodPhase(phase) {
+	case corev1.PodSucceeded:
+		return current(), nil
+	case corev1.PodFailed:
+		return failed("the pod failed"), nil
+	case corev1.PodRunning:
+		conditions, err := getConditions(u)
+		if err != nil {
+			return Readiness{}, err
+		}
+		if condition.IsTrue(conditions, toolchainv1alpha1.ConditionType(corev1.PodReady)) {
+			return current(), nil
+		}
+		return inProgress("the pod is running but it's not ready"), nil
+	default:
+		return inProgress("the pod is in the '%s' phase", phase), nil
+	}
+}
+
+func serviceReadiness(u *unstructured.Unstructured) (Readiness, error) {
+	serviceType, _, _ := unstructured.NestedString(u.Object, "spec", "type")
+	if serviceType != string(corev1.ServiceTypeLoadBalancer) {
+		return current(), nil
+	}
+	ingress, _, _ := unstructured.NestedSlice(u.Object, "status", "loadBalancer", "ingress")
+	if len(ingress) == 0 {
+		return inProgress("the load balancer is not provisioned yet"), nil
+	}
+	return current(), nil
+}
+
+// conditionReadiness evaluates the Ready condition. If the Ready condition is missing, then the object is
+// Current unless the Ready condition is required, which is the case for the toolchain resources.
+func conditionReadiness(u *unstructured.Unstructured, readyRequired bool) (Readiness, error) {
+	conditions, err := getConditions(u)
+	if err != nil {
+		return Readiness{}, err
+	}
+	ready, found := condition.FindConditionByType(conditions, toolchainv1alpha1.ConditionReady)
+	switch {
+	case !found && readyRequired:
+		return inProgress("the Ready condition is not set yet"), nil
+	case !found:
+		return current(), nil
+	case ready.Status == corev1.ConditionTrue:
+		return current(), nil
+	case ready.Status == corev1.ConditionFalse && isFailureReason(ready.Reason):
+		return failed("%s: %s", ready.Reason, ready.Message), nil
+	default:
+		return inProgress("%s: %s", ready.Reason, ready.Message), nil
+	}
+}
+
+// isFailureReason checks if the reason of the Ready condition signals an error (eg. `UnableToProvision` or `UpdateFailed`)
+func isFailureReason(reason string) bool {
+	return strings.HasPrefix(reason, "UnableTo") || strings.Contains(reason, "Failed") || strings.Contains(reason, "Error")
+}
+
+func getConditions(u *unstructured.Unstructured) ([]toolchainv1alpha1.Condition, error) {
+	rawConditions, found, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil || !found {
+		return nil, err
+	}
+	conditions := make([]toolchainv1alpha1.Condition, 0, len(rawConditions))
+	for _, rawCondition := range rawConditions {
+		rawMap, ok := rawCondition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		c := toolchainv1alpha1.Condition{}
+		c.Type = toolchainv1alpha1.ConditionType(stringValue(rawMap, "type"))
+		c.Status = corev1.ConditionStatus(stringValue(rawMap, "status"))
+		c.Reason = stringValue(rawMap, "reason")
+		c.Message = stringValue(rawMap, "message")
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+func stringValue(m map[string]interface{}, key string) string {
+	value, _ := m[key].(string)
+	return value
+}
+
+func desiredReplicas(u *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
+
+func statusInt(u *unstructured.Unstructured, field string) int64 {
+	value, _, _ := unstructured.NestedInt64(u.Object, "status", field)
+	return value
+}
+
+// WaitForReady polls the given objects from the cluster in the given interval until all of them are Current. It returns an error
+// as soon as any of the objects is Failed or cannot be read, or when the objects don't become Current within the given timeout.
+// An object which doesn't exist (yet) is considered as InProgress. The supplied objects are updated with the latest content
+// read from the cluster.
+func WaitForReady(ctx context.Context, cl client.Client, interval, timeout time.Duration, objects ...client.Object) error {
+	var notReady string
+	err := wait.PollUntilContextTimeout(ctx, interval, timeout, true, func(ctx context.Context) (bool, error) {
+		for _, obj := range objects {
+			if err := cl.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				if apierrors.IsNotFound(err) {
+					// the object may not be created yet, eg. by a controller
+					notReady = fmt.Sprintf("the object '%s' in namespace '%s' is not ready: not found", obj.GetName(), obj.GetNamespace())
+					return false, nil
+				}
+				return false, fmt.Errorf("unable to get the object '%s' in namespace '%s': %w", obj.GetName(), obj.GetNamespace(), err)
+			}
+			readiness, err := EvaluateReadiness(obj, cl.Scheme())
+			if err != nil {
+				return false, err
+			}
+			switch readiness.Status {
+			case Failed:
+				return false, fmt.Errorf("the object '%s' in namespace '%s' failed to become ready: %s", obj.GetName(), obj.GetNamespace(), readiness.Message)
+			case InProgress:
+				notReady = fmt.Sprintf("the object '%s' in namespace '%s' is not ready: %s", obj.GetName(), obj.GetNamespace(), readiness.Message)
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if wait.Interrupted(err) && notReady != "" {
+		return fmt.Errorf("%s: %w", notReady, err)
+	}
+	return err
+}
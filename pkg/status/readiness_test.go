@@ -0,0 +1,380 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEvaluateReadiness(t *testing.T) {
+	s := scheme.Scheme
+	require.NoError(t, toolchainv1alpha1.AddToScheme(s))
+
+	t.Run("deployment", func(t *testing.T) {
+
+		t.Run("current", func(t *testing.T) {
+			// given
+			deployment := newDeployment(3, 3)
+
+			// when
+			readiness, err := EvaluateReadiness(deployment, s)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, Current, readiness.Status)
+		})
+
+		t.Run("in progress when replicas are not ready", func(t *testing.T) {
+			// given
+			deployment := newDeployment(3, 1)
+
+			// when
+			readiness, err := EvaluateReadiness(deployment, s)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, InProgress, readiness.Status)
+			assert.Equal(t, "readyReplicas: 1 of 3", readiness.Message)
+		})
+
+		t.Run("in progress when generation is not observed", func(t *testing.T) {
+			// given
+			deployment := newDeployment(3, 3)
+			deployment.Generation = 2
+
+			// when
+			readiness, err := EvaluateReadiness(deployment, s)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, InProgress, readiness.Status)
+			assert.Contains(t, readiness.Message, "the latest generation 2 has not been observed yet")
+		})
+
+		t.Run("failed when progress deadline is exceeded", func(t *testing.T) {
+			// given
+			deployment := newDeployment(3, 1)
+			deployment.Status.Conditions = []appsv1.DeploymentCondition{{
+				Type:    appsv1.DeploymentProgressing,
+				Status:  corev1.ConditionFalse,
+				Reason:  "ProgressDeadlineExceeded",
+				Message: "too slow",
+			}}
+
+			// when
+			readiness, err := EvaluateReadiness(deployment, s)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, Failed, readiness.Status)
+			assert.Equal(t, "the deployment exceeded its progress deadline: too slow", readiness.Message)
+		})
+	})
+
+	t.Run("namespace", func(t *testing.T) {
+		for phase, expected := range map[corev1.NamespacePhase]ReadinessStatus{
+			corev1.NamespaceActive:      Current,
+			corev1.NamespaceTerminating: InProgress,
+		} {
+			t.Run(string(phase), func(t *testing.T) {
+				// given
+				ns := &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{Name: "test"},
+					Status:     corev1.NamespaceStatus{Phase: phase},
+				}
+
+				// when
+				readiness, err := EvaluateReadiness(ns, s)
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, expected, readiness.Status)
+			})
+		}
+	})
+
+	t.Run("pvc", func(t *testing.T) {
+		for phase, expected := range map[corev1.PersistentVolumeClaimPhase]ReadinessStatus{
+			corev1.ClaimBound:   Current,
+			corev1.ClaimPending: InProgress,
+			corev1.ClaimLost:    Failed,
+		} {
+			t.Run(string(phase), func(t *testing.T) {
+				// given
+				pvc := &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: test.HostOperatorNs},
+					Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+				}
+
+				// when
+				readiness, err := EvaluateReadiness(pvc, s)
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, expected, readiness.Status)
+			})
+		}
+	})
+
+	t.Run("job", func(t *testing.T) {
+		for conditionType, expected := range map[batchv1.JobConditionType]ReadinessStatus{
+			batchv1.JobComplete:  Current,
+			batchv1.JobFailed:    Failed,
+			batchv1.JobSuspended: InProgress,
+		} {
+			t.Run(string(conditionType), func(t *testing.T) {
+				// given
+				job := &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: test.HostOperatorNs},
+					Status: batchv1.JobStatus{
+						Conditions: []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}},
+					},
+				}
+
+				// when
+				readiness, err := EvaluateReadiness(job, s)
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, expected, readiness.Status)
+			})
+		}
+	})
+
+	t.Run("toolchain resource", func(t *testing.T) {
+
+		t.Run("current when ready", func(t *testing.T) {
+			// given
+			mur := newMasterUserRecord(toolchainv1alpha1.Condition{
+				Type:   toolchainv1alpha1.ConditionReady,
+				Status: corev1.ConditionTrue,
+			})
+
+			// when
+			readiness, err := EvaluateReadiness(mur, s)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, Current, readiness.Status)
+		})
+
+		t.Run("in progress when provisioning", func(t *testing.T) {
+			// given
+			mur := newMasterUserRecord(toolchainv1alpha1.Condition{
+				Type:   toolchainv1alpha1.ConditionReady,
+				Status: corev1.ConditionFalse,
+				Reason: "Provisioning",
+			})
+
+			// when
+			readiness, err := EvaluateReadiness(mur, s)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, InProgress, readiness.Status)
+		})
+
+		t.Run("in progress without ready condition", func(t *testing.T) {
+			// given
+			mur := newMasterUserRecord()
+
+			// when
+			readiness, err := EvaluateReadiness(mur, s)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, InProgress, readiness.Status)
+			assert.Equal(t, "the Ready condition is not set yet", readiness.Message)
+		})
+
+		t.Run("failed when unable to provision", func(t *testing.T) {
+			// given
+			mur := newMasterUserRecord(toolchainv1alpha1.Condition{
+				Type:    toolchainv1alpha1.ConditionReady,
+				Status:  corev1.ConditionFalse,
+				Reason:  "UnableToProvision",
+				Message: "boom",
+			})
+
+			// when
+			readiness, err := EvaluateReadiness(mur, s)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, Failed, readiness.Status)
+			assert.Equal(t, "UnableToProvision: boom", readiness.Message)
+		})
+	})
+
+	t.Run("other kinds are current", func(t *testing.T) {
+		// given
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: test.HostOperatorNs}}
+
+		// when
+		readiness, err := EvaluateReadiness(cm, s)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, Current, readiness.Status)
+	})
+}
+
+func TestWaitForReady(t *testing.T) {
+
+	t.Run("all objects ready", func(t *testing.T) {
+		// given
+		deployment := newDeployment(1, 1)
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive}}
+		cl := test.NewFakeClient(t, deployment, ns)
+
+		// when
+		err := WaitForReady(context.TODO(), cl, 10*time.Millisecond, time.Second, newObjectWithKey(deployment), newObjectWithKey(ns))
+
+		// then
+		require.NoError(t, err)
+	})
+
+	t.Run("becomes ready", func(t *testing.T) {
+		// given
+		deployment := newDeployment(1, 0)
+		cl := test.NewFakeClient(t, deployment)
+		// the deployment becomes ready during the third poll
+		polls := 0
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			if polls++; polls == 3 {
+				ready := newDeployment(1, 1)
+				ready.ResourceVersion = ""
+				require.NoError(t, cl.Status().Update(ctx, ready))
+			}
+			return cl.Client.Get(ctx, key, obj, opts...)
+		}
+
+		// when
+		err := WaitForReady(context.TODO(), cl, 10*time.Millisecond, 5*time.Second, newObjectWithKey(deployment))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, polls)
+	})
+
+	t.Run("becomes ready after it's created", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+		// the deployment is created during the second poll
+		polls := 0
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			if polls++; polls == 2 {
+				require.NoError(t, cl.Client.Create(ctx, newDeployment(1, 1)))
+			}
+			return cl.Client.Get(ctx, key, obj, opts...)
+		}
+
+		// when
+		err := WaitForReady(context.TODO(), cl, 10*time.Millisecond, 5*time.Second, newDeployment(1, 1))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 2, polls)
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		// given
+		deployment := newDeployment(2, 1)
+		cl := test.NewFakeClient(t, deployment)
+
+		// when
+		err := WaitForReady(context.TODO(), cl, 10*time.Millisecond, 100*time.Millisecond, newObjectWithKey(deployment))
+
+		// then
+		require.ErrorContains(t, err, "the object 'test-deployment' in namespace 'toolchain-host-operator' is not ready: readyReplicas: 1 of 2")
+	})
+
+	t.Run("fails immediately", func(t *testing.T) {
+		// given
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: test.HostOperatorNs},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimLost},
+		}
+		cl := test.NewFakeClient(t, pvc)
+
+		// when
+		err := WaitForReady(context.TODO(), cl, 10*time.Millisecond, time.Minute, newObjectWithKey(pvc))
+
+		// then
+		require.EqualError(t, err, "the object 'test' in namespace 'toolchain-host-operator' failed to become ready: the persistent volume claim lost its volume")
+	})
+
+	t.Run("object not found", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t)
+
+		// when
+		err := WaitForReady(context.TODO(), cl, 10*time.Millisecond, 100*time.Millisecond, newDeployment(1, 1))
+
+		// then
+		require.ErrorContains(t, err, "the object 'test-deployment' in namespace 'toolchain-host-operator' is not ready: not found")
+	})
+
+	t.Run("get error", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, newDeployment(1, 1))
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			return fmt.Errorf("mock error")
+		}
+
+		// when
+		err := WaitForReady(context.TODO(), cl, 10*time.Millisecond, time.Minute, newDeployment(1, 1))
+
+		// then
+		require.EqualError(t, err, "unable to get the object 'test-deployment' in namespace 'toolchain-host-operator': mock error")
+	})
+}
+
+func newDeployment(replicas, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-deployment",
+			Namespace:  test.HostOperatorNs,
+			Generation: 1,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           replicas,
+			UpdatedReplicas:    replicas,
+			ReadyReplicas:      ready,
+			AvailableReplicas:  ready,
+		},
+	}
+}
+
+func newMasterUserRecord(conditions ...toolchainv1alpha1.Condition) *toolchainv1alpha1.MasterUserRecord {
+	return &toolchainv1alpha1.MasterUserRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "john", Namespace: test.HostOperatorNs},
+		Status:     toolchainv1alpha1.MasterUserRecordStatus{Conditions: conditions},
+	}
+}
+
+// newObjectWithKey returns an empty object of the same type with the same name and namespace as the given object
+func newObjectWithKey[T runtimeclient.Object](obj T) T {
+	empty := obj.DeepCopyObject().(T)
+	empty.SetResourceVersion("")
+	return empty
+}
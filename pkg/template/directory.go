@@ -0,0 +1,254 @@
+package template
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// ociLayoutFile is the file which marks the root of an OCI image layout
+	ociLayoutFile = "oci-layout"
+	// ociIndexFile is the entrypoint of an OCI image layout
+	ociIndexFile = "index.json"
+
+	ociImageIndexMediaType      = "application/vnd.oci.image.index.v1+json"
+	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	// whiteoutPrefix is the prefix of the files which remove the files of the previous layers of an OCI image
+	whiteoutPrefix = ".wh."
+	// opaqueWhiteout is the file which removes all the files of the previous layers in its directory
+	opaqueWhiteout = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// LoadObjectsFromDirectory loads all the kubernetes objects from a directory on disk and returns a list of Unstructured objects that can be applied in the cluster.
+// The directory is either:
+//   - an OCI image layout (i.e. it contains the `oci-layout` file), in which case the templates are the files of the layers of the first image of the index
+//   - a directory containing templates and tarballs of templates (`.tar`, `.tar.gz` or `.tgz` files), e.g. a mounted ConfigMap
+//
+// The hidden files and directories are ignored.
+func LoadObjectsFromDirectory(dir string, variables *Variables) ([]*unstructured.Unstructured, error) {
+	fsys := os.DirFS(dir)
+	var files []templateFile
+	if _, err := fs.Stat(fsys, ociLayoutFile); err == nil {
+		if files, err = readOCILayout(fsys); err != nil {
+			return nil, errors.Wrapf(err, "unable to read the OCI image layout in %s", dir)
+		}
+	} else {
+		if files, err = readDirectory(fsys); err != nil {
+			return nil, errors.Wrapf(err, "unable to read the templates in %s", dir)
+		}
+	}
+	return loadObjects(files, variables)
+}
+
+// readDirectory returns the templates of the given directory, including the templates of the tarballs it contains
+func readDirectory(fsys fs.FS) ([]templateFile, error) {
+	var files []templateFile
+	err := fs.WalkDir(fsys, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath != "." && strings.HasPrefix(d.Name(), ".") {
+			// also skips the `..data` symlink and the timestamped directory of a mounted ConfigMap
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		if !isTarball(filePath) {
+			files = append(files, templateFile{name: filePath, content: content})
+			return nil
+		}
+		entries, err := readTarball(bytes.NewReader(content))
+		if err != nil {
+			return errors.Wrapf(err, "unable to read the tarball %s", filePath)
+		}
+		for _, entry := range entries {
+			if hidden(entry.name) {
+				continue
+			}
+			files = append(files, templateFile{name: filePath + "/" + entry.name, content: entry.content})
+		}
+		return nil
+	})
+	return files, err
+}
+
+func isTarball(filePath string) bool {
+	return strings.HasSuffix(filePath, ".tar") || strings.HasSuffix(filePath, ".tar.gz") || strings.HasSuffix(filePath, ".tgz")
+}
+
+// hidden returns true if the file or any of its parent directories is hidden
+func hidden(filePath string) bool {
+	for _, element := range strings.Split(filePath, "/") {
+		if strings.HasPrefix(element, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// readTarball returns the regular files of the given (optionally gzipped) tarball in the order of the archive
+func readTarball(r io.Reader) ([]templateFile, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gzr.Close()
+		r = gzr
+	} else {
+		r = br
+	}
+	var files []templateFile
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return files, nil
+			}
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, templateFile{name: path.Clean(strings.TrimPrefix(header.Name, "./")), content: content})
+	}
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// ociManifest contains the fields of both an image index and an image manifest
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+func (m ociManifest) isIndex() bool {
+	return m.MediaType == ociImageIndexMediaType || m.MediaType == dockerManifestListMediaType || (m.MediaType == "" && len(m.Manifests) > 0)
+}
+
+// readOCILayout returns the files of the first image of the given OCI image layout, after applying all its layers
+func readOCILayout(fsys fs.FS) ([]templateFile, error) {
+	content, err := fs.ReadFile(fsys, ociIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	manifest := ociManifest{}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", ociIndexFile)
+	}
+	// resolve the (nested) indexes until the first image manifest
+	for manifest.isIndex() {
+		if len(manifest.Manifests) == 0 {
+			return nil, fmt.Errorf("the image index does not contain any manifest")
+		}
+		descriptor := manifest.Manifests[0]
+		content, err := readBlob(fsys, descriptor)
+		if err != nil {
+			return nil, err
+		}
+		manifest = ociManifest{}
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return nil, errors.Wrapf(err, "invalid manifest %s", descriptor.Digest)
+		}
+	}
+
+	layerFiles := map[string][]byte{}
+	for _, layer := range manifest.Layers {
+		content, err := readBlob(fsys, layer)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := readTarball(bytes.NewReader(content))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the layer %s", layer.Digest)
+		}
+		// the whiteouts only remove the files of the previous layers
+		for _, entry := range entries {
+			dir, name := path.Split(entry.name)
+			if name == opaqueWhiteout {
+				removeFiles(layerFiles, dir)
+			} else if strings.HasPrefix(name, whiteoutPrefix) {
+				removeFiles(layerFiles, dir+strings.TrimPrefix(name, whiteoutPrefix))
+			}
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(path.Base(entry.name), whiteoutPrefix) {
+				layerFiles[entry.name] = entry.content
+			}
+		}
+	}
+
+	names := make([]string, 0, len(layerFiles))
+	for name := range layerFiles {
+		if !hidden(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	files := make([]templateFile, 0, len(names))
+	for _, name := range names {
+		files = append(files, templateFile{name: name, content: layerFiles[name]})
+	}
+	return files, nil
+}
+
+// removeFiles removes the given file, or all the files of the given directory (if the path ends with a slash)
+func removeFiles(files map[string][]byte, filePath string) {
+	prefix := strings.TrimSuffix(filePath, "/") + "/"
+	for name := range files {
+		if name == filePath || strings.HasPrefix(name, prefix) || filePath == "" {
+			delete(files, name)
+		}
+	}
+}
+
+// readBlob reads the blob of the given descriptor and verifies its digest
+func readBlob(fsys fs.FS, descriptor ociDescriptor) ([]byte, error) {
+	algorithm, encoded, found := strings.Cut(descriptor.Digest, ":")
+	if !found || algorithm != "sha256" || encoded == "" || strings.Contains(encoded, "/") {
+		return nil, fmt.Errorf("unsupported digest '%s'", descriptor.Digest)
+	}
+	content, err := fs.ReadFile(fsys, path.Join("blobs", algorithm, encoded))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != encoded {
+		return nil, fmt.Errorf("the content of the blob %s does not match its digest", descriptor.Digest)
+	}
+	return content, nil
+}
@@ -0,0 +1,182 @@
+package template_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/template"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestLoadObjectsFromDirectory(t *testing.T) {
+	variables := &template.Variables{Namespace: test.MemberOperatorNs, ClusterName: "member-1"}
+
+	t.Run("loads objects from the files and tarballs of the directory", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		writeFile(t, dir, "a.yaml", configMapTemplate("a"))
+		writeFile(t, dir, "sub/b.yaml", configMapTemplate("b"))
+		writeFile(t, dir, "c.tar.gz", newTarball(t, true, map[string]string{"./c.yaml": configMapTemplate("c")}))
+		writeFile(t, dir, "d.tar", newTarball(t, false, map[string]string{"d.yaml": configMapTemplate("d")}))
+		// hidden files and directories (like the ones of a mounted ConfigMap) are ignored
+		writeFile(t, dir, ".hidden.yaml", configMapTemplate("hidden"))
+		writeFile(t, dir, "..2026_01_01/a.yaml", configMapTemplate("hidden"))
+
+		// when
+		objects, err := template.LoadObjectsFromDirectory(dir, variables)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"member-1-a", "member-1-c", "member-1-d", "member-1-b"}, names(objects))
+		assert.Equal(t, test.MemberOperatorNs, objects[0].GetNamespace())
+	})
+
+	t.Run("loads objects from an OCI image layout", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		writeOCILayout(t, dir,
+			map[string]string{
+				"templates/a.yaml": configMapTemplate("a"),
+				"templates/b.yaml": configMapTemplate("b"),
+				"other/c.yaml":     configMapTemplate("c"),
+			},
+			// the second layer removes the `b.yaml` file and the `other` directory, and adds a new file
+			map[string]string{
+				"templates/.wh.b.yaml":  "",
+				"other/.wh..wh..opq":    "",
+				"templates/d.yaml":      configMapTemplate("d"),
+				"templates/.hidden.yml": configMapTemplate("hidden"),
+			})
+
+		// when
+		objects, err := template.LoadObjectsFromDirectory(dir, variables)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"member-1-a", "member-1-d"}, names(objects))
+	})
+
+	t.Run("error - when the blob does not match its digest", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		layer := writeOCILayout(t, dir, map[string]string{"a.yaml": configMapTemplate("a")})
+		writeFile(t, dir, filepath.Join("blobs", "sha256", layer), "modified")
+
+		// when
+		_, err := template.LoadObjectsFromDirectory(dir, variables)
+
+		// then
+		require.ErrorContains(t, err, "does not match its digest")
+	})
+
+	t.Run("error - when the directory does not exist", func(t *testing.T) {
+		// when
+		_, err := template.LoadObjectsFromDirectory(filepath.Join(t.TempDir(), "missing"), variables)
+
+		// then
+		require.Error(t, err)
+	})
+
+	t.Run("error - when the tarball is invalid", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		writeFile(t, dir, "a.tgz", "not a tarball")
+
+		// when
+		_, err := template.LoadObjectsFromDirectory(dir, variables)
+
+		// then
+		require.ErrorContains(t, err, "unable to read the tarball a.tgz")
+	})
+}
+
+func configMapTemplate(name string) string {
+	return `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{.ClusterName}}-` + name + `
+  namespace: {{.Namespace}}
+`
+}
+
+func names(objects []*unstructured.Unstructured) []string {
+	result := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		result = append(result, obj.GetName())
+	}
+	return result
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func newTarball(t *testing.T, gzipped bool, files map[string]string) string {
+	buf := &bytes.Buffer{}
+	var gzw *gzip.Writer
+	tw := tar.NewWriter(buf)
+	if gzipped {
+		gzw = gzip.NewWriter(buf)
+		tw = tar.NewWriter(gzw)
+	}
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	if gzw != nil {
+		require.NoError(t, gzw.Close())
+	}
+	return buf.String()
+}
+
+// writeOCILayout writes an OCI image layout with an image containing the given layers in the given directory
+// and returns the digest of the first layer
+func writeOCILayout(t *testing.T, dir string, layers ...map[string]string) string {
+	writeBlob := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		digest := hex.EncodeToString(sum[:])
+		writeFile(t, dir, filepath.Join("blobs", "sha256", digest), content)
+		return digest
+	}
+	toJSON := func(obj interface{}) string {
+		content, err := json.Marshal(obj)
+		require.NoError(t, err)
+		return string(content)
+	}
+	var layerDescriptors []map[string]string
+	for _, layer := range layers {
+		layerDescriptors = append(layerDescriptors, map[string]string{
+			"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+			"digest":    "sha256:" + writeBlob(newTarball(t, true, layer)),
+		})
+	}
+	manifest := writeBlob(toJSON(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers":        layerDescriptors,
+	}))
+	writeFile(t, dir, "oci-layout", `{"imageLayoutVersion": "1.0.0"}`)
+	writeFile(t, dir, "index.json", toJSON(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.index.v1+json",
+		"manifests": []map[string]string{{
+			"mediaType": "application/vnd.oci.image.manifest.v1+json",
+			"digest":    "sha256:" + manifest,
+		}},
+	}))
+	return layerDescriptors[0]["digest"][len("sha256:"):]
+}
@@ -5,9 +5,12 @@ import (
 	"embed"
 	"io"
 	"io/fs"
+	"slices"
+	"sort"
 	"text/template"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -16,23 +19,92 @@ import (
 
 // Variables contains all the available variables that are supported by the templates
 type Variables struct {
+	// Namespace is the namespace of the operator
 	Namespace string
+	// ClusterName is the name of the cluster the objects are applied to
+	ClusterName string
+	// ClusterRoles are the roles of the cluster the objects are applied to (e.g. `tenant`)
+	ClusterRoles []string
+	// Environment is the environment the operator runs in (e.g. `prod`, `e2e-tests` or `dev`)
+	Environment string
+	// Config contains the values of the spec of the ToolchainConfig (or of the MemberOperatorConfig), keyed by their JSON names.
+	// Since the unset values are missing, the templates should access them with the `index` function or inside a `with` action,
+	// e.g. `{{with .Config.host}}{{.environment}}{{end}}`.
+	Config map[string]interface{}
+}
+
+// HasRole returns true if the cluster has the given role, e.g. `{{if .HasRole "tenant"}}`
+func (v *Variables) HasRole(role string) bool {
+	return slices.Contains(v.ClusterRoles, role)
+}
+
+// ConfigValues returns the values of the spec of the given configuration object (ToolchainConfig or MemberOperatorConfig)
+// so that they can be set in the Config variable
+func ConfigValues(config runtime.Object) (map[string]interface{}, error) {
+	values, err := runtime.DefaultUnstructuredConverter.ToUnstructured(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to convert the configuration")
+	}
+	spec, _, err := unstructured.NestedMap(values, "spec")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the spec of the configuration")
+	}
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+	return spec, nil
+}
+
+// templateFile is a template loaded from one of the supported sources
+type templateFile struct {
+	name    string
+	content []byte
 }
 
 // LoadObjectsFromEmbedFS loads all the kubernetes objects from an embedded filesystem and returns a list of Unstructured objects that can be applied in the cluster.
 // The function will return all the objects it finds starting from the root of the embedded filesystem.
 func LoadObjectsFromEmbedFS(efs *embed.FS, variables *Variables) ([]*unstructured.Unstructured, error) {
-	var objects []*unstructured.Unstructured
-	entries, err := getAllTemplateNames(efs)
+	return LoadObjectsFromFS(efs, variables)
+}
+
+// LoadObjectsFromFS loads all the kubernetes objects from a filesystem and returns a list of Unstructured objects that can be applied in the cluster.
+// The function will return all the objects it finds starting from the root of the filesystem.
+func LoadObjectsFromFS(fsys fs.FS, variables *Variables) ([]*unstructured.Unstructured, error) {
+	entries, err := getAllTemplateNames(fsys)
 	if err != nil {
-		return objects, err
+		return nil, err
 	}
+	files := make([]templateFile, 0, len(entries))
 	for _, templatePath := range entries {
-		templateContent, err := efs.ReadFile(templatePath)
+		templateContent, err := fs.ReadFile(fsys, templatePath)
 		if err != nil {
-			return objects, err
+			return nil, err
 		}
-		buf, err := replaceTemplateVariables(templatePath, templateContent, variables)
+		files = append(files, templateFile{name: templatePath, content: templateContent})
+	}
+	return loadObjects(files, variables)
+}
+
+// LoadObjectsFromConfigMap loads all the kubernetes objects from the data of the given ConfigMap and returns a list of Unstructured objects
+// that can be applied in the cluster. Every entry of the data is a template; the entries are processed in the order of their keys.
+func LoadObjectsFromConfigMap(cm *corev1.ConfigMap, variables *Variables) ([]*unstructured.Unstructured, error) {
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	files := make([]templateFile, 0, len(keys))
+	for _, key := range keys {
+		files = append(files, templateFile{name: cm.Namespace + "/" + cm.Name + "/" + key, content: []byte(cm.Data[key])})
+	}
+	return loadObjects(files, variables)
+}
+
+// loadObjects evaluates the given templates and decodes the kubernetes objects they contain
+func loadObjects(files []templateFile, variables *Variables) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	for _, file := range files {
+		buf, err := replaceTemplateVariables(file.name, file.content, variables)
 		if err != nil {
 			return objects, err
 		}
@@ -71,9 +143,12 @@ func replaceTemplateVariables(templateName string, templateContent []byte, varia
 	return buf, err
 }
 
-// getAllTemplateNames reads the embedded filesystem and returns a list with all the filenames
-func getAllTemplateNames(efs *embed.FS) (files []string, err error) {
-	err = fs.WalkDir(efs, ".", func(path string, d fs.DirEntry, err error) error {
+// getAllTemplateNames reads the filesystem and returns a list with all the filenames
+func getAllTemplateNames(fsys fs.FS) (files []string, err error) {
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 		if d.IsDir() {
 			return nil
 		}
@@ -6,9 +6,12 @@ import (
 
 	"github.com/codeready-toolchain/toolchain-common/pkg/template"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -53,6 +56,102 @@ func TestLoadObjectsFromEmbedFS(t *testing.T) {
 	})
 }
 
+// clusterInfoTemplate uses all the variables
+const clusterInfoTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{.ClusterName}}-info
+  namespace: {{.Namespace}}
+data:
+  environment: {{.Environment}}
+  tenant: "{{.HasRole "tenant"}}"
+  {{- with .Config.host}}
+  host-environment: {{.environment}}
+  {{- end}}
+`
+
+func TestLoadObjectsFromConfigMap(t *testing.T) {
+	// given
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "templates",
+			Namespace: test.MemberOperatorNs,
+		},
+		Data: map[string]string{
+			"2-cluster-info.yaml": clusterInfoTemplate,
+			"1-cluster-role.yaml": `kind: ClusterRole
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: member-toolchaincluster-cr
+`,
+		},
+	}
+
+	t.Run("loads objects from all the entries", func(t *testing.T) {
+		// when
+		objects, err := template.LoadObjectsFromConfigMap(cm, &template.Variables{
+			Namespace:    test.MemberOperatorNs,
+			ClusterName:  "member-1",
+			ClusterRoles: []string{"tenant"},
+			Environment:  "e2e-tests",
+			Config: map[string]interface{}{
+				"host": map[string]interface{}{"environment": "prod"},
+			},
+		})
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, objects, 2)
+		// the entries are sorted by their keys
+		assert.Equal(t, "member-toolchaincluster-cr", objects[0].GetName())
+		assert.Equal(t, "member-1-info", objects[1].GetName())
+		assert.Equal(t, test.MemberOperatorNs, objects[1].GetNamespace())
+		assert.Equal(t, map[string]interface{}{
+			"environment":      "e2e-tests",
+			"tenant":           "true",
+			"host-environment": "prod",
+		}, objects[1].Object["data"])
+	})
+
+	t.Run("error - when variables are not provided", func(t *testing.T) {
+		// when
+		_, err := template.LoadObjectsFromConfigMap(cm, nil)
+
+		// then
+		require.Error(t, err)
+	})
+}
+
+func TestVariables(t *testing.T) {
+	t.Run("has role", func(t *testing.T) {
+		variables := &template.Variables{ClusterRoles: []string{"tenant", "other"}}
+		assert.True(t, variables.HasRole("tenant"))
+		assert.False(t, variables.HasRole("unknown"))
+	})
+
+	t.Run("config values", func(t *testing.T) {
+		// given
+		config := testconfig.NewToolchainConfigObj(t, testconfig.Environment(testconfig.E2E))
+
+		// when
+		values, err := template.ConfigValues(config)
+
+		// then
+		require.NoError(t, err)
+		require.IsType(t, map[string]interface{}{}, values["host"])
+		assert.Equal(t, "e2e-tests", values["host"].(map[string]interface{})["environment"])
+	})
+
+	t.Run("unset config values are missing", func(t *testing.T) {
+		// when
+		values, err := template.ConfigValues(testconfig.NewMemberOperatorConfigObj())
+
+		// then
+		require.NoError(t, err)
+		assert.NotContains(t, values, "environment")
+	})
+}
+
 func checkExpectedObjects(t *testing.T, objects []*unstructured.Unstructured) {
 	sa := &v1.ServiceAccount{}
 	err := runtime.DefaultUnstructuredConverter.FromUnstructured(objects[0].Object, sa)
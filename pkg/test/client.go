@@ -7,10 +7,12 @@ import (
 	"reflect"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -137,9 +139,68 @@ func Update(ctx context.Context, cl *FakeClient, obj client.Object, opts ...clie
 		obj.SetGeneration(current.GetGeneration())
 	}
 
+	// like the API server, don't modify the object (and its resourceVersion) if the update doesn't change anything,
+	// but only if the update would not be rejected because of a stale resourceVersion
+	if obj.GetResourceVersion() != "" && obj.GetResourceVersion() != current.GetResourceVersion() {
+		return newConflictError(obj)
+	}
+	if noop, err := isNoopChange(current, obj); err != nil || noop {
+		if err != nil {
+			return err
+		}
+		return cl.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+	}
+
 	return cl.Client.Update(ctx, obj, opts...)
 }
 
+// isNoopChange returns true if the updated object is the same as the current one, ignoring the metadata managed by the server
+func isNoopChange(currentObj, updatedObj client.Object) (bool, error) {
+	currentMap, err := toComparableMap(currentObj)
+	if err != nil {
+		return false, err
+	}
+	updatedMap, err := toComparableMap(updatedObj)
+	if err != nil {
+		return false, err
+	}
+	if len(updatedObj.GetManagedFields()) > 0 && !reflect.DeepEqual(currentObj.GetManagedFields(), updatedObj.GetManagedFields()) {
+		return false, nil
+	}
+	return reflect.DeepEqual(currentMap, updatedMap), nil
+}
+
+func toComparableMap(obj runtime.Object) (map[string]interface{}, error) {
+	m, err := toMap(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(m, "kind")
+	delete(m, "apiVersion")
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		// the managed fields are compared separately, because they are not modified if they are not set in the updated object
+		for _, field := range []string{"resourceVersion", "uid", "creationTimestamp", "managedFields", "selfLink"} {
+			delete(metadata, field)
+		}
+	}
+	// the empty structs of the typed objects are missing in the unstructured ones
+	removeEmptyValues(m)
+	return m, nil
+}
+
+func removeEmptyValues(m map[string]interface{}) {
+	for key, value := range m {
+		if nested, ok := value.(map[string]interface{}); ok {
+			removeEmptyValues(nested)
+			if len(nested) == 0 {
+				delete(m, key)
+			}
+		} else if value == nil {
+			delete(m, key)
+		}
+	}
+}
+
 func isGenerationChangeNeeded(currentObj, updatedObj client.Object) (bool, error) {
 	// Update Generation if needed since the kube fake client doesn't update generations.
 	// Increment the generation if spec (for objects with Spec) or data/stringData (for objects like CM and Secrets) is changed.
@@ -259,6 +320,12 @@ func Patch(ctx context.Context, fakeClient *FakeClient, obj client.Object, patch
 		found = false
 	}
 
+	patchOptions := &client.PatchOptions{}
+	patchOptions.ApplyOptions(opts)
+	if len(patchOptions.DryRun) > 0 {
+		return dryRunPatch(obj, orig, found, patch)
+	}
+
 	// A non-SSA patch assumes the object must already exist and should break if it doesn't. The SSA patch, on the other hand, creates the object
 	// if it doesn't exist.
 	if patch == client.Apply {
@@ -295,8 +362,57 @@ func Patch(ctx context.Context, fakeClient *FakeClient, obj client.Object, patch
 
 		if shouldUpdateGeneration {
 			obj.SetGeneration(orig.GetGeneration() + 1)
+		} else if patch.Type() == types.MergePatchType {
+			// like the API server, don't modify the object (and its resourceVersion) if the patch doesn't change anything
+			patched := obj.DeepCopyObject().(client.Object)
+			if err := dryRunPatch(patched, orig, true, patch); err != nil {
+				return err
+			}
+			if noop, err := isNoopChange(orig, patched); err != nil || noop {
+				if err != nil {
+					return err
+				}
+				return fakeClient.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+			}
 		}
 	}
 
 	return fakeClient.Client.Patch(ctx, obj, patch, opts...)
 }
+
+// newConflictError returns the error of the API server when the resourceVersion of the given object is stale
+func newConflictError(obj client.Object) error {
+	return errors.NewConflict(schema.GroupResource{}, obj.GetName(), fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+}
+
+// dryRunPatch emulates the response of the API server to a dry-run patch, because the fake client doesn't modify the object at all.
+// The patch is applied as a JSON merge patch on top of the original object (if it exists) and the result is stored in the given object.
+func dryRunPatch(obj, orig client.Object, found bool, patch client.Patch) error {
+	if !found {
+		if patch != client.Apply {
+			return errors.NewNotFound(schema.GroupResource{}, obj.GetName())
+		}
+		// the SSA patch creates the object, so the result is the object itself
+		return nil
+	}
+	patchData, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	origData, err := json.Marshal(orig)
+	if err != nil {
+		return err
+	}
+	patched, err := jsonpatch.MergePatch(origData, patchData)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patched, obj); err != nil {
+		return err
+	}
+	// like the API server, reject the patch which contains a stale resourceVersion (eg. with the optimistic lock)
+	if obj.GetResourceVersion() != orig.GetResourceVersion() {
+		return newConflictError(obj)
+	}
+	return nil
+}
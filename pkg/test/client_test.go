@@ -109,6 +109,34 @@ func TestNewClient(t *testing.T) {
 			assert.EqualValues(t, 1, retrieved.Generation) // Generation updated
 		})
 
+		t.Run("update object without any change keeps the resourceVersion", func(t *testing.T) {
+			created, retrieved := createAndGetDeployment(t, fclient)
+			resourceVersion := retrieved.ResourceVersion
+			require.NoError(t, fclient.Update(context.TODO(), retrieved))
+			assert.Equal(t, resourceVersion, retrieved.ResourceVersion)
+			require.NoError(t, fclient.Get(context.TODO(), types.NamespacedName{Namespace: "somenamespace", Name: created.Name}, retrieved))
+			assert.Equal(t, resourceVersion, retrieved.ResourceVersion) // like the API server, the no-op update is not persisted
+		})
+
+		t.Run("update object without any change with a stale resourceVersion fails with a conflict", func(t *testing.T) {
+			_, retrieved := createAndGetDeployment(t, fclient)
+			stale := retrieved.DeepCopy()
+			retrieved.Labels = map[string]string{"foo": "baz"}
+			require.NoError(t, fclient.Update(context.TODO(), retrieved))
+			stale.Labels = map[string]string{"foo": "baz"}
+			err := fclient.Update(context.TODO(), stale)
+			assert.True(t, errs.IsConflict(err), "expected a conflict, got %v", err)
+		})
+
+		t.Run("update object labels changes the resourceVersion but not the generation", func(t *testing.T) {
+			_, retrieved := createAndGetDeployment(t, fclient)
+			resourceVersion := retrieved.ResourceVersion
+			retrieved.Labels = map[string]string{"foo": "baz"}
+			require.NoError(t, fclient.Update(context.TODO(), retrieved))
+			assert.NotEqual(t, resourceVersion, retrieved.ResourceVersion)
+			assert.EqualValues(t, 1, retrieved.Generation)
+		})
+
 		t.Run("no error in blank status update", func(t *testing.T) {
 			created, retrieved := createAndGetDeployment(t, fclient)
 			require.NoError(t, fclient.Status().Update(context.TODO(), created))
@@ -148,6 +176,29 @@ func TestNewClient(t *testing.T) {
 			assert.Equal(t, annotations, retrieved.GetObjectMeta().GetAnnotations())
 		})
 
+		t.Run("patch without any change keeps the resourceVersion", func(t *testing.T) {
+			created, retrieved := createAndGetSecret(t, fclient)
+			resourceVersion := retrieved.ResourceVersion
+			mergePatch, err := json.Marshal(map[string]interface{}{
+				"stringData": created.StringData,
+			})
+			require.NoError(t, err)
+			require.NoError(t, fclient.Patch(context.TODO(), created, client.RawPatch(types.MergePatchType, mergePatch)))
+			require.NoError(t, fclient.Get(context.TODO(), types.NamespacedName{Namespace: "somenamespace", Name: created.Name}, retrieved))
+			assert.Equal(t, resourceVersion, retrieved.ResourceVersion)
+		})
+
+		t.Run("patch without any change with a stale resourceVersion fails with a conflict", func(t *testing.T) {
+			_, retrieved := createAndGetSecret(t, fclient)
+			stale := retrieved.DeepCopy()
+			retrieved.Labels = map[string]string{"foo": "baz"}
+			require.NoError(t, fclient.Update(context.TODO(), retrieved))
+			patch := client.MergeFromWithOptions(stale.DeepCopy(), client.MergeFromWithOptimisticLock{})
+			stale.Labels = map[string]string{"foo": "baz"}
+			err := fclient.Patch(context.TODO(), stale, patch)
+			assert.True(t, errs.IsConflict(err), "expected a conflict, got %v", err)
+		})
+
 		t.Run("status patch", func(t *testing.T) {
 			_, retrieved := createAndGetDeployment(t, fclient)
 			depPatch := client.MergeFrom(retrieved.DeepCopy())